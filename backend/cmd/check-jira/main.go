@@ -1,76 +1,263 @@
+// Command check-jira diagnoses the Jira configuration in the environment (or
+// .env): it checks the connection and credentials, the search API, the story
+// points field of each project and the permissions estimates need, without
+// changing anything in Jira, and can print a ready-to-use configuration.
+//
+//	go run ./cmd/check-jira -project WEB,PAY -config
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/joho/godotenv"
+	"github.com/poker/backend/internal/jira"
 )
 
+// checker prints the outcome of each check and counts the failures
+type checker struct {
+	failures int
+}
+
+func (c *checker) ok(format string, args ...interface{}) {
+	fmt.Printf("✓ "+format+"\n", args...)
+}
+
+func (c *checker) warn(format string, args ...interface{}) {
+	fmt.Printf("⚠️  "+format+"\n", args...)
+}
+
+func (c *checker) fail(format string, args ...interface{}) {
+	c.failures++
+	fmt.Printf("✗ "+format+"\n", args...)
+}
+
+// projectResult is what was found out about a project
+type projectResult struct {
+	project string
+	field   *jira.Field
+}
+
 func main() {
+	projects := flag.String("project", "", "Comma-separated project keys to check (default: the most recently updated issue of the site)")
+	issueKey := flag.String("issue", "", "Issue to check permissions on (default: the project's most recently updated issue)")
+	printConfig := flag.Bool("config", false, "Print a ready-to-use configuration")
+	flag.Parse()
+
 	// Load .env from backend root (assuming we run from backend dir)
 	_ = godotenv.Load()
 
-	baseURL := os.Getenv("JIRA_URL")
-	email := os.Getenv("JIRA_EMAIL")
-	token := os.Getenv("JIRA_TOKEN")
+	config := jira.Config{
+		BaseURL:          os.Getenv("JIRA_URL"),
+		Email:            os.Getenv("JIRA_EMAIL"),
+		APIToken:         os.Getenv("JIRA_TOKEN"),
+		StoryPointsField: os.Getenv("JIRA_POINTS_FIELD"),
+		Retries:          -1, // Report failures as they are
+	}
+	var err error
+	if config.Flavor, err = jira.ParseFlavor(os.Getenv("JIRA_FLAVOR")); err != nil {
+		fatal("Invalid JIRA_FLAVOR: %v", err)
+	}
+	if config.EstimateFields, err = jira.ParseEstimateFields(os.Getenv("JIRA_ESTIMATE_FIELDS")); err != nil {
+		fatal("Invalid JIRA_ESTIMATE_FIELDS: %v", err)
+	}
+	client, err := jira.NewClient(config)
+	if err != nil {
+		fatal("Invalid Jira configuration: %v (set JIRA_URL and JIRA_TOKEN, and JIRA_EMAIL for Jira Cloud)", err)
+	}
+
+	ctx := context.Background()
+	c := &checker{}
 
-	if baseURL == "" || email == "" || token == "" {
-		log.Fatal("Please set JIRA_URL, JIRA_EMAIL, and JIRA_TOKEN in .env")
+	// Connection and flavor; nothing else can be checked without them
+	info, err := client.ServerInfo(ctx)
+	if err != nil {
+		c.fail("Cannot reach Jira at %s: %v", config.BaseURL, err)
+		os.Exit(1)
+	}
+	c.ok("Jira %s %s at %s", info.DeploymentType, info.Version, config.BaseURL)
+	if config.Flavor != jira.FlavorAuto && config.Flavor != info.Flavor() {
+		c.fail("JIRA_FLAVOR is %s, but the site is %s", config.Flavor, info.Flavor())
+	}
+	if info.Flavor() == jira.FlavorCloud && config.Email == "" {
+		c.fail("Jira Cloud needs JIRA_EMAIL with an API token in JIRA_TOKEN")
 	}
 
-	url := fmt.Sprintf("%s/rest/api/3/field", baseURL)
-	req, err := http.NewRequest("GET", url, nil)
+	// Credentials
+	user, err := client.Myself(ctx)
 	if err != nil {
-		log.Fatal(err)
+		c.fail("Authentication failed: %v", err)
+		if info.Flavor() == jira.FlavorCloud {
+			fmt.Println("  Use the account's email in JIRA_EMAIL and an API token from id.atlassian.com in JIRA_TOKEN")
+		} else {
+			fmt.Println("  Leave JIRA_EMAIL empty and use a personal access token in JIRA_TOKEN")
+		}
+		os.Exit(1)
 	}
+	c.ok("Authenticated as %s", describeUser(user))
 
-	req.SetBasicAuth(email, token)
-	req.Header.Set("Accept", "application/json")
+	// Search
+	if err := client.ValidateConnection(ctx); err != nil {
+		c.fail("Search with %s failed: %v", client.SearchAPI(ctx), err)
+	} else {
+		c.ok("Search works with %s", client.SearchAPI(ctx))
+	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Fatal(err)
+	// Story points field and permissions, per project
+	keys := splitList(*projects)
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	var results []projectResult
+	for _, project := range keys {
+		results = append(results, checkProject(ctx, c, client, config, project, *issueKey))
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		log.Fatalf("Error: Status %d", resp.StatusCode)
+	if *printConfig {
+		printEnv(config, info, results)
+	}
+	if c.failures > 0 {
+		fmt.Printf("\n%d check(s) failed\n", c.failures)
+		os.Exit(1)
 	}
+}
 
-	var fields []struct {
-		ID   string `json:"id"`
-		Name string `json:"name"`
+// checkProject finds the project's story points field and checks, on a
+// sample issue, that estimates can be read and written
+func checkProject(ctx context.Context, c *checker, client *jira.Client, config jira.Config, project, issueKey string) projectResult {
+	result := projectResult{project: project}
+	name := project
+	if name == "" {
+		name = "Site"
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&fields); err != nil {
-		log.Fatal(err)
+	field, err := client.DiscoverPointsField(ctx, project)
+	switch {
+	case err != nil:
+		c.fail("%s: no story points field found: %v", name, err)
+	case config.StoryPointsField != "" && config.StoryPointsField != field.ID:
+		result.field = field
+		c.warn("%s: story points field is %s (%s), but JIRA_POINTS_FIELD is %s", name, field.ID, field.Name, config.StoryPointsField)
+	default:
+		result.field = field
+		c.ok("%s: story points field is %s (%s)", name, field.ID, field.Name)
 	}
 
-	fmt.Println("Found Fields matching 'Story Points' or 'Estimate':")
-	fmt.Println("------------------------------------------------")
-	found := false
+	// Permissions are checked on an issue, without changing it
+	if issueKey == "" {
+		issue, err := client.SampleIssue(ctx, project)
+		if err != nil {
+			c.fail("%s: cannot read issues: %v", name, err)
+			return result
+		}
+		if issue == nil {
+			c.warn("%s: no issues to check permissions on", name)
+			return result
+		}
+		issueKey = issue.Key
+	}
+	if _, err := client.GetIssue(ctx, issueKey); err != nil {
+		c.fail("%s: cannot read %s: %v", name, issueKey, err)
+		return result
+	}
+	c.ok("%s: can read %s", name, issueKey)
+
+	permissions := []string{jira.PermissionBrowse, jira.PermissionEdit, jira.PermissionComment, jira.PermissionTransition}
+	granted, err := client.Permissions(ctx, issueKey, permissions...)
+	if err != nil {
+		c.fail("%s: cannot check permissions on %s: %v", name, issueKey, err)
+		return result
+	}
+	for _, permission := range permissions {
+		switch {
+		case granted[permission]:
+			c.ok("%s: has %s on %s", name, permission, issueKey)
+		case permission == jira.PermissionBrowse || permission == jira.PermissionEdit:
+			c.fail("%s: lacks %s on %s, which estimates need", name, permission, issueKey)
+		default:
+			c.warn("%s: lacks %s on %s, needed only for room Jira actions", name, permission, issueKey)
+		}
+	}
+
+	// The fields estimates are written to must be on the issue's edit screen
+	editable, err := client.EditMeta(ctx, issueKey)
+	if err != nil {
+		c.fail("%s: cannot check which fields of %s can be edited: %v", name, issueKey, err)
+		return result
+	}
+	fields := config.EstimateFields
+	if len(fields) == 0 && result.field != nil {
+		fields = []jira.EstimateField{{ID: result.field.ID, Type: jira.FieldNumber}}
+	}
 	for _, f := range fields {
-		nameLower := strings.ToLower(f.Name)
-		if strings.Contains(nameLower, "story") || strings.Contains(nameLower, "point") || strings.Contains(nameLower, "estimate") {
-			fmt.Printf("ID: %-20s | Name: %s\n", f.ID, f.Name)
-			found = true
+		if slices.ContainsFunc(editable, func(e jira.Field) bool { return e.ID == f.ID }) {
+			c.ok("%s: %s can be written on %s", name, f.ID, issueKey)
+		} else {
+			c.fail("%s: %s cannot be written on %s; add it to the project's edit screen", name, f.ID, issueKey)
+		}
+	}
+	return result
+}
+
+// printEnv prints the environment variables of a working configuration
+func printEnv(config jira.Config, info *jira.ServerInfo, results []projectResult) {
+	fmt.Println("\n# Jira configuration")
+	fmt.Printf("JIRA_URL=%s\n", config.BaseURL)
+	fmt.Printf("JIRA_FLAVOR=%s\n", info.Flavor())
+	if config.Email != "" {
+		fmt.Printf("JIRA_EMAIL=%s\n", config.Email)
+	}
+	fmt.Println("# JIRA_TOKEN: keep your current token")
+
+	// The field of the first project checked, noting projects that differ
+	var field *jira.Field
+	for _, r := range results {
+		if r.field == nil {
+			continue
+		}
+		if field == nil {
+			field = r.field
+		} else if r.field.ID != field.ID {
+			fmt.Printf("# %s uses %s (%s) for story points instead\n", r.project, r.field.ID, r.field.Name)
+		}
+	}
+	if field != nil {
+		fmt.Printf("JIRA_POINTS_FIELD=%s\n", field.ID)
+	}
+	if len(config.EstimateFields) > 0 {
+		entries := make([]string, len(config.EstimateFields))
+		for i, f := range config.EstimateFields {
+			entries[i] = f.ID + ":" + string(f.Type)
 		}
+		fmt.Printf("JIRA_ESTIMATE_FIELDS=%s\n", strings.Join(entries, ","))
 	}
+}
+
+func describeUser(user *jira.User) string {
+	switch {
+	case user.EmailAddress != "":
+		return fmt.Sprintf("%s <%s>", user.DisplayName, user.EmailAddress)
+	case user.Name != "":
+		return fmt.Sprintf("%s (%s)", user.DisplayName, user.Name)
+	}
+	return user.DisplayName
+}
 
-	if !found {
-		fmt.Println("No matching fields found. Listing all custom fields:")
-		for _, f := range fields {
-			if strings.HasPrefix(f.ID, "customfield") {
-				fmt.Printf("ID: %-20s | Name: %s\n", f.ID, f.Name)
-			}
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, strings.ToUpper(v))
 		}
-	} else {
-		fmt.Println("------------------------------------------------")
-		fmt.Println("Update your .env file with the correct JIRA_POINTS_FIELD value.")
 	}
+	return values
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"github.com/poker/backend/internal/db"
+)
+
+const usage = `Usage: migrate <command>
+
+Commands:
+  up            Apply all pending migrations (default)
+  status        List migrations and whether they are applied
+  rollback [n]  Revert the last n applied migrations (default 1)
+
+The database is taken from DATABASE_URL (Postgres) if set, otherwise from
+DB_PATH (SQLite, default ./data/poker.db).`
+
+func main() {
+	// Load .env from backend root (assuming we run from backend dir)
+	_ = godotenv.Load()
+
+	command := "up"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "./data/poker.db"
+	}
+
+	conn, dialect, err := db.Connect(os.Getenv("DATABASE_URL"), dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+
+	switch command {
+	case "up":
+		if err := db.Migrate(conn, dialect); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		fmt.Println("Database is up to date")
+
+	case "status":
+		status, err := db.Status(conn, dialect)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-30s  %s\n", s.Version, s.Name, applied)
+		}
+
+	case "rollback":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations: %s", os.Args[2])
+			}
+		}
+		reverted, err := db.Rollback(conn, dialect, steps)
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", reverted)
+
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/poker/backend/internal/cluster"
 	"github.com/poker/backend/internal/db"
 	"github.com/poker/backend/internal/game"
 	"github.com/poker/backend/internal/handler"
@@ -24,8 +26,9 @@ func main() {
 	// Configuration from environment
 	port := getEnv("PORT", "8080")
 	defaultExpiry, _ := strconv.Atoi(getEnv("DEFAULT_ROOM_EXPIRY_HOURS", "24"))
-	allowedOrigins := getEnv("ALLOWED_ORIGINS", "*")
+	allowedOrigins := middleware.NewOriginPolicy(middleware.ParseOrigins(getEnv("ALLOWED_ORIGINS", "*")))
 	dbPath := getEnv("DB_PATH", "./data/poker.db")
+	databaseURL := getEnv("DATABASE_URL", "") // Postgres; takes precedence over DB_PATH
 
 	// Ensure data directory exists
 	if err := os.MkdirAll("./data", 0755); err != nil {
@@ -33,28 +36,106 @@ func main() {
 	}
 
 	// Initialize Database
-	if err := db.InitDB(dbPath); err != nil {
+	conn, dialect, err := db.Connect(databaseURL, dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.Migrate(conn, dialect); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	repo := db.NewRoomRepo(db.DB)
+	log.Printf("Using %s database", dialect.Name)
+	repo := db.NewRoomRepoWithDialect(conn, dialect)
+
+	// Room code format
+	codeLength, _ := strconv.Atoi(getEnv("ROOM_CODE_LENGTH", "0"))
+	codes, err := game.NewCodeGenerator(getEnv("ROOM_CODE_STYLE", game.CodeStyleRandom), codeLength, getEnv("ROOM_CODE_ALPHABET", ""))
+	if err != nil {
+		log.Fatalf("Invalid room code configuration: %v", err)
+	}
+
+	// Host tokens never expire unless a lifetime is configured
+	hostTokenTTLHours, _ := strconv.Atoi(getEnv("HOST_TOKEN_TTL_HOURS", "0"))
+
+	// Capacity; zero values fall back to the hub defaults
+	maxRooms, _ := strconv.Atoi(getEnv("MAX_ROOMS", "0"))
+	maxPlayers, _ := strconv.Atoi(getEnv("MAX_PLAYERS_PER_ROOM", "0"))
+	idleMinutes, _ := strconv.Atoi(getEnv("ROOM_IDLE_EVICT_MINUTES", "0"))
+	unloadMinutes, _ := strconv.Atoi(getEnv("ROOM_UNLOAD_MINUTES", "0"))
+
+	// Clustering: rooms are leased to one instance via the broker's registry
+	instanceID := getEnv("INSTANCE_ID", getEnv("FLY_MACHINE_ID", ""))
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+	var broker *cluster.Redis
+	if brokerURL := getEnv("BROKER_URL", ""); brokerURL != "" {
+		broker, err = cluster.DialRedis(context.Background(), brokerURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to broker: %v", err)
+		}
+		defer broker.Close()
+		if databaseURL == "" {
+			log.Println("⚠️  BROKER_URL is set without DATABASE_URL: instances cannot take over each other's rooms")
+		}
+		log.Printf("Clustering enabled as instance %s", instanceID)
+	}
 
 	// Create hub
-	hub := game.NewHub(defaultExpiry, repo)
+	hubConfig := game.HubConfig{
+		DefaultExpiry: defaultExpiry,
+		Codes:         codes,
+		HostTokenTTL:  time.Duration(hostTokenTTLHours) * time.Hour,
+		MaxRooms:      maxRooms,
+		MaxPlayers:    maxPlayers,
+		IdleTimeout:   time.Duration(idleMinutes) * time.Minute,
+		UnloadAfter:   time.Duration(unloadMinutes) * time.Minute,
+		InstanceID:    instanceID,
+	}
+	if broker != nil {
+		hubConfig.Registry = broker
+		hubConfig.Broker = broker
+	}
+	hub := game.NewHubWithConfig(hubConfig, repo)
 	defer hub.Stop()
 
-	// Create handlers
-	roomHandler := handler.NewRoomHandler(hub)
-	wsHandler := handler.NewWebSocketHandler(hub)
+	// Rate limits are shared through the broker's Redis when clustered, so
+	// every instance draws from the same budget
+	var limiter middleware.Limiter = middleware.NewMemoryLimiter(middleware.DefaultLimiterCapacity)
+	if broker != nil {
+		limiter = middleware.NewRedisLimiter(broker.Client())
+	}
 
 	// Initialize Jira Client
 	var jiraHandler *handler.JiraHandler
+	var jiraWebhook *handler.JiraWebhookHandler
+	var issueLoader handler.IssueLoader
 	jiraBaseURL := getEnv("JIRA_URL", "")
 	if jiraBaseURL != "" {
+		jiraFlavor, err := jira.ParseFlavor(getEnv("JIRA_FLAVOR", ""))
+		if err != nil {
+			log.Fatalf("Invalid JIRA_FLAVOR: %v", err)
+		}
 		jiraConfig := jira.Config{
-			BaseURL:          jiraBaseURL,
-			Email:            getEnv("JIRA_EMAIL", ""),
-			APIToken:         getEnv("JIRA_TOKEN", ""),
-			StoryPointsField: getEnv("JIRA_POINTS_FIELD", "customfield_10016"),
+			BaseURL:                 jiraBaseURL,
+			Flavor:                  jiraFlavor,
+			Email:                   getEnv("JIRA_EMAIL", ""),
+			APIToken:                getEnv("JIRA_TOKEN", ""),
+			StoryPointsField:        getEnv("JIRA_POINTS_FIELD", ""),
+			AcceptanceCriteriaField: getEnv("JIRA_ACCEPTANCE_FIELD", ""),
+		}
+		jiraConfig.SearchLimit, _ = strconv.Atoi(getEnv("JIRA_SEARCH_LIMIT", "0"))
+		jiraConfig.MaxSearchLimit, _ = strconv.Atoi(getEnv("JIRA_SEARCH_MAX_LIMIT", "0"))
+		jiraConfig.HoursPerPoint, _ = strconv.ParseFloat(getEnv("JIRA_HOURS_PER_POINT", "0"), 64)
+		jiraTimeout, _ := strconv.Atoi(getEnv("JIRA_TIMEOUT_SECONDS", "0"))
+		jiraConfig.Timeout = time.Duration(jiraTimeout) * time.Second
+		jiraConfig.Retries, _ = strconv.Atoi(getEnv("JIRA_REQUEST_RETRIES", "0"))
+		jiraCacheSeconds, _ := strconv.Atoi(getEnv("JIRA_CACHE_SECONDS", "0"))
+		jiraConfig.CacheTTL = time.Duration(jiraCacheSeconds) * time.Second
+		jiraConfig.BreakerThreshold, _ = strconv.Atoi(getEnv("JIRA_BREAKER_THRESHOLD", "0"))
+		jiraCooldown, _ := strconv.Atoi(getEnv("JIRA_BREAKER_COOLDOWN_SECONDS", "0"))
+		jiraConfig.BreakerCooldown = time.Duration(jiraCooldown) * time.Second
+		if jiraConfig.EstimateFields, err = jira.ParseEstimateFields(getEnv("JIRA_ESTIMATE_FIELDS", "")); err != nil {
+			log.Fatalf("Invalid JIRA_ESTIMATE_FIELDS: %v", err)
 		}
 
 		jiraClient, err := jira.NewClient(jiraConfig)
@@ -63,67 +144,154 @@ func main() {
 			log.Println("Jira integration disabled due to configuration error")
 		} else {
 			// Validate Jira connection on startup (warn but don't fail)
-			if err := jiraClient.ValidateConnection(); err != nil {
+			if err := jiraClient.ValidateConnection(context.Background()); err != nil {
 				log.Printf("⚠️  Jira connection validation failed: %v", err)
 				log.Println("⚠️  Jira integration enabled but connection could not be validated")
-				log.Println("⚠️  Please check your JIRA_URL, JIRA_EMAIL, and JIRA_TOKEN")
+				log.Println("⚠️  Please check your JIRA_URL, JIRA_EMAIL, JIRA_TOKEN and JIRA_FLAVOR")
+				log.Println("⚠️  Run `go run ./cmd/check-jira` for a full diagnosis")
 				log.Println("⚠️  Server will continue, but Jira features may not work")
 			} else {
 				log.Printf("✓ Jira integration enabled and validated for %s", jiraBaseURL)
+
+				// Without a configured field, use the one the site's issues have
+				if jiraConfig.StoryPointsField == "" {
+					field, err := jiraClient.DiscoverPointsField(context.Background(), "")
+					if err != nil {
+						log.Printf("⚠️  Could not discover the story points field, using %s: %v", jiraClient.PointsFieldID(), err)
+						log.Println("⚠️  Set JIRA_POINTS_FIELD, or run `go run ./cmd/check-jira` to find it")
+					} else {
+						jiraClient.UsePointsField(field.ID)
+						log.Printf("✓ Discovered story points field %s (%s)", field.ID, field.Name)
+					}
+				}
 			}
 
-			jiraHandler = handler.NewJiraHandler(jiraClient)
+			// Write-backs are stored and retried while Jira is unavailable
+			outboxConfig := jira.OutboxConfig{}
+			outboxConfig.MaxAttempts, _ = strconv.Atoi(getEnv("JIRA_RETRY_ATTEMPTS", "0"))
+			outboxSeconds, _ := strconv.Atoi(getEnv("JIRA_RETRY_DELAY_SECONDS", "0"))
+			outboxConfig.BaseDelay = time.Duration(outboxSeconds) * time.Second
+			outbox := jira.NewOutboxWithConfig(jiraClient, db.NewOutboxRepo(conn, dialect), outboxConfig)
+			outbox.Start()
+			defer outbox.Stop()
+
+			jiraHandler = handler.NewJiraHandler(jiraClient, hub, outbox)
+			jiraWebhook = handler.NewJiraWebhookHandler(jiraClient, hub, getEnv("JIRA_WEBHOOK_SECRET", ""))
+			issueLoader = jiraClient
 		}
 	} else {
 		log.Println("Jira integration disabled: JIRA_URL not set")
 	}
 
-	// Setup router
-	r := gin.Default()
+	// Create handlers
+	roomHandler := handler.NewRoomHandler(hub)
+	wsMaxConnsPerIP, _ := strconv.Atoi(getEnv("WS_MAX_CONNS_PER_IP", "20"))
+	wsJoinRate, _ := strconv.ParseFloat(getEnv("WS_JOIN_RATE", "1"), 64)
+	wsJoinBurst, _ := strconv.Atoi(getEnv("WS_JOIN_BURST", "10"))
+	wsRateLimitWarnings, _ := strconv.Atoi(getEnv("WS_RATE_LIMIT_WARNINGS", "3"))
+	wsRateLimitAction, err := handler.ParseRateLimitAction(getEnv("WS_RATE_LIMIT_ACTION", "warn"))
+	if err != nil {
+		log.Fatalf("Invalid WS_RATE_LIMIT_ACTION: %v", err)
+	}
+	wsHandler := handler.NewWebSocketHandlerWithConfig(hub, handler.WebSocketConfig{
+		Origins:              allowedOrigins,
+		MaxConnsPerIP:        wsMaxConnsPerIP,
+		JoinRate:             wsJoinRate,
+		JoinBurst:            wsJoinBurst,
+		RateLimitAction:      wsRateLimitAction,
+		MaxRateLimitWarnings: wsRateLimitWarnings,
+		Limiter:              limiter,
+		Issues:               issueLoader,
+	})
 
-	// CORS configuration
-	var origins []string
-	if allowedOrigins == "*" {
-		origins = []string{"*"}
-	} else {
-		origins = []string{
-			"https://scrum-poker.pages.dev", // Your Cloudflare Pages domain
-			"https://*.pages.dev",           // Cloudflare Pages preview domains
-			"http://localhost:5173",         // Local development
-			"http://localhost:3000",         // Alternative local port
-		}
+	// Setup router. Webhook requests are not logged: Jira versions that
+	// cannot sign them pass the secret in the query string.
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/api/jira/webhook"}}), gin.Recovery())
+
+	// Client IPs key the rate limits, so forwarded headers are only believed
+	// from configured proxies or the hosting platform's edge
+	trustedPlatform := getEnv("TRUSTED_PLATFORM", "")
+	if trustedPlatform == "" && os.Getenv("FLY_MACHINE_ID") != "" {
+		trustedPlatform = "fly"
+	}
+	if err := middleware.ConfigureClientIP(r, middleware.ClientIPConfig{
+		TrustedProxies: middleware.ParseProxies(getEnv("TRUSTED_PROXIES", "")),
+		Platform:       trustedPlatform,
+	}); err != nil {
+		log.Fatalf("Invalid client IP configuration: %v", err)
+	}
+
+	// CORS configuration (the same policy guards WebSocket upgrades)
+	if allowedOrigins.AllowsAll() {
+		log.Println("⚠️  ALLOWED_ORIGINS is \"*\": any site can call the API and open sockets")
 	}
+	r.Use(middleware.CORSMiddleware(allowedOrigins))
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     origins,
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		AllowCredentials: true,
-		AllowWebSockets:  true,
-	}))
+	// Stricter limits and failure backoff for endpoints that reveal whether a room exists
+	lookupConfig := middleware.DefaultLookupGuardConfig()
+	if v, err := strconv.ParseFloat(getEnv("LOOKUP_RATE", ""), 64); err == nil {
+		lookupConfig.RequestsPerSecond = v
+	}
+	if v, err := strconv.Atoi(getEnv("LOOKUP_BURST", "")); err == nil {
+		lookupConfig.Burst = v
+	}
+	lookupConfig.Limiter = limiter
+	lookups := middleware.NewLookupGuard(lookupConfig)
+	defer lookups.Stop()
+
+	// Requests for rooms owned by another instance are replayed there; on Fly
+	// the proxy does this for us
+	var routing gin.HandlerFunc = func(c *gin.Context) { c.Next() }
+	if broker != nil {
+		routing = middleware.RoomRouter(hub.Owner, os.Getenv("FLY_MACHINE_ID") != "")
+	}
+
+	// Per-route rate limit policies
+	apiPolicy := rateLimitPolicy("api", "API", 10, 20)
+	createPolicy := rateLimitPolicy("create", "CREATE", 0.1, 5)
+	jiraPolicy := rateLimitPolicy("jira", "JIRA", 2, 10)
+
+	// Rooms each client IP may create per hour
+	roomQuota, _ := strconv.Atoi(getEnv("ROOM_CREATE_QUOTA", "20"))
+	creations := middleware.NewQuota(roomQuota, time.Hour)
+	defer creations.Stop()
 
 	// Routes
 	api := r.Group("/api")
-	api.Use(middleware.RateLimitMiddleware(10, 20)) // 10 requests/sec, burst 20
+	api.Use(middleware.RateLimit(limiter, apiPolicy))
+	api.Use(routing)
 	{
 		api.GET("/health", roomHandler.HealthCheck)
 		api.GET("/stats", roomHandler.GetStats)
 		api.GET("/scales", roomHandler.GetScales)
 
 		// Room routes
-		api.POST("/rooms", roomHandler.CreateRoom)
-		api.GET("/rooms/:code", roomHandler.GetRoom)
-		api.GET("/rooms/:code/check", roomHandler.CheckRoom)
+		create := middleware.RateLimit(limiter, createPolicy)
+		api.POST("/rooms", create, creations.Middleware(), roomHandler.CreateRoom)
+		api.PUT("/rooms/:code", create, creations.Middleware(), roomHandler.ReserveRoom)
+		api.POST("/rooms/import", create, creations.Middleware(), roomHandler.ImportRoom)
+		api.GET("/rooms/:code", lookups.Middleware(), roomHandler.GetRoom)
+		api.GET("/rooms/:code/check", lookups.Middleware(), roomHandler.CheckRoom)
+		api.GET("/rooms/:code/export", lookups.Middleware(), roomHandler.ExportRoom)
 
 		// Jira routes
 		if jiraHandler != nil {
-			api.GET("/jira/search", jiraHandler.Search)
-			api.POST("/jira/issue/:key/estimate", jiraHandler.UpdateEstimation)
+			jiraLimit := middleware.RateLimit(limiter, jiraPolicy)
+			api.GET("/jira/search", jiraLimit, jiraHandler.Search)
+			api.POST("/jira/issue/:key/estimate", jiraLimit, jiraHandler.UpdateEstimation)
+			api.GET("/jira/boards", jiraLimit, jiraHandler.ListBoards)
+			api.GET("/jira/boards/:id/sprints", jiraLimit, jiraHandler.ListSprints)
+			api.GET("/jira/outbox", jiraLimit, jiraHandler.ListWrites)
+			api.POST("/jira/outbox/:id/retry", jiraLimit, jiraHandler.RetryWrite)
+			// Jira sends a burst of events on bulk edits, so only the API limit applies
+			api.POST("/jira/webhook", jiraWebhook.Receive)
 		}
 	}
 
-	// WebSocket route
-	r.GET("/ws", wsHandler.HandleConnection)
+	// WebSocket route. Joins have their own rate limit, so only the lookup
+	// failure backoff applies here.
+	r.GET("/ws", routing, lookups.BackoffMiddleware(), wsHandler.HandleConnection)
 
 	log.Printf("Starting server on port %s", port)
 	log.Printf("Default room expiry: %d hours", defaultExpiry)
@@ -133,6 +301,17 @@ func main() {
 	}
 }
 
+// rateLimitPolicy reads a policy's rate and burst from <PREFIX>_RATE and <PREFIX>_BURST
+func rateLimitPolicy(name, prefix string, rate float64, burst int) middleware.Policy {
+	if v, err := strconv.ParseFloat(getEnv(prefix+"_RATE", ""), 64); err == nil {
+		rate = v
+	}
+	if v, err := strconv.Atoi(getEnv(prefix+"_BURST", "")); err == nil {
+		burst = v
+	}
+	return middleware.Policy{Name: name, Limit: middleware.Limit{Rate: rate, Burst: burst}}
+}
+
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
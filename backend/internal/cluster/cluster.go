@@ -0,0 +1,31 @@
+// Package cluster lets several server instances share rooms. Each room is
+// owned by one instance at a time, recorded as a lease in a Registry; requests
+// for a room are routed to its owner, and room events raised elsewhere reach
+// the owner through a Broker.
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// Broker publishes messages to every subscriber of a topic, on any instance
+type Broker interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe calls handler for each message on the topic until the
+	// returned function is called. Handlers run on a single goroutine per
+	// subscription.
+	Subscribe(ctx context.Context, topic string, handler func(payload []byte)) (unsubscribe func(), err error)
+	Close() error
+}
+
+// Registry records which instance owns each room
+type Registry interface {
+	// Claim takes or renews the lease on a room for the instance and returns
+	// the room's owner, which is another instance if its lease is still valid
+	Claim(ctx context.Context, code, instance string, ttl time.Duration) (owner string, err error)
+	// Owner returns the instance holding the lease, or "" if there is none
+	Owner(ctx context.Context, code string) (string, error)
+	// Release gives up the instance's lease; leases of other instances are kept
+	Release(ctx context.Context, code, instance string) error
+}
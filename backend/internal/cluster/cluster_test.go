@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCluster is a broker and registry with a way to let leases run out
+type testCluster struct {
+	broker   Broker
+	registry Registry
+	advance  func(d time.Duration)
+}
+
+// forEachCluster runs the test against every implementation; Redis is
+// stood in for by an in-process miniredis server
+func forEachCluster(t *testing.T, test func(t *testing.T, c testCluster)) {
+	t.Run("memory", func(t *testing.T) {
+		m := NewMemory()
+		now := time.Now()
+		m.now = func() time.Time { return now }
+		t.Cleanup(func() { m.Close() })
+		test(t, testCluster{broker: m, registry: m, advance: func(d time.Duration) { now = now.Add(d) }})
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		r := NewRedis(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+		t.Cleanup(func() { r.Close() })
+		test(t, testCluster{broker: r, registry: r, advance: server.FastForward})
+	})
+}
+
+func TestRegistry_Leases(t *testing.T) {
+	forEachCluster(t, func(t *testing.T, c testCluster) {
+		ctx := context.Background()
+
+		owner, err := c.registry.Claim(ctx, "ROOM", "a", time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, "a", owner)
+
+		// Another instance cannot take a held lease, the holder can renew it
+		owner, _ = c.registry.Claim(ctx, "ROOM", "b", time.Minute)
+		assert.Equal(t, "a", owner)
+		owner, _ = c.registry.Claim(ctx, "ROOM", "a", time.Minute)
+		assert.Equal(t, "a", owner)
+		owner, _ = c.registry.Owner(ctx, "ROOM")
+		assert.Equal(t, "a", owner)
+
+		// Only the holder can release it
+		assert.Nil(t, c.registry.Release(ctx, "ROOM", "b"))
+		owner, _ = c.registry.Owner(ctx, "ROOM")
+		assert.Equal(t, "a", owner)
+		assert.Nil(t, c.registry.Release(ctx, "ROOM", "a"))
+		owner, _ = c.registry.Owner(ctx, "ROOM")
+		assert.Equal(t, "", owner)
+
+		// Expired leases can be taken over
+		c.registry.Claim(ctx, "ROOM", "a", time.Minute)
+		c.advance(2 * time.Minute)
+		owner, _ = c.registry.Owner(ctx, "ROOM")
+		assert.Equal(t, "", owner)
+		owner, _ = c.registry.Claim(ctx, "ROOM", "b", time.Minute)
+		assert.Equal(t, "b", owner)
+	})
+}
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	forEachCluster(t, func(t *testing.T, c testCluster) {
+		ctx := context.Background()
+		received := make(chan string, 10)
+
+		unsubscribe, err := c.broker.Subscribe(ctx, "events", func(payload []byte) {
+			received <- string(payload)
+		})
+		assert.Nil(t, err)
+		other, err := c.broker.Subscribe(ctx, "other", func(payload []byte) {
+			received <- "other: " + string(payload)
+		})
+		assert.Nil(t, err)
+		defer other()
+
+		assert.Nil(t, c.broker.Publish(ctx, "events", []byte("hello")))
+		select {
+		case msg := <-received:
+			assert.Equal(t, "hello", msg)
+		case <-time.After(time.Second):
+			t.Fatal("message not delivered")
+		}
+
+		// Nothing arrives after unsubscribing
+		unsubscribe()
+		unsubscribe()
+		assert.Nil(t, c.broker.Publish(ctx, "events", []byte("late")))
+		select {
+		case msg := <-received:
+			t.Fatalf("unexpected message %q", msg)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Broker and Registry within one process. It is what a single
+// instance uses, and lets tests run several hubs against a shared cluster.
+type Memory struct {
+	mu          sync.Mutex
+	leases      map[string]lease
+	subscribers map[string]map[int]chan []byte
+	nextID      int
+	now         func() time.Time
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemory creates an in-process broker and registry
+func NewMemory() *Memory {
+	return &Memory{
+		leases:      make(map[string]lease),
+		subscribers: make(map[string]map[int]chan []byte),
+		now:         time.Now,
+	}
+}
+
+// subscriberBuffer is how many messages a slow subscriber may fall behind before messages are dropped
+const subscriberBuffer = 256
+
+// Publish delivers the payload to the topic's current subscribers
+func (m *Memory) Publish(ctx context.Context, topic string, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ch := range m.subscribers[topic] {
+		select {
+		case ch <- append([]byte(nil), payload...):
+		default:
+			// Like Redis pub/sub, delivery is best effort
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a handler for the topic
+func (m *Memory) Subscribe(ctx context.Context, topic string, handler func(payload []byte)) (func(), error) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	if m.subscribers[topic] == nil {
+		m.subscribers[topic] = make(map[int]chan []byte)
+	}
+	m.subscribers[topic][id] = ch
+	m.mu.Unlock()
+
+	go func() {
+		for payload := range ch {
+			handler(payload)
+		}
+	}()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subscribers[topic][id]; ok {
+			delete(m.subscribers[topic], id)
+			close(ch)
+		}
+	}, nil
+}
+
+// Claim takes or renews the lease on a room
+func (m *Memory) Claim(ctx context.Context, code, instance string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if l, ok := m.leases[code]; ok && l.owner != instance && now.Before(l.expires) {
+		return l.owner, nil
+	}
+	m.leases[code] = lease{owner: instance, expires: now.Add(ttl)}
+	return instance, nil
+}
+
+// Owner returns the instance holding the room's lease
+func (m *Memory) Owner(ctx context.Context, code string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.leases[code]; ok && m.now().Before(l.expires) {
+		return l.owner, nil
+	}
+	return "", nil
+}
+
+// Release gives up the instance's lease on a room
+func (m *Memory) Release(ctx context.Context, code, instance string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.leases[code]; ok && l.owner == instance {
+		delete(m.leases, code)
+	}
+	return nil
+}
+
+// Close drops all subscriptions
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for topic, subs := range m.subscribers {
+		for _, ch := range subs {
+			close(ch)
+		}
+		delete(m.subscribers, topic)
+	}
+	return nil
+}
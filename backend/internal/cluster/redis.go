@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ownerKeyPrefix namespaces room leases in Redis
+const ownerKeyPrefix = "poker:room-owner:"
+
+// claimScript takes the lease if it is free or already ours and returns the owner
+var claimScript = redis.NewScript(`
+local owner = redis.call("GET", KEYS[1])
+if not owner or owner == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return ARGV[1]
+end
+return owner
+`)
+
+// releaseScript deletes the lease only if we hold it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Redis is a Broker and Registry backed by a Redis server: leases are keys
+// with an expiry and events use Redis pub/sub
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a broker and registry using the client
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// DialRedis connects to the Redis server at url (e.g. "redis://host:6379/0")
+func DialRedis(ctx context.Context, url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return NewRedis(client), nil
+}
+
+// Publish sends the payload to the topic's subscribers on all instances
+func (r *Redis) Publish(ctx context.Context, topic string, payload []byte) error {
+	return r.client.Publish(ctx, topic, payload).Err()
+}
+
+// Subscribe registers a handler for the topic
+func (r *Redis) Subscribe(ctx context.Context, topic string, handler func(payload []byte)) (func(), error) {
+	sub := r.client.Subscribe(ctx, topic)
+	// Wait for the confirmation so no message published after we return is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			handler([]byte(msg.Payload))
+		}
+	}()
+
+	return func() { sub.Close() }, nil
+}
+
+// Claim takes or renews the lease on a room
+func (r *Redis) Claim(ctx context.Context, code, instance string, ttl time.Duration) (string, error) {
+	return claimScript.Run(ctx, r.client, []string{ownerKeyPrefix + code}, instance, ttl.Milliseconds()).Text()
+}
+
+// Owner returns the instance holding the room's lease
+func (r *Redis) Owner(ctx context.Context, code string) (string, error) {
+	owner, err := r.client.Get(ctx, ownerKeyPrefix+code).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return owner, err
+}
+
+// Release gives up the instance's lease on a room
+func (r *Redis) Release(ctx context.Context, code, instance string) error {
+	return releaseScript.Run(ctx, r.client, []string{ownerKeyPrefix + code}, instance).Err()
+}
+
+// Client returns the underlying connection, for other state shared through
+// the same Redis such as rate limits
+func (r *Redis) Client() *redis.Client {
+	return r.client
+}
+
+// Close closes the Redis connection
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDatabase is an empty database of one dialect
+type testDatabase struct {
+	conn    *sql.DB
+	dialect *Dialect
+}
+
+// forEachDatabase runs the test against SQLite and, when TEST_DATABASE_URL is
+// set, against Postgres. Each Postgres test gets its own schema.
+func forEachDatabase(t *testing.T, test func(t *testing.T, db testDatabase)) {
+	t.Run(SQLite.Name, func(t *testing.T) {
+		test(t, testDatabase{conn: openTestDB(t), dialect: SQLite})
+	})
+
+	t.Run(Postgres.Name, func(t *testing.T) {
+		databaseURL := os.Getenv("TEST_DATABASE_URL")
+		if databaseURL == "" {
+			t.Skip("TEST_DATABASE_URL not set")
+		}
+		test(t, testDatabase{conn: openTestSchema(t, databaseURL), dialect: Postgres})
+	})
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	conn, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// openTestSchema creates a throwaway schema and connects with it as the search path
+func openTestSchema(t *testing.T, databaseURL string) *sql.DB {
+	admin, err := OpenPostgres(databaseURL)
+	if err != nil {
+		t.Fatalf("connect to %s: %v", databaseURL, err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatalf("parse TEST_DATABASE_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	conn, err := OpenPostgres(u.String())
+	if err != nil {
+		t.Fatalf("connect to schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT 1 FROM rooms WHERE code = ? AND host_id = ?"
+	assert.Equal(t, query, SQLite.Rebind(query))
+	assert.Equal(t, "SELECT 1 FROM rooms WHERE code = $1 AND host_id = $2", Postgres.Rebind(query))
+}
+
+func TestTranslate(t *testing.T) {
+	ddl := "CREATE TABLE t (created_at DATETIME, expiry_hours INTEGER, name TEXT)"
+	assert.Equal(t, ddl, SQLite.translate(ddl))
+	translated := Postgres.translate(ddl)
+	assert.True(t, strings.Contains(translated, "created_at TIMESTAMPTZ"))
+	assert.True(t, strings.Contains(translated, "expiry_hours BIGINT"))
+	assert.True(t, strings.Contains(translated, "name TEXT"))
+}
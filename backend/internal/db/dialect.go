@@ -0,0 +1,67 @@
+package db
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the differences between the supported SQL databases.
+// Queries and migrations are written for SQLite and translated on the fly.
+type Dialect struct {
+	Name   string
+	Driver string
+
+	numberedParams bool              // $1, $2, ... instead of ?
+	types          map[string]string // SQLite column type -> dialect column type
+}
+
+var (
+	// SQLite is the default, file-based store
+	SQLite = &Dialect{Name: "sqlite", Driver: "sqlite"}
+
+	// Postgres is selected with DATABASE_URL
+	Postgres = &Dialect{
+		Name:           "postgres",
+		Driver:         "pgx",
+		numberedParams: true,
+		types: map[string]string{
+			"DATETIME": "TIMESTAMPTZ",
+			"INTEGER":  "BIGINT", // Millisecond timestamps overflow 32 bits
+		},
+	}
+)
+
+var columnTypePattern = regexp.MustCompile(`\b(DATETIME|INTEGER)\b`)
+
+// Rebind rewrites ? placeholders for the dialect
+func (d *Dialect) Rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// translate rewrites column types in a schema statement for the dialect
+func (d *Dialect) translate(ddl string) string {
+	if len(d.types) == 0 {
+		return ddl
+	}
+	return columnTypePattern.ReplaceAllStringFunc(ddl, func(t string) string {
+		if mapped, ok := d.types[t]; ok {
+			return mapped
+		}
+		return t
+	})
+}
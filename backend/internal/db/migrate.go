@@ -0,0 +1,351 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Migration is a numbered schema change. Up and Down may contain several
+// statements; each migration runs in its own transaction. Migrations are
+// written in SQLite syntax and translated for other dialects, so keep them to
+// the common subset of SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrations must be ordered by version. Never edit a released migration;
+// add a new one instead.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: `
+			CREATE TABLE rooms (
+				code TEXT PRIMARY KEY,
+				host_id TEXT,
+				host_token TEXT,
+				created_at DATETIME,
+				last_active DATETIME,
+				expiry_hours INTEGER,
+				scale_type TEXT,
+				timer_end_time INTEGER,
+				timer_auto_reveal BOOLEAN,
+				revealed BOOLEAN,
+				current_issue TEXT,
+				passphrase_hash TEXT,
+				host_token_expires_at INTEGER,
+				facilitator_hash TEXT,
+				persistent BOOLEAN,
+				history TEXT
+			);
+			CREATE TABLE players (
+				id TEXT PRIMARY KEY,
+				room_code TEXT,
+				name TEXT,
+				avatar TEXT,
+				has_voted BOOLEAN,
+				vote TEXT,
+				is_host BOOLEAN,
+				session_token TEXT,
+				role TEXT,
+				is_co_host BOOLEAN,
+				FOREIGN KEY(room_code) REFERENCES rooms(code) ON DELETE CASCADE
+			);`,
+		Down: `
+			DROP TABLE players;
+			DROP TABLE rooms;`,
+	},
+	{
+		Version: 2,
+		Name:    "index players by room",
+		// Players of rooms deleted while foreign keys were off are orphaned
+		Up: `
+			DELETE FROM players WHERE room_code NOT IN (SELECT code FROM rooms);
+			CREATE INDEX idx_players_room_code ON players(room_code);`,
+		Down: `DROP INDEX idx_players_room_code;`,
+	},
+	{
+		Version: 3,
+		Name:    "room issue queue",
+		Up:      `ALTER TABLE rooms ADD COLUMN queue TEXT;`,
+		Down:    `ALTER TABLE rooms DROP COLUMN queue;`,
+	},
+	{
+		Version: 4,
+		Name:    "room scale values",
+		Up:      `ALTER TABLE rooms ADD COLUMN scale TEXT;`,
+		Down:    `ALTER TABLE rooms DROP COLUMN scale;`,
+	},
+	{
+		Version: 5,
+		Name:    "room jira actions",
+		Up:      `ALTER TABLE rooms ADD COLUMN jira_actions TEXT;`,
+		Down:    `ALTER TABLE rooms DROP COLUMN jira_actions;`,
+	},
+	{
+		Version: 6,
+		Name:    "jira outbox",
+		Up: `
+			CREATE TABLE jira_outbox (
+				id TEXT PRIMARY KEY,
+				room_code TEXT,
+				status TEXT,
+				next_attempt_at INTEGER,
+				created_at INTEGER,
+				data TEXT
+			);
+			CREATE INDEX idx_jira_outbox_due ON jira_outbox(status, next_attempt_at);
+			CREATE INDEX idx_jira_outbox_room ON jira_outbox(room_code);`,
+		Down: `DROP TABLE jira_outbox;`,
+	},
+}
+
+// legacyColumns lists the columns that unversioned databases may be missing.
+// Before migrations existed, columns were added at startup with ALTER TABLE.
+var legacyColumns = map[string][]string{
+	"rooms": {
+		"current_issue TEXT",
+		"passphrase_hash TEXT",
+		"host_token_expires_at INTEGER",
+		"facilitator_hash TEXT",
+		"persistent BOOLEAN",
+		"history TEXT",
+	},
+	"players": {
+		"session_token TEXT",
+		"role TEXT",
+		"is_co_host BOOLEAN",
+	},
+}
+
+// Migrate applies all pending migrations
+func Migrate(conn *sql.DB, d *Dialect) error {
+	if err := ensureMigrationsTable(conn, d); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := runMigration(conn, d, m, true); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %d: %s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Rollback reverts up to the given number of most recently applied
+// migrations and returns how many were reverted
+func Rollback(conn *sql.DB, d *Dialect, steps int) (int, error) {
+	if err := ensureMigrationsTable(conn, d); err != nil {
+		return 0, err
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(migrations) - 1; i >= 0 && reverted < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if err := runMigration(conn, d, m, false); err != nil {
+			return reverted, err
+		}
+		log.Printf("Rolled back migration %d: %s", m.Version, m.Name)
+		reverted++
+	}
+	return reverted, nil
+}
+
+// Status lists all known migrations and when they were applied
+func Status(conn *sql.DB, d *Dialect) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(conn, d); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// runMigration applies (up) or reverts (down) a migration and records it
+func runMigration(conn *sql.DB, d *Dialect, m Migration, up bool) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	if _, err := tx.Exec(d.translate(script)); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	if up {
+		_, err = tx.Exec(d.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			m.Version, m.Name, time.Now())
+	} else {
+		_, err = tx.Exec(d.Rebind("DELETE FROM schema_migrations WHERE version = ?"), m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ensureMigrationsTable creates the schema_migrations table. SQLite databases
+// created before migrations existed are upgraded to the initial schema and
+// marked as being at version 1.
+func ensureMigrationsTable(conn *sql.DB, d *Dialect) error {
+	exists, err := tableExists(conn, d, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	legacy := false
+	if d == SQLite {
+		if legacy, err = tableExists(conn, d, "rooms"); err != nil {
+			return err
+		}
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(d.translate(`
+		CREATE TABLE schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT,
+			applied_at DATETIME
+		);
+	`))
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		if err := upgradeLegacySchema(tx); err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migrations[0].Version, migrations[0].Name, time.Now())
+		if err != nil {
+			return err
+		}
+		log.Println("Adopted unversioned database at migration 1")
+	}
+
+	return tx.Commit()
+}
+
+// upgradeLegacySchema adds the columns an unversioned database is missing
+func upgradeLegacySchema(tx *sql.Tx) error {
+	for table, columns := range legacyColumns {
+		existing, err := columnNames(tx, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			if existing[strings.Fields(column)[0]] {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appliedVersions returns the applied migration versions and their timestamps
+func appliedVersions(conn *sql.DB) (map[int]time.Time, error) {
+	rows, err := conn.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func tableExists(conn *sql.DB, d *Dialect, table string) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)"
+	if d == Postgres {
+		query = d.Rebind(`SELECT EXISTS(SELECT 1 FROM information_schema.tables
+			WHERE table_schema = current_schema() AND table_name = ?)`)
+	}
+
+	var exists bool
+	err := conn.QueryRow(query, table).Scan(&exists)
+	return exists, err
+}
+
+func columnNames(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
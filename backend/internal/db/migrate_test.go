@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	forEachDatabase(t, testMigrateFreshDatabase)
+}
+
+func testMigrateFreshDatabase(t *testing.T, db testDatabase) {
+	conn := db.conn
+
+	assert.Nil(t, Migrate(conn, db.dialect))
+	// Running again is a no-op
+	assert.Nil(t, Migrate(conn, db.dialect))
+
+	status, err := Status(conn, db.dialect)
+	assert.Nil(t, err)
+	assert.Len(t, status, len(migrations))
+	for _, s := range status {
+		assert.NotNil(t, s.AppliedAt, "migration %d", s.Version)
+	}
+
+	// Foreign keys are enforced, so players go with their room
+	_, err = conn.Exec("INSERT INTO players (id, room_code) VALUES ('p1', 'NOPE')")
+	assert.NotNil(t, err)
+	_, err = conn.Exec("INSERT INTO rooms (code) VALUES ('ROOM')")
+	assert.Nil(t, err)
+	_, err = conn.Exec("INSERT INTO players (id, room_code) VALUES ('p1', 'ROOM')")
+	assert.Nil(t, err)
+	_, err = conn.Exec("DELETE FROM rooms WHERE code = 'ROOM'")
+	assert.Nil(t, err)
+	var players int
+	conn.QueryRow("SELECT COUNT(*) FROM players").Scan(&players)
+	assert.Equal(t, 0, players)
+}
+
+func TestMigrate_Rollback(t *testing.T) {
+	forEachDatabase(t, testMigrateRollback)
+}
+
+func testMigrateRollback(t *testing.T, db testDatabase) {
+	conn := db.conn
+	assert.Nil(t, Migrate(conn, db.dialect))
+
+	reverted, err := Rollback(conn, db.dialect, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, reverted)
+	status, _ := Status(conn, db.dialect)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.Nil(t, status[len(status)-1].AppliedAt)
+
+	// Rolling back everything drops the schema
+	reverted, err = Rollback(conn, db.dialect, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(migrations)-1, reverted)
+	exists, err := tableExists(conn, db.dialect, "rooms")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	assert.Nil(t, Migrate(conn, db.dialect))
+	exists, _ = tableExists(conn, db.dialect, "rooms")
+	assert.True(t, exists)
+}
+
+func TestMigrate_LegacyDatabase(t *testing.T) {
+	conn := openTestDB(t)
+
+	// Schema as created before migrations existed
+	_, err := conn.Exec(`
+		CREATE TABLE rooms (
+			code TEXT PRIMARY KEY, host_id TEXT, host_token TEXT, created_at DATETIME,
+			last_active DATETIME, expiry_hours INTEGER, scale_type TEXT, timer_end_time INTEGER,
+			timer_auto_reveal BOOLEAN, revealed BOOLEAN, current_issue TEXT
+		);
+		CREATE TABLE players (
+			id TEXT PRIMARY KEY, room_code TEXT, name TEXT, avatar TEXT, has_voted BOOLEAN,
+			vote TEXT, is_host BOOLEAN,
+			FOREIGN KEY(room_code) REFERENCES rooms(code) ON DELETE CASCADE
+		);
+		INSERT INTO rooms (code, host_token) VALUES ('KEEP', 'legacy-token');
+		INSERT INTO players (id, room_code, name) VALUES ('p1', 'KEEP', 'Ada');
+	`)
+	assert.Nil(t, err)
+	// Orphans left behind while foreign keys were off
+	_, err = conn.Exec("PRAGMA foreign_keys = OFF; INSERT INTO players (id, room_code) VALUES ('p2', 'GONE'); PRAGMA foreign_keys = ON;")
+	assert.Nil(t, err)
+
+	assert.Nil(t, Migrate(conn, SQLite))
+
+	var hostToken, name string
+	var persistent sql.NullBool
+	err = conn.QueryRow("SELECT host_token, persistent FROM rooms WHERE code = 'KEEP'").Scan(&hostToken, &persistent)
+	assert.Nil(t, err)
+	assert.Equal(t, "legacy-token", hostToken)
+	err = conn.QueryRow("SELECT name FROM players WHERE role IS NULL AND id = 'p1'").Scan(&name)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ada", name)
+
+	var orphans int
+	conn.QueryRow("SELECT COUNT(*) FROM players WHERE id = 'p2'").Scan(&orphans)
+	assert.Equal(t, 0, orphans)
+}
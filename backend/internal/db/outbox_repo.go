@@ -0,0 +1,131 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/poker/backend/internal/jira"
+)
+
+// OutboxRepo stores Jira write-backs waiting to be done
+type OutboxRepo struct {
+	db      *sql.DB
+	dialect *Dialect
+}
+
+// NewOutboxRepo creates an outbox repository for the given SQL dialect
+func NewOutboxRepo(db *sql.DB, d *Dialect) *OutboxRepo {
+	return &OutboxRepo{db: db, dialect: d}
+}
+
+// SaveWrite inserts or updates a write. The columns hold what writes are
+// looked up by; the write itself is stored as JSON.
+func (r *OutboxRepo) SaveWrite(w *jira.Write) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(r.dialect.Rebind(`
+		INSERT INTO jira_outbox (id, room_code, status, next_attempt_at, created_at, data)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status = excluded.status,
+			next_attempt_at = excluded.next_attempt_at,
+			data = excluded.data
+	`), w.ID, w.Room, string(w.Status), w.NextAttemptAt.UnixMilli(), w.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+// DeleteWrite removes a write
+func (r *OutboxRepo) DeleteWrite(id string) error {
+	_, err := r.db.Exec(r.dialect.Rebind("DELETE FROM jira_outbox WHERE id = ?"), id)
+	return err
+}
+
+// GetWrite loads a write, or nil if it is not stored
+func (r *OutboxRepo) GetWrite(id string) (*jira.Write, error) {
+	writes, err := r.queryWrites("SELECT next_attempt_at, data FROM jira_outbox WHERE id = ?", id)
+	if err != nil || len(writes) == 0 {
+		return nil, err
+	}
+	return writes[0], nil
+}
+
+// ClaimWrites returns up to limit pending writes due by now and pushes their
+// next attempt back by lease. A write another instance claimed first is
+// skipped: the update only matches while the attempt time is unchanged.
+func (r *OutboxRepo) ClaimWrites(now time.Time, lease time.Duration, limit int) ([]*jira.Write, error) {
+	due, err := r.queryWrites(`
+		SELECT next_attempt_at, data FROM jira_outbox
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at LIMIT ?
+	`, string(jira.WritePending), now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, w := range due {
+		ok, err := r.ClaimWrite(w, now, lease)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			claimed = append(claimed, w)
+		}
+	}
+	return claimed, nil
+}
+
+// ClaimWrite pushes a loaded write's next attempt back by lease. It fails if
+// the write was claimed or updated since it was loaded: the update only
+// matches while the attempt time is unchanged.
+func (r *OutboxRepo) ClaimWrite(w *jira.Write, now time.Time, lease time.Duration) (bool, error) {
+	leaseEnd := now.Add(lease)
+	result, err := r.db.Exec(r.dialect.Rebind(`
+		UPDATE jira_outbox SET next_attempt_at = ? WHERE id = ? AND next_attempt_at = ?
+	`), leaseEnd.UnixMilli(), w.ID, w.NextAttemptAt.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n != 1 {
+		return false, nil
+	}
+	w.NextAttemptAt = leaseEnd
+	return true, nil
+}
+
+// ListWrites returns the pending and failed writes of a room, oldest first
+func (r *OutboxRepo) ListWrites(room string) ([]*jira.Write, error) {
+	return r.queryWrites(`
+		SELECT next_attempt_at, data FROM jira_outbox
+		WHERE room_code = ? AND status <> ?
+		ORDER BY created_at
+	`, room, string(jira.WriteDone))
+}
+
+func (r *OutboxRepo) queryWrites(query string, args ...interface{}) ([]*jira.Write, error) {
+	rows, err := r.db.Query(r.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var writes []*jira.Write
+	for rows.Next() {
+		var nextAttemptAt int64
+		var data string
+		if err := rows.Scan(&nextAttemptAt, &data); err != nil {
+			return nil, err
+		}
+		var w jira.Write
+		if err := json.Unmarshal([]byte(data), &w); err != nil {
+			return nil, err
+		}
+		// Claims move the attempt time without rewriting the JSON
+		w.NextAttemptAt = time.UnixMilli(nextAttemptAt)
+		writes = append(writes, &w)
+	}
+	return writes, rows.Err()
+}
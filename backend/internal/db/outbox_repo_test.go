@@ -0,0 +1,86 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/poker/backend/internal/jira"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRepo(t *testing.T) {
+	forEachDatabase(t, testOutboxRepo)
+}
+
+func testOutboxRepo(t *testing.T, db testDatabase) {
+	assert.Nil(t, Migrate(db.conn, db.dialect))
+	repo := NewOutboxRepo(db.conn, db.dialect)
+
+	now := time.UnixMilli(time.Now().UnixMilli())
+	due := &jira.Write{
+		ID:            "w1",
+		Room:          "PAYMENTS",
+		IssueKey:      "PAY-1",
+		Estimate:      jira.PointsEstimate(5),
+		Actions:       jira.Actions{Comment: true, Label: "estimated"},
+		Votes:         jira.Votes{{Name: "Ada", Vote: "5"}},
+		Status:        jira.WritePending,
+		Done:          []string{jira.StepEstimate},
+		Attempts:      2,
+		NextAttemptAt: now.Add(-time.Second),
+		CreatedAt:     now.Add(-time.Minute),
+	}
+	later := &jira.Write{ID: "w2", Room: "PAYMENTS", IssueKey: "PAY-2", Status: jira.WritePending, NextAttemptAt: now.Add(time.Minute), CreatedAt: now}
+	failed := &jira.Write{ID: "w3", Room: "OTHER", IssueKey: "PAY-3", Status: jira.WriteFailed, NextAttemptAt: now.Add(-time.Minute), CreatedAt: now}
+	for _, w := range []*jira.Write{due, later, failed} {
+		assert.Nil(t, repo.SaveWrite(w))
+	}
+
+	loaded, err := repo.GetWrite("w1")
+	assert.Nil(t, err)
+	if assert.NotNil(t, loaded) {
+		assert.Equal(t, 5.0, *loaded.Estimate.Points)
+		assert.Equal(t, []string{jira.StepEstimate}, loaded.Done)
+		assert.Equal(t, "estimated", loaded.Actions.Label)
+		assert.True(t, due.NextAttemptAt.Equal(loaded.NextAttemptAt))
+	}
+	missing, err := repo.GetWrite("nope")
+	assert.Nil(t, err)
+	assert.Nil(t, missing)
+
+	writes, err := repo.ListWrites("PAYMENTS")
+	assert.Nil(t, err)
+	if assert.Len(t, writes, 2) {
+		assert.Equal(t, "w1", writes[0].ID)
+		assert.Equal(t, "w2", writes[1].ID)
+	}
+
+	// Only pending writes that are due are claimed, and only once per lease
+	claimed, err := repo.ClaimWrites(now, time.Minute, 10)
+	assert.Nil(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, "w1", claimed[0].ID)
+		assert.True(t, now.Add(time.Minute).Equal(claimed[0].NextAttemptAt))
+	}
+	claimed, err = repo.ClaimWrites(now, time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, claimed)
+	claimed, err = repo.ClaimWrites(now.Add(2*time.Minute), time.Minute, 1)
+	assert.Nil(t, err)
+	assert.Len(t, claimed, 1)
+
+	// A write is claimed by one of those who loaded it
+	first, _ := repo.GetWrite("w3")
+	second, _ := repo.GetWrite("w3")
+	ok, err := repo.ClaimWrite(first, now, time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, now.Add(time.Minute).Equal(first.NextAttemptAt))
+	ok, err = repo.ClaimWrite(second, now, time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	assert.Nil(t, repo.DeleteWrite("w1"))
+	writes, _ = repo.ListWrites("PAYMENTS")
+	assert.Len(t, writes, 1)
+}
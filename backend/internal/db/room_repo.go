@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
+	"reflect"
 	"time"
 
 	"github.com/poker/backend/internal/game"
@@ -11,15 +13,22 @@ import (
 
 // RoomRepo handles room persistence
 type RoomRepo struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect *Dialect
 }
 
+// NewRoomRepo creates a repository backed by SQLite
 func NewRoomRepo(db *sql.DB) *RoomRepo {
-	return &RoomRepo{db: db}
+	return NewRoomRepoWithDialect(db, SQLite)
+}
+
+// NewRoomRepoWithDialect creates a repository for the given SQL dialect
+func NewRoomRepoWithDialect(db *sql.DB, d *Dialect) *RoomRepo {
+	return &RoomRepo{db: db, dialect: d}
 }
 
 // SaveRoom saves the room and its players
-func (r *RoomRepo) SaveRoom(room *game.Room) error {
+func (r *RoomRepo) SaveRoom(room *game.RoomSnapshot) error {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return err
@@ -27,55 +36,95 @@ func (r *RoomRepo) SaveRoom(room *game.Room) error {
 	defer tx.Rollback()
 
 	// 1. Save Room
-	var timerEndTime *int64
-	if room.TimerEndTime != nil {
-		ts := room.TimerEndTime.UnixMilli()
-		timerEndTime = &ts
+	var currentIssueJSON, queueJSON, historyJSON, scaleJSON, actionsJSON *string
+	if !reflect.DeepEqual(room.Scale, models.PresetScales[room.Scale.Type]) {
+		if scaleJSON, err = marshalJSON(room.Scale); err != nil {
+			return err
+		}
+	}
+	if room.JiraActions.Any() {
+		if actionsJSON, err = marshalJSON(room.JiraActions); err != nil {
+			return err
+		}
 	}
-
-	// Serialize CurrentIssue
-	var currentIssueJSON *string
 	if room.CurrentIssue != nil {
-		b, err := json.Marshal(room.CurrentIssue)
-		if err == nil {
-			s := string(b)
-			currentIssueJSON = &s
+		if currentIssueJSON, err = marshalJSON(room.CurrentIssue); err != nil {
+			return err
+		}
+	}
+	if len(room.Queue) > 0 {
+		if queueJSON, err = marshalJSON(room.Queue); err != nil {
+			return err
+		}
+	}
+	if len(room.History) > 0 {
+		if historyJSON, err = marshalJSON(room.History); err != nil {
+			return err
 		}
 	}
 
-	_, err = tx.Exec(`
-		INSERT OR REPLACE INTO rooms (
+	_, err = tx.Exec(r.dialect.Rebind(`
+		INSERT INTO rooms (
 			code, host_id, host_token, created_at, last_active, expiry_hours, 
-			scale_type, timer_end_time, timer_auto_reveal, revealed, current_issue
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`,
+			scale_type, timer_end_time, timer_auto_reveal, revealed, current_issue,
+			passphrase_hash, host_token_expires_at, facilitator_hash, persistent, history, queue, scale,
+			jira_actions
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(code) DO UPDATE SET
+			host_id = excluded.host_id,
+			host_token = excluded.host_token,
+			created_at = excluded.created_at,
+			last_active = excluded.last_active,
+			expiry_hours = excluded.expiry_hours,
+			scale_type = excluded.scale_type,
+			timer_end_time = excluded.timer_end_time,
+			timer_auto_reveal = excluded.timer_auto_reveal,
+			revealed = excluded.revealed,
+			current_issue = excluded.current_issue,
+			passphrase_hash = excluded.passphrase_hash,
+			host_token_expires_at = excluded.host_token_expires_at,
+			facilitator_hash = excluded.facilitator_hash,
+			persistent = excluded.persistent,
+			history = excluded.history,
+			queue = excluded.queue,
+			scale = excluded.scale,
+			jira_actions = excluded.jira_actions
+	`),
 		room.Code,
 		room.HostID,
-		room.HostToken,
+		room.HostTokenHash,
 		room.CreatedAt,
 		room.LastActive,
 		room.ExpiryHours,
 		room.Scale.Type,
-		timerEndTime,
+		unixMilli(room.TimerEndTime),
 		room.TimerAutoReveal,
 		room.Revealed,
 		currentIssueJSON,
+		room.PassphraseHash,
+		unixMilli(room.HostTokenExpiry),
+		room.FacilitatorHash,
+		room.Persistent,
+		historyJSON,
+		queueJSON,
+		scaleJSON,
+		actionsJSON,
 	)
 	if err != nil {
 		return err
 	}
 
 	// 2. Save Players (simpler to delete all for this room and re-insert given the small number)
-	_, err = tx.Exec("DELETE FROM players WHERE room_code = ?", room.Code)
+	_, err = tx.Exec(r.dialect.Rebind("DELETE FROM players WHERE room_code = ?"), room.Code)
 	if err != nil {
 		return err
 	}
 
 	for _, p := range room.Players {
-		_, err = tx.Exec(`
-			INSERT INTO players (id, room_code, name, avatar, has_voted, vote, is_host)
-			VALUES (?, ?, ?, ?, ?, ?, ?)
-		`,
+		_, err = tx.Exec(r.dialect.Rebind(`
+			INSERT INTO players (id, room_code, name, avatar, has_voted, vote, is_host, session_token, role, is_co_host)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`),
 			p.ID,
 			room.Code,
 			p.Name,
@@ -83,6 +132,9 @@ func (r *RoomRepo) SaveRoom(room *game.Room) error {
 			p.HasVoted,
 			p.Vote,
 			p.IsHost,
+			p.SessionHash,
+			p.Role,
+			p.IsCoHost,
 		)
 		if err != nil {
 			return err
@@ -92,34 +144,42 @@ func (r *RoomRepo) SaveRoom(room *game.Room) error {
 	return tx.Commit()
 }
 
-// GetRoom loads a room and its players
-func (r *RoomRepo) GetRoom(code string) (*game.Room, error) {
+// GetRoom loads a room and its players, or nil if the room is not stored
+func (r *RoomRepo) GetRoom(code string) (*game.RoomSnapshot, error) {
 	// 1. Get Room
-	var hostID, hostToken string
-	var createdAt, lastActive time.Time
-	var expiryHours int
+	room := &game.RoomSnapshot{Code: code}
 	var scaleType string
-	var timerEndTime *int64
-	var timerAutoReveal, revealed bool
-	var currentIssueJSON sql.NullString
+	var timerEndTime, hostTokenExpiresAt *int64
+	var currentIssueJSON, passphraseHash, facilitatorHash, historyJSON, queueJSON, scaleJSON, actionsJSON sql.NullString
+	var persistent sql.NullBool
 
-	row := r.db.QueryRow(`
+	row := r.db.QueryRow(r.dialect.Rebind(`
 		SELECT host_id, host_token, created_at, last_active, expiry_hours, 
-		       scale_type, timer_end_time, timer_auto_reveal, revealed, current_issue
+		       scale_type, timer_end_time, timer_auto_reveal, revealed, current_issue,
+		       passphrase_hash, host_token_expires_at, facilitator_hash, persistent, history, queue, scale,
+		       jira_actions
 		FROM rooms WHERE code = ?
-	`, code)
+	`), code)
 
 	err := row.Scan(
-		&hostID,
-		&hostToken,
-		&createdAt,
-		&lastActive,
-		&expiryHours,
+		&room.HostID,
+		&room.HostTokenHash,
+		&room.CreatedAt,
+		&room.LastActive,
+		&room.ExpiryHours,
 		&scaleType,
 		&timerEndTime,
-		&timerAutoReveal,
-		&revealed,
+		&room.TimerAutoReveal,
+		&room.Revealed,
 		&currentIssueJSON,
+		&passphraseHash,
+		&hostTokenExpiresAt,
+		&facilitatorHash,
+		&persistent,
+		&historyJSON,
+		&queueJSON,
+		&scaleJSON,
+		&actionsJSON,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil // Not found
@@ -128,81 +188,152 @@ func (r *RoomRepo) GetRoom(code string) (*game.Room, error) {
 		return nil, err
 	}
 
-	// Restore complex fields
-	var scale *models.VotingScale
-	if s, ok := models.PresetScales[models.VotingScaleType(scaleType)]; ok {
-		scale = &s
-	} else {
-		def := models.PresetScales[models.ScaleFibonacci]
-		scale = &def
-	}
-
-	var tEndTime *time.Time
-	if timerEndTime != nil {
-		t := time.UnixMilli(*timerEndTime)
-		tEndTime = &t
+	// Preset scales are stored by type and resolved by the game; custom
+	// scales and point mappings are stored whole
+	room.Scale.Type = models.VotingScaleType(scaleType)
+	if scaleJSON.Valid && scaleJSON.String != "" {
+		var scale models.VotingScale
+		err := json.Unmarshal([]byte(scaleJSON.String), &scale)
+		if err == nil {
+			err = scale.Validate()
+		}
+		if err != nil {
+			// Keep the room usable with the preset of its type, if it has one
+			if _, ok := models.PresetScales[room.Scale.Type]; !ok {
+				room.Scale.Type = models.ScaleFibonacci
+			}
+			log.Printf("⚠️  Invalid scale stored for room %s, using the %s preset: %v", room.Code, room.Scale.Type, err)
+			scale = models.PresetScales[room.Scale.Type].Clone()
+		}
+		room.Scale = scale
 	}
-
-	var currentIssue *models.JiraIssue
+	room.TimerEndTime = fromUnixMilli(timerEndTime)
+	room.HostTokenExpiry = fromUnixMilli(hostTokenExpiresAt)
+	room.PassphraseHash = passphraseHash.String
+	room.FacilitatorHash = facilitatorHash.String
+	room.Persistent = persistent.Bool
 	if currentIssueJSON.Valid && currentIssueJSON.String != "" {
 		var issue models.JiraIssue
 		if err := json.Unmarshal([]byte(currentIssueJSON.String), &issue); err == nil {
-			currentIssue = &issue
+			room.CurrentIssue = &issue
+		}
+	}
+	if actionsJSON.Valid && actionsJSON.String != "" {
+		json.Unmarshal([]byte(actionsJSON.String), &room.JiraActions)
+	}
+	if queueJSON.Valid && queueJSON.String != "" {
+		var queue []models.JiraIssue
+		if err := json.Unmarshal([]byte(queueJSON.String), &queue); err == nil {
+			room.Queue = queue
+		}
+	}
+	if historyJSON.Valid && historyJSON.String != "" {
+		var history []models.RoundResult
+		if err := json.Unmarshal([]byte(historyJSON.String), &history); err == nil {
+			room.History = history
 		}
 	}
-
-	room := game.RestoreRoom(
-		code, hostID, hostToken, createdAt, lastActive, expiryHours,
-		scale, tEndTime, timerAutoReveal, revealed, currentIssue,
-	)
 
 	// 2. Get Players
-	rows, err := r.db.Query("SELECT id, name, avatar, has_voted, vote, is_host FROM players WHERE room_code = ?", code)
+	rows, err := r.db.Query(r.dialect.Rebind(`
+		SELECT id, name, avatar, has_voted, vote, is_host, session_token, role, is_co_host
+		FROM players WHERE room_code = ? ORDER BY id
+	`), code)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	room.Players = []game.PlayerSnapshot{}
 	for rows.Next() {
-		var p game.Player
-		// Note: Conn is nil for restored players until they reconnect
-		err := rows.Scan(&p.ID, &p.Name, &p.Avatar, &p.HasVoted, &p.Vote, &p.IsHost)
+		var p game.PlayerSnapshot
+		var sessionToken, role sql.NullString
+		var isCoHost sql.NullBool
+		err := rows.Scan(&p.ID, &p.Name, &p.Avatar, &p.HasVoted, &p.Vote, &p.IsHost, &sessionToken, &role, &isCoHost)
 		if err != nil {
 			return nil, err
 		}
-		room.RestorePlayer(&p)
+		p.SessionHash = sessionToken.String
+		p.Role = models.PlayerRole(role.String)
+		p.IsCoHost = isCoHost.Bool
+		room.Players = append(room.Players, p)
 	}
 
-	return room, nil
+	return room, rows.Err()
+}
+
+// RoomExists checks whether a room code is stored
+func (r *RoomRepo) RoomExists(code string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(r.dialect.Rebind("SELECT EXISTS(SELECT 1 FROM rooms WHERE code = ?)"), code).Scan(&exists)
+	return exists, err
 }
 
-// GetAllRooms loads all rooms (for startup)
-func (r *RoomRepo) GetAllRooms() ([]*game.Room, error) {
-	rows, err := r.db.Query("SELECT code FROM rooms")
+// DeleteExpiredRooms deletes stored rooms whose expiry has passed. Persistent
+// rooms never expire.
+func (r *RoomRepo) DeleteExpiredRooms(now time.Time) (int, error) {
+	rows, err := r.db.Query(`
+		SELECT code, last_active, expiry_hours
+		FROM rooms WHERE persistent IS NOT TRUE
+	`)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
 
-	var rooms []*game.Room
+	var expired []string
 	for rows.Next() {
 		var code string
-		if err := rows.Scan(&code); err != nil {
-			return nil, err
+		var lastActive time.Time
+		var expiryHours int
+		if err := rows.Scan(&code, &lastActive, &expiryHours); err != nil {
+			rows.Close()
+			return 0, err
 		}
-		room, err := r.GetRoom(code)
-		if err != nil {
-			continue
+		if now.Sub(lastActive) > time.Duration(expiryHours)*time.Hour {
+			expired = append(expired, code)
 		}
-		if room != nil {
-			rooms = append(rooms, room)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, code := range expired {
+		if err := r.DeleteRoom(code); err != nil {
+			return 0, err
 		}
 	}
-	return rooms, nil
+	return len(expired), nil
 }
 
 // DeleteRoom deletes a room
 func (r *RoomRepo) DeleteRoom(code string) error {
-	_, err := r.db.Exec("DELETE FROM rooms WHERE code = ?", code)
+	_, err := r.db.Exec(r.dialect.Rebind("DELETE FROM rooms WHERE code = ?"), code)
 	return err
 }
+
+func marshalJSON(v any) (*string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	s := string(b)
+	return &s, nil
+}
+
+// unixMilli converts an optional time to the millisecond timestamps stored in INTEGER columns
+func unixMilli(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
+
+func fromUnixMilli(ms *int64) *time.Time {
+	if ms == nil {
+		return nil
+	}
+	t := time.UnixMilli(*ms)
+	return &t
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/poker/backend/internal/game"
+	"github.com/poker/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRepo migrates the database and returns a repository for it
+func newTestRepo(t *testing.T, db testDatabase) *RoomRepo {
+	assert.Nil(t, Migrate(db.conn, db.dialect))
+	return NewRoomRepoWithDialect(db.conn, db.dialect)
+}
+
+func TestRoomRepo_SaveAndLoad(t *testing.T) {
+	forEachDatabase(t, testRoomRepoSaveAndLoad)
+}
+
+func testRoomRepoSaveAndLoad(t *testing.T, db testDatabase) {
+	repo := newTestRepo(t, db)
+
+	room := game.NewRoomWithScale("PAYMENTS", 24, models.ScaleTShirt)
+	room.Persistent = true
+	room.SetPassphrase("sprint")
+	hostToken, _ := room.IssueHostToken(time.Hour)
+	p := game.NewPlayer("p1", "Ada", "", nil, false)
+	room.AddPlayer(p)
+	room.SetIssue(p.ID, &models.JiraIssue{Key: "PAY-1", Summary: "Checkout"})
+	room.SetJiraActions(models.JiraActions{Comment: true, Label: "estimated"})
+	room.Vote(p.ID, "M")
+	room.Reveal(p.ID)
+
+	assert.Nil(t, repo.SaveRoom(room.Snapshot()))
+	// Saving again updates in place without losing players
+	assert.Nil(t, repo.SaveRoom(room.Snapshot()))
+
+	exists, err := repo.RoomExists("PAYMENTS")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	snapshot, err := repo.GetRoom("PAYMENTS")
+	assert.Nil(t, err)
+	if !assert.NotNil(t, snapshot) {
+		return
+	}
+	assert.Len(t, snapshot.Players, 1)
+	loaded := game.RoomFromSnapshot(snapshot)
+	assert.True(t, loaded.Persistent)
+	assert.Equal(t, models.ScaleTShirt, loaded.Scale.Type)
+	assert.True(t, loaded.CheckPassphrase("sprint"))
+	assert.True(t, loaded.CheckHostToken(hostToken))
+	assert.NotNil(t, loaded.HostTokenExpiry)
+	assert.Equal(t, "PAY-1", loaded.CurrentIssue.Key)
+	assert.Equal(t, models.JiraActions{Comment: true, Label: "estimated"}, loaded.GetJiraActions())
+	assert.Len(t, loaded.History, 1)
+	assert.Equal(t, models.RoundVote{Name: "Ada", Vote: "M"}, loaded.History[0].Votes["p1"])
+	assert.Equal(t, 1, loaded.PlayerCount())
+	assert.Empty(t, loaded.GetPlayer("p1").SessionToken)
+	resumed, _ := loaded.ResumePlayer(p.SessionToken, nil)
+	assert.Equal(t, "p1", resumed.ID)
+
+	// Deleting a room removes its players
+	assert.Nil(t, repo.DeleteRoom("PAYMENTS"))
+	snapshot, err = repo.GetRoom("PAYMENTS")
+	assert.Nil(t, err)
+	assert.Nil(t, snapshot)
+	var players int
+	db.conn.QueryRow("SELECT COUNT(*) FROM players").Scan(&players)
+	assert.Equal(t, 0, players)
+}
+
+func TestRoomRepo_CustomScale(t *testing.T) {
+	forEachDatabase(t, testRoomRepoCustomScale)
+}
+
+func testRoomRepoCustomScale(t *testing.T, db testDatabase) {
+	repo := newTestRepo(t, db)
+
+	scale := models.VotingScale{Type: models.ScaleCustom, Name: "Custom", Values: []string{"tiny", "big"}, Points: map[string]float64{"tiny": 1, "big": 8}}
+	room := game.NewRoom("CUSTOM", 24)
+	room.SetScale(&scale)
+	assert.Nil(t, repo.SaveRoom(room.Snapshot()))
+	preset := game.NewRoomWithScale("PRESET", 24, models.ScaleTShirt)
+	assert.Nil(t, repo.SaveRoom(preset.Snapshot()))
+
+	snapshot, err := repo.GetRoom("CUSTOM")
+	assert.Nil(t, err)
+	assert.Equal(t, scale, *game.RoomFromSnapshot(snapshot).Scale)
+
+	// Presets are stored by type only
+	var stored *string
+	db.conn.QueryRow("SELECT scale FROM rooms WHERE code = 'PRESET'").Scan(&stored)
+	assert.Nil(t, stored)
+	snapshot, err = repo.GetRoom("PRESET")
+	assert.Nil(t, err)
+	assert.Equal(t, models.PresetScales[models.ScaleTShirt], *game.RoomFromSnapshot(snapshot).Scale)
+
+	// A scale that cannot be used falls back to a preset
+	db.conn.Exec(`UPDATE rooms SET scale = '{"type":"custom","values":[]}' WHERE code = 'CUSTOM'`)
+	snapshot, err = repo.GetRoom("CUSTOM")
+	assert.Nil(t, err)
+	assert.Equal(t, models.PresetScales[models.ScaleFibonacci], snapshot.Scale)
+	db.conn.Exec(`UPDATE rooms SET scale = '{"type":' WHERE code = 'PRESET'`)
+	snapshot, err = repo.GetRoom("PRESET")
+	assert.Nil(t, err)
+	assert.Equal(t, models.PresetScales[models.ScaleTShirt], snapshot.Scale)
+}
+
+func TestRoomRepo_DeleteExpiredRooms(t *testing.T) {
+	forEachDatabase(t, testRoomRepoDeleteExpiredRooms)
+}
+
+func testRoomRepoDeleteExpiredRooms(t *testing.T, db testDatabase) {
+	repo := newTestRepo(t, db)
+
+	old := game.NewRoom("OLD", 1)
+	old.LastActive = time.Now().Add(-2 * time.Hour)
+	team := game.NewRoom("TEAM", 1)
+	team.Persistent = true
+	team.LastActive = old.LastActive
+	fresh := game.NewRoom("FRESH", 1)
+	for _, r := range []*game.Room{old, team, fresh} {
+		assert.Nil(t, repo.SaveRoom(r.Snapshot()))
+	}
+
+	n, err := repo.DeleteExpiredRooms(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	for code, want := range map[string]bool{"OLD": false, "TEAM": true, "FRESH": true} {
+		exists, _ := repo.RoomExists(code)
+		assert.Equal(t, want, exists, code)
+	}
+}
+
+func TestRoomRepo_ImportExistingRoom(t *testing.T) {
+	forEachDatabase(t, testRoomRepoImportExistingRoom)
+}
+
+func testRoomRepoImportExistingRoom(t *testing.T, db testDatabase) {
+	repo := newTestRepo(t, db)
+	hub := game.NewHub(24, repo)
+	defer hub.Stop()
+
+	room := hub.CreateRoom(24)
+	p := game.NewPlayer("p1", "Ada", "", nil, false)
+	room.AddPlayer(p)
+	room.Vote(p.ID, "5")
+	room.Reveal(p.ID)
+	hub.SaveRoom(room)
+
+	// A copy of a room that still exists is stored next to it
+	copied, err := hub.ImportRoom(room.Export(), "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	snapshot, err := repo.GetRoom(copied.Code)
+	assert.Nil(t, err)
+	if assert.NotNil(t, snapshot) && assert.Len(t, snapshot.Players, 1) {
+		assert.NotEqual(t, "p1", snapshot.Players[0].ID)
+		assert.Equal(t, snapshot.Players[0].ID, snapshot.HostID)
+		assert.Contains(t, snapshot.History[0].Votes, snapshot.Players[0].ID)
+	}
+
+	original, err := repo.GetRoom(room.Code)
+	assert.Nil(t, err)
+	if assert.NotNil(t, original) {
+		assert.Equal(t, "p1", original.Players[0].ID)
+	}
+}
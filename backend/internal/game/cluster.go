@@ -0,0 +1,306 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/poker/backend/internal/models"
+)
+
+const (
+	// DefaultLeaseTTL is how long an instance owns a room without renewing
+	// its lease; leases are renewed at a third of it
+	DefaultLeaseTTL = 30 * time.Second
+
+	// roomEventsTopic is the broker topic room events are published on
+	roomEventsTopic = "poker:room-events"
+
+	// clusterTimeout bounds each call to the registry or broker
+	clusterTimeout = 2 * time.Second
+)
+
+// EventBroadcast sends a server message (the event data) to the room's players
+const EventBroadcast = "broadcast"
+
+var (
+	// ErrRoomNotFound is returned when dispatching an event to a room that exists nowhere
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrNoBroker is returned when an event for another instance's room cannot be sent
+	ErrNoBroker = errors.New("no broker configured for room events")
+)
+
+// RoomEvent is a change to a room that may be raised on any instance and is
+// applied by the instance that owns the room
+type RoomEvent struct {
+	Room string          `json:"room"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// RoomEventHandler applies an event to a room owned by this instance
+type RoomEventHandler func(room *Room, data json.RawMessage)
+
+// HandleEvent registers the handler for an event type. Register handlers
+// before the hub serves requests.
+func (h *Hub) HandleEvent(eventType string, handler RoomEventHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.eventHandlers[eventType] = handler
+}
+
+// Dispatch applies the event to the room, here if this instance owns the room
+// (or may load it), otherwise by publishing it to the owner
+func (h *Hub) Dispatch(event RoomEvent) error {
+	event.Room = strings.ToUpper(event.Room)
+
+	if _, local := h.Owner(event.Room); local {
+		room := h.GetRoom(event.Room)
+		if room == nil {
+			return ErrRoomNotFound
+		}
+		h.applyEvent(room, event)
+		return nil
+	}
+
+	if h.broker == nil {
+		return ErrNoBroker
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	return h.broker.Publish(ctx, roomEventsTopic, payload)
+}
+
+// Broadcast sends a message to the players of a room on whichever instance owns it
+func (h *Hub) Broadcast(code string, msg *models.ServerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return h.Dispatch(RoomEvent{Room: code, Type: EventBroadcast, Data: data})
+}
+
+// DispatchAll applies an event to every loaded room on every instance, for
+// changes that may concern any room. Rooms only stored in the repository
+// are left alone.
+func (h *Hub) DispatchAll(eventType string, data json.RawMessage) error {
+	event := RoomEvent{Type: eventType, Data: data}
+	if h.broker == nil {
+		for _, room := range h.loadedRooms() {
+			h.applyEvent(room, event)
+		}
+		return nil
+	}
+
+	// Every instance receives it, this one included
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	return h.broker.Publish(ctx, roomEventsTopic, payload)
+}
+
+// loadedRooms returns the rooms in memory
+func (h *Hub) loadedRooms() []*Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rooms := make([]*Room, 0, len(h.Rooms))
+	for _, room := range h.Rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func broadcastEvent(room *Room, data json.RawMessage) {
+	var msg models.ServerMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Invalid broadcast event for room %s: %v", room.Code, err)
+		return
+	}
+	room.Broadcast(&msg)
+}
+
+func (h *Hub) applyEvent(room *Room, event RoomEvent) {
+	h.mu.RLock()
+	handler := h.eventHandlers[event.Type]
+	h.mu.RUnlock()
+
+	if handler == nil {
+		log.Printf("No handler for room event %q", event.Type)
+		return
+	}
+	handler(room, event.Data)
+}
+
+// receiveEvent applies events published by other instances to rooms loaded here
+func (h *Hub) receiveEvent(payload []byte) {
+	var event RoomEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		log.Printf("Invalid room event: %v", err)
+		return
+	}
+
+	// Events for no room in particular go to all of them
+	if event.Room == "" {
+		for _, room := range h.loadedRooms() {
+			h.applyEvent(room, event)
+		}
+		return
+	}
+
+	h.mu.RLock()
+	room := h.Rooms[event.Room]
+	h.mu.RUnlock()
+	if room != nil {
+		h.applyEvent(room, event)
+	}
+}
+
+// Owner returns the instance that owns the room. local is true if that is
+// this instance, or if no instance does and this one may load the room.
+func (h *Hub) Owner(code string) (owner string, local bool) {
+	code = strings.ToUpper(code)
+	if h.registry == nil {
+		return h.instanceID, true
+	}
+
+	h.mu.RLock()
+	_, loaded := h.Rooms[code]
+	h.mu.RUnlock()
+	if loaded {
+		return h.instanceID, true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	owner, err := h.registry.Owner(ctx, code)
+	if err != nil {
+		log.Printf("Error looking up owner of room %s: %v", code, err)
+		return h.instanceID, true
+	}
+	return owner, owner == "" || owner == h.instanceID
+}
+
+// claim takes or renews this instance's lease on a room
+func (h *Hub) claim(code string) bool {
+	if h.registry == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	owner, err := h.registry.Claim(ctx, code, h.instanceID, h.leaseTTL)
+	if err != nil {
+		log.Printf("Error claiming room %s: %v", code, err)
+		return false
+	}
+	return owner == h.instanceID
+}
+
+// release gives up this instance's lease on a room
+func (h *Hub) release(code string) {
+	if h.registry == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+	defer cancel()
+	if err := h.registry.Release(ctx, code, h.instanceID); err != nil {
+		log.Printf("Error releasing room %s: %v", code, err)
+	}
+}
+
+// joinCluster subscribes to room events and starts renewing leases
+func (h *Hub) joinCluster() {
+	if h.broker != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		defer cancel()
+		unsubscribe, err := h.broker.Subscribe(ctx, roomEventsTopic, h.receiveEvent)
+		if err != nil {
+			log.Printf("⚠️  Failed to subscribe to room events: %v", err)
+		} else {
+			h.unsubscribe = unsubscribe
+		}
+	}
+
+	if h.registry != nil {
+		go h.leaseRoutine()
+	}
+}
+
+// leaveCluster stops receiving events and hands the loaded rooms back
+func (h *Hub) leaveCluster() {
+	if h.unsubscribe != nil {
+		h.unsubscribe()
+	}
+	if h.registry == nil {
+		return
+	}
+
+	for _, room := range h.loadedRooms() {
+		if h.repo != nil {
+			if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
+				log.Printf("Error saving room %s: %v", room.Code, err)
+			}
+		}
+		h.release(room.Code)
+	}
+}
+
+func (h *Hub) leaseRoutine() {
+	ticker := time.NewTicker(h.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.renewLeases()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// renewLeases extends the leases of loaded rooms. A room whose lease was
+// taken over by another instance (after this one failed to renew in time) is
+// dropped, since the new owner loaded it from storage.
+func (h *Hub) renewLeases() {
+	h.mu.RLock()
+	codes := make([]string, 0, len(h.Rooms))
+	for code := range h.Rooms {
+		codes = append(codes, code)
+	}
+	h.mu.RUnlock()
+
+	for _, code := range codes {
+		ctx, cancel := context.WithTimeout(context.Background(), clusterTimeout)
+		owner, err := h.registry.Claim(ctx, code, h.instanceID, h.leaseTTL)
+		cancel()
+		if err != nil {
+			// Keep serving the room; the lease may still be renewed in time
+			log.Printf("Error renewing lease on room %s: %v", code, err)
+			continue
+		}
+		if owner == h.instanceID {
+			continue
+		}
+
+		h.mu.Lock()
+		room := h.Rooms[code]
+		delete(h.Rooms, code)
+		h.mu.Unlock()
+		if room != nil {
+			room.Close("room moved to another server, please rejoin")
+			log.Printf("Lost lease on room %s", code)
+		}
+	}
+}
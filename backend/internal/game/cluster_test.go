@@ -0,0 +1,127 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/poker/backend/internal/cluster"
+	"github.com/poker/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClusterHub(id string, shared *cluster.Memory, repo RoomRepository) *Hub {
+	return NewHubWithConfig(HubConfig{
+		DefaultExpiry: 24,
+		InstanceID:    id,
+		Registry:      shared,
+		Broker:        shared,
+		LeaseTTL:      time.Minute,
+	}, repo)
+}
+
+func TestHub_ClusterOwnership(t *testing.T) {
+	shared := cluster.NewMemory()
+	repo := newMemoryRepo()
+	a := newClusterHub("a", shared, repo)
+	b := newClusterHub("b", shared, repo)
+	defer b.Stop()
+
+	room := a.CreateRoom(24)
+	owner, local := a.Owner(room.Code)
+	assert.Equal(t, "a", owner)
+	assert.True(t, local)
+
+	// The other instance routes to the owner instead of loading the room
+	owner, local = b.Owner(room.Code)
+	assert.Equal(t, "a", owner)
+	assert.False(t, local)
+	assert.Nil(t, b.GetRoom(room.Code))
+
+	// Names are unique across the cluster
+	_, err := a.CreatePersistentRoom("PAYMENTS", models.ScaleFibonacci)
+	assert.Nil(t, err)
+	_, err = b.CreatePersistentRoom("PAYMENTS", models.ScaleFibonacci)
+	assert.Equal(t, ErrRoomNameTaken, err)
+
+	// Rooms nobody owns can be loaded anywhere
+	_, local = b.Owner("UNKNOWN")
+	assert.True(t, local)
+
+	// Once the owner shuts down, another instance takes over
+	a.Stop()
+	_, local = b.Owner(room.Code)
+	assert.True(t, local)
+	assert.NotNil(t, b.GetRoom(room.Code))
+	owner, _ = b.Owner(room.Code)
+	assert.Equal(t, "b", owner)
+}
+
+func TestHub_ClusterEvents(t *testing.T) {
+	shared := cluster.NewMemory()
+	repo := newMemoryRepo()
+	a := newClusterHub("a", shared, repo)
+	defer a.Stop()
+	b := newClusterHub("b", shared, repo)
+	defer b.Stop()
+
+	received := make(chan string, 2)
+	handler := func(room *Room, data json.RawMessage) {
+		var text string
+		json.Unmarshal(data, &text)
+		received <- room.Code + ":" + text
+	}
+	a.HandleEvent("note", handler)
+	b.HandleEvent("note", handler)
+
+	room := a.CreateRoom(24)
+	data, _ := json.Marshal("hello")
+
+	// Raised on another instance, applied by the owner
+	assert.Nil(t, b.Dispatch(RoomEvent{Room: room.Code, Type: "note", Data: data}))
+	select {
+	case got := <-received:
+		assert.Equal(t, room.Code+":hello", got)
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered to the owner")
+	}
+
+	// Raised on the owner, applied directly
+	assert.Nil(t, a.Dispatch(RoomEvent{Room: room.Code, Type: "note", Data: data}))
+	assert.Equal(t, room.Code+":hello", <-received)
+
+	assert.Equal(t, ErrRoomNotFound, a.Dispatch(RoomEvent{Room: "MISSING", Type: "note"}))
+
+	// Events for all rooms reach the rooms of every instance, once each
+	other := b.CreateRoom(24)
+	assert.Nil(t, b.DispatchAll("note", data))
+	var got []string
+	for range 2 {
+		select {
+		case note := <-received:
+			got = append(got, note)
+		case <-time.After(time.Second):
+			t.Fatal("event not delivered to every instance")
+		}
+	}
+	assert.ElementsMatch(t, []string{room.Code + ":hello", other.Code + ":hello"}, got)
+	select {
+	case note := <-received:
+		t.Fatalf("event delivered twice: %s", note)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHub_DispatchAll(t *testing.T) {
+	hub := NewHub(24, nil)
+	defer hub.Stop()
+	var codes []string
+	hub.HandleEvent("note", func(room *Room, data json.RawMessage) {
+		codes = append(codes, room.Code)
+	})
+
+	first := hub.CreateRoom(24)
+	second := hub.CreateRoom(24)
+	assert.Nil(t, hub.DispatchAll("note", nil))
+	assert.ElementsMatch(t, []string{first.Code, second.Code}, codes)
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/poker/backend/internal/models"
+)
+
+// ExportVersion is the format version of exported rooms. Bump it when a
+// change to RoomSnapshot would make older exports import incorrectly.
+const ExportVersion = 1
+
+// ErrUnsupportedExport is returned when importing an export of an unknown version
+var ErrUnsupportedExport = errors.New("unsupported export version")
+
+// RoomExport is a room snapshot as exported for moving a session or attaching
+// it to a bug report. Credentials are left out: token and passphrase hashes
+// and player session tokens.
+type RoomExport struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exportedAt"`
+	Room       *RoomSnapshot `json:"room"`
+}
+
+// Export returns the room's state in the export format
+func (r *Room) Export() *RoomExport {
+	s := r.Snapshot()
+	s.HostTokenHash = ""
+	s.HostTokenExpiry = nil
+	s.FacilitatorHash = ""
+	s.PassphraseHash = ""
+	for i := range s.Players {
+		s.Players[i].SessionHash = ""
+	}
+
+	return &RoomExport{
+		Version:    ExportVersion,
+		ExportedAt: time.Now(),
+		Room:       s,
+	}
+}
+
+// maxPlayerIDLength caps imported player IDs, which are UUIDs when issued here
+const maxPlayerIDLength = 64
+
+// Validate checks that the export can be imported: it must hold no more than
+// a room created here could, with a valid scale, players and Jira actions.
+// The player limit depends on the hub and is checked on import.
+func (e *RoomExport) Validate() error {
+	if e.Version != ExportVersion {
+		return fmt.Errorf("%w: %d (expected %d)", ErrUnsupportedExport, e.Version, ExportVersion)
+	}
+	s := e.Room
+	if s == nil {
+		return errors.New("export has no room")
+	}
+
+	if _, err := importedScale(s.Scale); err != nil {
+		return err
+	}
+	if err := s.JiraActions.Validate(); err != nil {
+		return err
+	}
+	if len(s.Queue) > MaxQueue {
+		return fmt.Errorf("queue has more than %d issues", MaxQueue)
+	}
+	if len(s.History) > MaxHistory {
+		return fmt.Errorf("history has more than %d rounds", MaxHistory)
+	}
+	for _, round := range s.History {
+		for _, v := range round.Votes {
+			if !validPlayerName(v.Name) || utf8.RuneCountInString(v.Vote) > models.MaxScaleValueLength {
+				return errors.New("invalid vote in history")
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(s.Players))
+	for _, p := range s.Players {
+		if p.ID == "" || len(p.ID) > maxPlayerIDLength || seen[p.ID] {
+			return fmt.Errorf("invalid or duplicate player id %q", p.ID)
+		}
+		seen[p.ID] = true
+		if !validPlayerName(p.Name) {
+			return fmt.Errorf("player names must be 1 to %d characters", MaxPlayerNameLength)
+		}
+		if p.Role != "" && p.Role != models.RoleVoter && p.Role != models.RoleObserver {
+			return fmt.Errorf("unknown role %q", p.Role)
+		}
+		if !validAvatar(p.Avatar) {
+			return fmt.Errorf("unknown avatar %q", p.Avatar)
+		}
+		if utf8.RuneCountInString(p.Vote) > models.MaxScaleValueLength {
+			return fmt.Errorf("invalid vote of player %q", p.ID)
+		}
+	}
+	return nil
+}
+
+// importedScale returns the scale an imported room uses: a preset, with the
+// export's points if any, or a custom scale, validated as on creation
+func importedScale(scale models.VotingScale) (models.VotingScale, error) {
+	if scale.Type == "" {
+		scale.Type = models.ScaleFibonacci
+	}
+	var imported models.VotingScale
+	if scale.Type == models.ScaleCustom {
+		imported = models.VotingScale{Type: models.ScaleCustom, Name: "Custom", Values: scale.Values}
+	} else {
+		preset, ok := models.PresetScales[scale.Type]
+		if !ok {
+			return models.VotingScale{}, fmt.Errorf("unknown scale: %s", scale.Type)
+		}
+		imported = preset.Clone()
+	}
+	if len(scale.Points) > 0 {
+		imported.Points = scale.Points
+	}
+	if err := imported.Validate(); err != nil {
+		return models.VotingScale{}, err
+	}
+	return imported.Clone(), nil
+}
+
+func validPlayerName(name string) bool {
+	name = strings.TrimSpace(name)
+	return name != "" && utf8.RuneCountInString(name) <= MaxPlayerNameLength
+}
+
+// validAvatar reports whether the avatar is one assignAvatar hands out
+func validAvatar(avatar string) bool {
+	if avatar == "" || slices.Contains(Avatars, avatar) {
+		return true
+	}
+	i := strings.LastIndex(avatar, "-")
+	if i < 0 || !slices.Contains(Avatars, avatar[:i]) {
+		return false
+	}
+	n, err := strconv.Atoi(avatar[i+1:])
+	return err == nil && n >= 0 && n < 1000
+}
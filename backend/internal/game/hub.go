@@ -1,27 +1,67 @@
 package game
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/poker/backend/internal/cluster"
 	"github.com/poker/backend/internal/models"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	emptyRoomGracePeriod = 30 * time.Second
-	MaxRooms             = 1000
+
+	// DefaultMaxRooms is the room capacity of hubs without a configured limit
+	DefaultMaxRooms = 1000
+	// DefaultIdleTimeout is how long a room must be inactive before it may be
+	// evicted to make space for a new one
+	DefaultIdleTimeout = time.Hour
+	// DefaultUnloadAfter is how long a room without connected players stays
+	// in memory before it is unloaded (it remains in the repository)
+	DefaultUnloadAfter = 30 * time.Minute
+)
+
+var (
+	// ErrRoomNameTaken is returned when reserving a vanity name that is already in use
+	ErrRoomNameTaken = errors.New("room name is already taken")
+	// ErrTooManyRooms is returned when the hub is at capacity
+	ErrTooManyRooms = errors.New("too many rooms")
+	// ErrSaveFailed is returned when a new room could not be stored
+	ErrSaveFailed = errors.New("room could not be saved")
 )
 
 // RoomRepository defines the interface for room persistence
 type RoomRepository interface {
-	SaveRoom(room *Room) error
-	GetRoom(code string) (*Room, error)
+	SaveRoom(room *RoomSnapshot) error
+	GetRoom(code string) (*RoomSnapshot, error) // Nil when not stored
+	RoomExists(code string) (bool, error)
 	DeleteRoom(code string) error
-	GetAllRooms() ([]*Room, error)
+	DeleteExpiredRooms(now time.Time) (int, error) // Persistent rooms are kept
+}
+
+// HubConfig holds hub settings
+type HubConfig struct {
+	DefaultExpiry int           // hours
+	Codes         CodeGenerator // Defaults to DefaultCodeGenerator
+	HostTokenTTL  time.Duration // Lifetime of issued host tokens (0 = no expiry)
+	MaxRooms      int           // Defaults to DefaultMaxRooms
+	MaxPlayers    int           // Players per room, defaults to DefaultMaxPlayers
+	IdleTimeout   time.Duration // Defaults to DefaultIdleTimeout
+	UnloadAfter   time.Duration // Defaults to DefaultUnloadAfter
+
+	// Running several instances: rooms are leased to one instance in the
+	// registry and events for them travel over the broker. Leave unset for a
+	// single instance.
+	InstanceID string           // Unique per instance, e.g. the machine ID
+	Registry   cluster.Registry // Room ownership
+	Broker     cluster.Broker   // Room events
+	LeaseTTL   time.Duration    // Defaults to DefaultLeaseTTL
 }
 
 // Hub manages all rooms and connections
@@ -29,6 +69,19 @@ type Hub struct {
 	Rooms         map[string]*Room
 	DefaultExpiry int // hours
 	repo          RoomRepository
+	codes         CodeGenerator
+	hostTokenTTL  time.Duration
+	maxRooms      int
+	maxPlayers    int
+	idleTimeout   time.Duration
+	unloadAfter   time.Duration
+	instanceID    string
+	registry      cluster.Registry
+	broker        cluster.Broker
+	leaseTTL      time.Duration
+	eventHandlers map[string]RoomEventHandler
+	unsubscribe   func()
+	loads         singleflight.Group // Loads from the repository in progress, by code
 	mu            sync.RWMutex
 	cleanupTicker *time.Ticker
 	done          chan struct{}
@@ -36,30 +89,55 @@ type Hub struct {
 
 // NewHub creates a new hub
 func NewHub(defaultExpiryHours int, repo RoomRepository) *Hub {
+	return NewHubWithConfig(HubConfig{DefaultExpiry: defaultExpiryHours}, repo)
+}
+
+// NewHubWithConfig creates a new hub with the given settings
+func NewHubWithConfig(config HubConfig, repo RoomRepository) *Hub {
+	if config.Codes == nil {
+		config.Codes = DefaultCodeGenerator()
+	}
+	if config.MaxRooms <= 0 {
+		config.MaxRooms = DefaultMaxRooms
+	}
+	if config.MaxPlayers <= 0 {
+		config.MaxPlayers = DefaultMaxPlayers
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DefaultIdleTimeout
+	}
+	if config.UnloadAfter <= 0 {
+		config.UnloadAfter = DefaultUnloadAfter
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = DefaultLeaseTTL
+	}
+
 	h := &Hub{
 		Rooms:         make(map[string]*Room),
-		DefaultExpiry: defaultExpiryHours,
+		DefaultExpiry: config.DefaultExpiry,
 		repo:          repo,
+		codes:         config.Codes,
+		hostTokenTTL:  config.HostTokenTTL,
+		maxRooms:      config.MaxRooms,
+		maxPlayers:    config.MaxPlayers,
+		idleTimeout:   config.IdleTimeout,
+		unloadAfter:   config.UnloadAfter,
+		instanceID:    config.InstanceID,
+		registry:      config.Registry,
+		broker:        config.Broker,
+		leaseTTL:      config.LeaseTTL,
+		eventHandlers: make(map[string]RoomEventHandler),
 		done:          make(chan struct{}),
 	}
-
-	// Load existing rooms
-	if repo != nil {
-		rooms, err := repo.GetAllRooms()
-		if err != nil {
-			log.Printf("Error loading rooms from DB: %v", err)
-		} else {
-			for _, r := range rooms {
-				h.Rooms[r.Code] = r
-				log.Printf("Loaded room %s from DB", r.Code)
-			}
-		}
-	}
+	h.HandleEvent(EventBroadcast, broadcastEvent)
 
 	// Start cleanup routine
 	h.cleanupTicker = time.NewTicker(10 * time.Minute)
 	go h.cleanupRoutine()
 
+	h.joinCluster()
+
 	return h
 }
 
@@ -70,56 +148,358 @@ func (h *Hub) CreateRoom(expiryHours int) *Room {
 
 // CreateRoomWithScale creates a new room with a specific voting scale
 func (h *Hub) CreateRoomWithScale(expiryHours int, scaleType models.VotingScaleType) *Room {
+	if expiryHours <= 0 {
+		expiryHours = h.DefaultExpiry
+	}
+	expiryHours = min(expiryHours, MaxExpiryHours)
+
+	for {
+		code := h.generateRoomCode()
+		room := NewRoomWithScale(code, expiryHours, scaleType)
+		victim, err := h.insertRoom(room)
+		if errors.Is(err, ErrRoomNameTaken) {
+			continue // Loaded meanwhile
+		}
+		if err != nil {
+			h.release(code)
+			log.Printf("Max rooms reached (%d), rejecting creation", h.maxRooms)
+			return nil
+		}
+		h.evict(victim)
+
+		if h.repo != nil {
+			if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
+				log.Printf("Error saving new room %s: %v", code, err)
+			}
+		}
+
+		log.Printf("Room created: %s (expires in %d hours, scale: %s)", code, expiryHours, scaleType)
+		return room
+	}
+}
+
+// CreatePersistentRoom reserves a named team room (e.g. "PAYMENTS"). Persistent
+// rooms survive being empty and never expire, so they keep their settings and
+// history between sessions.
+func (h *Hub) CreatePersistentRoom(name string, scaleType models.VotingScaleType) (*Room, error) {
+	code, err := NormalizeRoomName(name)
+	if err != nil {
+		return nil, err
+	}
+	if h.roomExists(code) || !h.claim(code) {
+		return nil, ErrRoomNameTaken
+	}
+
+	room := NewRoomWithScale(code, h.DefaultExpiry, scaleType)
+	room.Persistent = true
+	victim, err := h.insertRoom(room)
+	if errors.Is(err, ErrTooManyRooms) {
+		h.release(code)
+		log.Printf("Max rooms reached (%d), rejecting reservation of %s", h.maxRooms, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.evict(victim)
+
+	if h.repo != nil {
+		if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
+			log.Printf("Error saving new room %s: %v", code, err)
+		}
+	}
+
+	log.Printf("Persistent room reserved: %s (scale: %s)", code, scaleType)
+	return room, nil
+}
+
+// insertRoom adds a new room to memory, making space for it if the hub is
+// full. The room evicted for it, if any, is returned for evict. It fails with
+// ErrRoomNameTaken if the code was loaded meanwhile, and with ErrTooManyRooms,
+// after which callers release the code they claimed.
+func (h *Hub) insertRoom(room *Room) (*Room, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if len(h.Rooms) >= MaxRooms {
-		log.Printf("Max rooms reached (%d), rejecting creation", MaxRooms)
-		return nil
+	if _, exists := h.Rooms[room.Code]; exists {
+		return nil, ErrRoomNameTaken
+	}
+	victim, ok := h.reserveCapacity()
+	if !ok {
+		return nil, ErrTooManyRooms
 	}
+	room.MaxPlayers = h.maxPlayers
+	h.Rooms[room.Code] = room
+	return victim, nil
+}
 
-	if expiryHours <= 0 {
-		expiryHours = h.DefaultExpiry
+// reserveCapacity makes room for one more room, taking the least recently
+// active empty or idle room out of memory if the hub is full. The evicted
+// room is returned for evict. Callers must hold the lock.
+func (h *Hub) reserveCapacity() (*Room, bool) {
+	if len(h.Rooms) < h.maxRooms {
+		return nil, true
 	}
 
-	code := h.generateRoomCode()
-	room := NewRoomWithScale(code, expiryHours, scaleType)
-	h.Rooms[code] = room
+	var victim *Room
+	for _, room := range h.Rooms {
+		if room.Persistent {
+			continue
+		}
+		if !room.IsEmpty() && !room.IsIdle(h.idleTimeout) {
+			continue
+		}
+		if victim == nil || room.LastActivity().Before(victim.LastActivity()) {
+			victim = room
+		}
+	}
+	if victim == nil {
+		return nil, false
+	}
+
+	delete(h.Rooms, victim.Code)
+	return victim, true
+}
 
+// evict deletes a room reserveCapacity took out of memory, if any, and
+// disconnects its players. Idle rooms that still have players are only
+// unloaded: they are saved and can be loaded again when someone rejoins.
+// Storage, the registry and the players' connections are all written to, so
+// it is called without the lock.
+func (h *Hub) evict(victim *Room) {
+	if victim == nil {
+		return
+	}
 	if h.repo != nil {
-		if err := h.repo.SaveRoom(room); err != nil {
-			log.Printf("Error saving new room %s: %v", code, err)
+		if victim.IsEmpty() || victim.IsExpired() {
+			h.repo.DeleteRoom(victim.Code)
+		} else if err := h.repo.SaveRoom(victim.Snapshot()); err != nil {
+			log.Printf("Error saving evicted room %s: %v", victim.Code, err)
 		}
 	}
+	h.release(victim.Code)
+	victim.Close("room closed to make space for new rooms")
+	log.Printf("Room evicted: %s (inactive since %s)", victim.Code, victim.LastActivity().Format(time.RFC3339))
+}
 
-	log.Printf("Room created: %s (expires in %d hours, scale: %s)", code, expiryHours, scaleType)
-	return room
+// ImportRoom recreates a room from an export under the requested code, or a
+// new code if none is given. The room starts a fresh activity window and has
+// no host or facilitator tokens until they are issued; imported players are
+// offline, cannot resume their sessions and get new IDs, so the export of a
+// room that still exists can be imported next to it.
+func (h *Hub) ImportRoom(export *RoomExport, code string) (*Room, error) {
+	if err := export.Validate(); err != nil {
+		return nil, err
+	}
+	if len(export.Room.Players) > h.maxPlayers {
+		return nil, fmt.Errorf("room has more than %d players", h.maxPlayers)
+	}
+	// Like reserved rooms, only rooms imported under a name are persistent
+	_, nameErr := NormalizeRoomName(code)
+	persistent := export.Room.Persistent && nameErr == nil
+	if code != "" {
+		normalized, err := NormalizeRoomCode(code)
+		if err != nil {
+			return nil, err
+		}
+		code = normalized
+	}
+
+	if code == "" {
+		code = h.generateRoomCode()
+	} else if h.roomExists(code) || !h.claim(code) {
+		return nil, ErrRoomNameTaken
+	}
+
+	// Credentials are never imported; the importer is issued new tokens
+	snapshot := *export.Room
+	snapshot.Code = code
+	snapshot.HostTokenHash = ""
+	snapshot.HostTokenExpiry = nil
+	snapshot.FacilitatorHash = ""
+	snapshot.PassphraseHash = ""
+	snapshot.Players = make([]PlayerSnapshot, len(export.Room.Players))
+	playerIDs := make(map[string]string, len(export.Room.Players))
+	for i, p := range export.Room.Players {
+		playerIDs[p.ID] = uuid.New().String()
+		p.ID = playerIDs[p.ID]
+		p.SessionHash = ""
+		if p.Role == "" {
+			p.Role = models.RoleVoter
+		}
+		snapshot.Players[i] = p
+	}
+	snapshot.HostID = playerIDs[export.Room.HostID]
+	snapshot.LastActive = time.Now()
+	snapshot.TimerEndTime = nil // Timers do not survive the move
+	snapshot.Persistent = persistent
+	if !persistent {
+		snapshot.JiraActions = models.JiraActions{} // Like created rooms, only reserved ones change issues
+	}
+	snapshot.Scale, _ = importedScale(export.Room.Scale)
+
+	// Issue HTML is only ever rendered by the server, never taken from a file
+	if snapshot.CurrentIssue != nil {
+		issue := snapshot.CurrentIssue.WithoutMarkup()
+		snapshot.CurrentIssue = &issue
+	}
+	snapshot.Queue = make([]models.JiraIssue, len(export.Room.Queue))
+	for i, issue := range export.Room.Queue {
+		snapshot.Queue[i] = issue.WithoutMarkup()
+	}
+	snapshot.History = make([]models.RoundResult, len(export.Room.History))
+	for i, round := range export.Room.History {
+		if round.Issue != nil {
+			issue := round.Issue.WithoutMarkup()
+			round.Issue = &issue
+		}
+		// Votes of imported players follow them to their new IDs
+		votes := make(map[string]models.RoundVote, len(round.Votes))
+		for id, vote := range round.Votes {
+			if newID, ok := playerIDs[id]; ok {
+				id = newID
+			}
+			votes[id] = vote
+		}
+		round.Votes = votes
+		snapshot.History[i] = round
+	}
+	if snapshot.ExpiryHours <= 0 {
+		snapshot.ExpiryHours = h.DefaultExpiry
+	}
+	snapshot.ExpiryHours = min(snapshot.ExpiryHours, MaxExpiryHours)
+
+	room := RoomFromSnapshot(&snapshot)
+	victim, err := h.insertRoom(room)
+	if errors.Is(err, ErrTooManyRooms) {
+		h.release(code)
+		log.Printf("Max rooms reached (%d), rejecting import of %s", h.maxRooms, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.evict(victim)
+
+	if h.repo != nil {
+		if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
+			log.Printf("Error saving imported room %s: %v", code, err)
+			h.mu.Lock()
+			if h.Rooms[code] == room {
+				delete(h.Rooms, code)
+			}
+			h.mu.Unlock()
+			h.release(code)
+			return nil, ErrSaveFailed
+		}
+	}
+
+	log.Printf("Room imported: %s (%d players, %d rounds)", code, len(snapshot.Players), len(snapshot.History))
+	return room, nil
 }
 
 // SaveRoom saves the room state (for updates)
 func (h *Hub) SaveRoom(room *Room) {
 	if h.repo != nil {
-		if err := h.repo.SaveRoom(room); err != nil {
+		if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
 			log.Printf("Error saving room %s: %v", room.Code, err)
 		}
 	}
 }
 
-// GetRoom returns a room by code
+// IssueHostToken rotates the room's host token and persists the new hash
+func (h *Hub) IssueHostToken(room *Room) (string, *time.Time) {
+	token, expiry := room.IssueHostToken(h.hostTokenTTL)
+	h.SaveRoom(room)
+	return token, expiry
+}
+
+// IssueFacilitatorToken rotates the room's facilitator invite and persists the new hash
+func (h *Hub) IssueFacilitatorToken(room *Room) string {
+	token := room.IssueFacilitatorToken()
+	h.SaveRoom(room)
+	return token
+}
+
+// GetRoom returns a room by code, loading it from the repository if it is
+// not in memory
 func (h *Hub) GetRoom(code string) *Room {
+	room, _ := h.LookupRoom(code)
+	return room
+}
+
+// LookupRoom is GetRoom for callers that answer clients: it fails with
+// ErrTooManyRooms when the room is stored but the hub is too full to load it,
+// and returns nil only when the room does not exist here.
+func (h *Hub) LookupRoom(code string) (*Room, error) {
+	code = strings.ToUpper(code)
+
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return h.Rooms[strings.ToUpper(code)]
+	room := h.Rooms[code]
+	h.mu.RUnlock()
+	if room != nil || h.repo == nil {
+		return room, nil
+	}
+
+	// Concurrent lookups of the same room share one load
+	loaded, err, _ := h.loads.Do(code, func() (interface{}, error) {
+		return h.loadRoom(code)
+	})
+	return loaded.(*Room), err
+}
+
+// loadRoom reads a room from the repository into memory. The repository and
+// registry are asked without holding the lock, so lookups of unknown codes
+// do not stall the hub, and a full hub does not evict rooms for a lookup.
+func (h *Hub) loadRoom(code string) (*Room, error) {
+	snapshot, err := h.repo.GetRoom(code)
+	if err != nil {
+		log.Printf("Error loading room %s: %v", code, err)
+		return nil, nil
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	room := RoomFromSnapshot(snapshot)
+	if !room.Persistent && room.IsExpired() {
+		h.repo.DeleteRoom(code)
+		log.Printf("Room expired in storage: %s", code)
+		return nil, nil
+	}
+	if !h.claim(code) {
+		// Served by another instance
+		return nil, nil
+	}
+
+	h.mu.Lock()
+	if existing := h.Rooms[code]; existing != nil {
+		// Created or imported meanwhile
+		h.mu.Unlock()
+		return existing, nil
+	}
+	if len(h.Rooms) >= h.maxRooms {
+		h.mu.Unlock()
+		h.release(code)
+		log.Printf("Max rooms reached (%d), cannot load room %s", h.maxRooms, code)
+		return nil, ErrTooManyRooms
+	}
+	room.MaxPlayers = h.maxPlayers
+	h.Rooms[code] = room
+	h.mu.Unlock()
+
+	log.Printf("Loaded room %s from storage", code)
+	return room, nil
 }
 
 // DeleteRoom removes a room
 func (h *Hub) DeleteRoom(code string) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
 	delete(h.Rooms, code)
+	h.mu.Unlock()
+
 	if h.repo != nil {
 		h.repo.DeleteRoom(code)
 	}
+	h.release(code)
 	log.Printf("Room deleted: %s", code)
 }
 
@@ -128,32 +508,56 @@ func (h *Hub) ScheduleDeleteIfEmpty(code string) {
 	go func() {
 		time.Sleep(emptyRoomGracePeriod)
 		h.mu.Lock()
-		defer h.mu.Unlock()
-
-		if room, exists := h.Rooms[code]; exists && room.IsEmpty() {
+		room, exists := h.Rooms[code]
+		deleted := exists && !room.Persistent && room.IsEmpty()
+		if deleted {
 			delete(h.Rooms, code)
+		}
+		h.mu.Unlock()
+
+		if deleted {
 			if h.repo != nil {
 				h.repo.DeleteRoom(code)
 			}
+			h.release(code)
 			log.Printf("Room deleted after grace period: %s", code)
 		}
 	}()
 }
 
-// generateRoomCode creates a unique 6-character room code
+// generateRoomCode creates a unique room code
 func (h *Hub) generateRoomCode() string {
 	for {
-		bytes := make([]byte, 3)
-		rand.Read(bytes)
-		code := strings.ToUpper(hex.EncodeToString(bytes))
+		code := h.codes.Generate()
 
-		// Make sure code doesn't exist
-		if _, exists := h.Rooms[code]; !exists {
+		// Make sure code doesn't exist, in memory or in storage, and that no
+		// other instance picked it at the same time
+		if !h.roomExists(code) && h.claim(code) {
 			return code
 		}
 	}
 }
 
+// roomExists checks memory and the repository for a room
+func (h *Hub) roomExists(code string) bool {
+	h.mu.RLock()
+	_, exists := h.Rooms[code]
+	h.mu.RUnlock()
+	if exists {
+		return true
+	}
+	if h.repo == nil {
+		return false
+	}
+	exists, err := h.repo.RoomExists(code)
+	if err != nil {
+		// Treat storage errors as taken rather than risk overwriting a room
+		log.Printf("Error checking room %s: %v", code, err)
+		return true
+	}
+	return exists
+}
+
 // cleanupRoutine periodically removes expired and empty rooms
 func (h *Hub) cleanupRoutine() {
 	for {
@@ -167,29 +571,75 @@ func (h *Hub) cleanupRoutine() {
 	}
 }
 
-// cleanup removes expired and empty rooms
+// cleanup removes expired and empty rooms and unloads rooms nobody is
+// connected to. Persistent rooms are never removed. Rooms are picked under
+// the lock, and storage and the registry are updated after releasing it.
 func (h *Hub) cleanup() {
+	var removed, idle []*Room
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	for code, room := range h.Rooms {
-		if room.IsEmpty() || room.IsExpired() {
+		if !room.Persistent && (room.IsEmpty() || room.IsExpired()) {
 			delete(h.Rooms, code)
-			if h.repo != nil {
-				h.repo.DeleteRoom(code)
-			}
-			log.Printf("Room cleaned up: %s (empty: %v, expired: %v)",
-				code, room.IsEmpty(), room.IsExpired())
+			removed = append(removed, room)
+			continue
+		}
+		// Rooms can only be unloaded if they can be loaded again
+		if h.repo != nil && room.ConnectedCount() == 0 && room.IsIdle(h.unloadAfter) {
+			idle = append(idle, room)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, room := range removed {
+		if h.repo != nil {
+			h.repo.DeleteRoom(room.Code)
+		}
+		h.release(room.Code)
+		log.Printf("Room cleaned up: %s (empty: %v, expired: %v)",
+			room.Code, room.IsEmpty(), room.IsExpired())
+	}
+	for _, room := range idle {
+		h.unload(room)
+	}
+
+	// Expire rooms that are only in storage
+	if h.repo != nil {
+		if n, err := h.repo.DeleteExpiredRooms(time.Now()); err != nil {
+			log.Printf("Error deleting expired rooms: %v", err)
+		} else if n > 0 {
+			log.Printf("Deleted %d expired rooms from storage", n)
 		}
 	}
 }
 
-// Stop stops the hub cleanup routine
+// unload saves a room nobody is connected to and drops it from memory,
+// unless it was used while being saved
+func (h *Hub) unload(room *Room) {
+	if err := h.repo.SaveRoom(room.Snapshot()); err != nil {
+		log.Printf("Error saving room %s, keeping it loaded: %v", room.Code, err)
+		return
+	}
+
+	h.mu.Lock()
+	if h.Rooms[room.Code] != room || room.ConnectedCount() > 0 || !room.IsIdle(h.unloadAfter) {
+		h.mu.Unlock()
+		return
+	}
+	delete(h.Rooms, room.Code)
+	h.mu.Unlock()
+
+	h.release(room.Code)
+	log.Printf("Room unloaded: %s", room.Code)
+}
+
+// Stop stops the hub cleanup routine. In a cluster, rooms are saved and
+// their leases released so other instances can take them over.
 func (h *Hub) Stop() {
 	close(h.done)
+	h.leaveCluster()
 }
 
-// RoomCount returns the number of active rooms
+// RoomCount returns the number of rooms loaded in memory
 func (h *Hub) RoomCount() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -207,7 +657,8 @@ func (h *Hub) Stats() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"rooms":   len(h.Rooms),
-		"players": totalPlayers,
+		"rooms":    len(h.Rooms),
+		"players":  totalPlayers,
+		"maxRooms": h.maxRooms,
 	}
 }
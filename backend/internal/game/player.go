@@ -1,6 +1,7 @@
 package game
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/gorilla/websocket"
@@ -20,31 +21,44 @@ var Avatars = []string{
 	"bounty-hunter",
 }
 
+// ErrNotConnected is returned when sending to a player without a live connection
+var ErrNotConnected = errors.New("player is not connected")
+
 // Player represents a connected user
 type Player struct {
-	ID          string
-	Name        string
-	Avatar      string
-	IsHost      bool
-	Vote        string
-	HasVoted    bool
-	Conn        *websocket.Conn
-	Room        *Room
-	mu          sync.RWMutex
-	RateLimiter *rate.Limiter
+	ID           string
+	Name         string
+	Avatar       string
+	Role         models.PlayerRole
+	SessionToken string // Handed to the client; empty for restored players until they resume
+	SessionHash  string // Hash of the session token, which lets a reconnecting client resume this player
+	IsHost       bool
+	IsCoHost     bool // Joined with a facilitator invite
+	tokenHost    bool // Proved the host token; players promoted when the host leaves have not
+	Vote         string
+	HasVoted     bool
+	Conn         *websocket.Conn
+	Room         *Room
+	Protocol     int // Negotiated protocol version (models.ProtocolV0, ...)
+	mu           sync.RWMutex
+	RateLimiter  *rate.Limiter
 }
 
 // NewPlayer creates a new player
 func NewPlayer(id, name, avatar string, conn *websocket.Conn, isHost bool) *Player {
+	sessionToken := newSecretToken()
 	return &Player{
-		ID:          id,
-		Name:        name,
-		Avatar:      avatar,
-		Conn:        conn,
-		HasVoted:    false,
-		Vote:        "",
-		IsHost:      isHost,
-		RateLimiter: rate.NewLimiter(5, 10), // 5 messages/sec, burst 10
+		ID:           id,
+		Name:         name,
+		Avatar:       avatar,
+		Role:         models.RoleVoter,
+		SessionToken: sessionToken,
+		SessionHash:  hashSecret(sessionToken),
+		Conn:         conn,
+		HasVoted:     false,
+		Vote:         "",
+		IsHost:       isHost,
+		RateLimiter:  rate.NewLimiter(5, 10), // 5 messages/sec, burst 10
 	}
 }
 
@@ -54,8 +68,10 @@ func (p *Player) ToModel(includeVote bool) *models.Player {
 		ID:       p.ID,
 		Name:     p.Name,
 		Avatar:   p.Avatar,
+		Role:     p.Role,
 		HasVoted: p.HasVoted,
 		IsHost:   p.IsHost,
+		IsCoHost: p.IsCoHost,
 	}
 	if includeVote {
 		player.Vote = p.Vote
@@ -67,9 +83,49 @@ func (p *Player) ToModel(includeVote bool) *models.Player {
 func (p *Player) SendMessage(msg *models.ServerMessage) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.Conn == nil {
+		return ErrNotConnected
+	}
 	return p.Conn.WriteJSON(msg)
 }
 
+// IsObserver returns true if the player watches without voting
+func (p *Player) IsObserver() bool {
+	return p.Role == models.RoleObserver
+}
+
+// IsConnected returns true if the player has an open connection
+func (p *Player) IsConnected() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Conn != nil
+}
+
+// closeConn closes the player's connection, if any
+func (p *Player) closeConn() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+// swapConn replaces the player's connection and returns the previous one
+func (p *Player) swapConn(conn *websocket.Conn) *websocket.Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := p.Conn
+	p.Conn = conn
+	return old
+}
+
+// hasConn reports whether the player is currently bound to the given connection
+func (p *Player) hasConn(conn *websocket.Conn) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Conn == conn
+}
+
 // ResetVote resets the player's vote
 func (p *Player) ResetVote() {
 	p.HasVoted = false
@@ -6,8 +6,9 @@ import (
 	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/poker/backend/internal/models"
+	"golang.org/x/time/rate"
 )
 
 // Room represents a poker planning room
@@ -16,7 +17,10 @@ type Room struct {
 	Players         map[string]*Player
 	Revealed        bool
 	HostID          string
-	HostToken       string
+	HostTokenHash   string     // SHA-256 of the host token; the token itself is never stored
+	HostTokenExpiry *time.Time // Nil when the host token does not expire
+	FacilitatorHash string     // SHA-256 of the facilitator invite token, empty when revoked
+	PassphraseHash  string     // Empty when the room is open to anyone with the code
 	CreatedAt       time.Time
 	LastActive      time.Time
 	ExpiryHours     int
@@ -24,6 +28,11 @@ type Room struct {
 	TimerEndTime    *time.Time
 	TimerAutoReveal bool
 	CurrentIssue    *models.JiraIssue
+	Queue           []models.JiraIssue   // Issues waiting to be estimated, next first
+	JiraActions     models.JiraActions   // Done in Jira when an estimate is saved
+	MaxPlayers      int                  // Player cap, DefaultMaxPlayers when zero
+	Persistent      bool                 // Named team room, exempt from cleanup
+	History         []models.RoundResult // Revealed rounds, oldest first
 	timerCancel     chan struct{}
 	mu              sync.RWMutex
 	usedAvatars     map[string]bool
@@ -37,7 +46,6 @@ func NewRoom(code string, expiryHours int) *Room {
 		Code:        code,
 		Players:     make(map[string]*Player),
 		Revealed:    false,
-		HostToken:   uuid.New().String(),
 		CreatedAt:   time.Now(),
 		LastActive:  time.Now(),
 		ExpiryHours: expiryHours,
@@ -58,7 +66,6 @@ func NewRoomWithScale(code string, expiryHours int, scaleType models.VotingScale
 		Code:        code,
 		Players:     make(map[string]*Player),
 		Revealed:    false,
-		HostToken:   uuid.New().String(),
 		CreatedAt:   time.Now(),
 		LastActive:  time.Now(),
 		ExpiryHours: expiryHours,
@@ -67,38 +74,17 @@ func NewRoomWithScale(code string, expiryHours int, scaleType models.VotingScale
 	}
 }
 
-// RestoreRoom reconstructs a room from persistence fields
-func RestoreRoom(
-	code, hostID, hostToken string,
-	createdAt, lastActive time.Time,
-	expiryHours int,
-	scale *models.VotingScale,
-	timerEndTime *time.Time,
-	timerAutoReveal, revealed bool,
-	currentIssue *models.JiraIssue,
-) *Room {
-	return &Room{
-		Code:            code,
-		Players:         make(map[string]*Player),
-		Revealed:        revealed,
-		HostID:          hostID,
-		HostToken:       hostToken,
-		CreatedAt:       createdAt,
-		LastActive:      lastActive,
-		ExpiryHours:     expiryHours,
-		Scale:           scale,
-		TimerEndTime:    timerEndTime,
-		TimerAutoReveal: timerAutoReveal,
-		CurrentIssue:    currentIssue,
-		usedAvatars:     make(map[string]bool),
-	}
-}
-
 // RestorePlayer adds a restored player to the room
 func (r *Room) RestorePlayer(player *Player) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if player.Role == "" {
+		player.Role = models.RoleVoter
+	}
+	if player.RateLimiter == nil {
+		player.RateLimiter = rate.NewLimiter(5, 10)
+	}
 	r.Players[player.ID] = player
 	player.Room = r
 	if player.Avatar != "" {
@@ -106,14 +92,19 @@ func (r *Room) RestorePlayer(player *Player) {
 	}
 }
 
-const MaxPlayers = 30
+// DefaultMaxPlayers is the player cap of rooms without a configured limit
+const DefaultMaxPlayers = 30
 
 // AddPlayer adds a player to the room
 func (r *Room) AddPlayer(player *Player) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if len(r.Players) >= MaxPlayers {
+	maxPlayers := r.MaxPlayers
+	if maxPlayers <= 0 {
+		maxPlayers = DefaultMaxPlayers
+	}
+	if len(r.Players) >= maxPlayers {
 		return false
 	}
 
@@ -133,11 +124,49 @@ func (r *Room) AddPlayer(player *Player) bool {
 	return true
 }
 
+// ResumePlayer rebinds the player owning the session token to a new connection.
+// It returns the player and its previous connection, or nil if no player matches.
+func (r *Room) ResumePlayer(sessionToken string, conn *websocket.Conn) (*Player, *websocket.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sessionToken == "" {
+		return nil, nil
+	}
+	for _, p := range r.Players {
+		if secretMatches(sessionToken, p.SessionHash) {
+			p.SessionToken = sessionToken
+			old := p.swapConn(conn)
+			r.LastActive = time.Now()
+			return p, old
+		}
+	}
+	return nil, nil
+}
+
 // RemovePlayer removes a player from the room
 func (r *Room) RemovePlayer(playerID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.removePlayer(playerID)
+}
+
+// DetachPlayer removes a player whose connection closed, unless the player has
+// since resumed its session on another connection
+func (r *Room) DetachPlayer(playerID string, conn *websocket.Conn) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
+	player, exists := r.Players[playerID]
+	if !exists || !player.hasConn(conn) {
+		return false
+	}
+	r.removePlayer(playerID)
+	return true
+}
+
+// removePlayer removes a player; callers must hold the room lock
+func (r *Room) removePlayer(playerID string) {
 	if player, exists := r.Players[playerID]; exists {
 		// Free up the avatar
 		r.usedAvatars[player.Avatar] = false
@@ -147,6 +176,7 @@ func (r *Room) RemovePlayer(playerID string) {
 		if r.HostID == playerID && len(r.Players) > 0 {
 			for id, p := range r.Players {
 				p.IsHost = true
+				p.IsCoHost = false
 				r.HostID = id
 				break
 			}
@@ -160,21 +190,180 @@ func (r *Room) ClaimHost(playerID, token string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if token != "" && token == r.HostToken {
-		// Demote current host if exists
-		if currHost, ok := r.Players[r.HostID]; ok {
-			currHost.IsHost = false
-		}
+	if !r.hostTokenValid(token) {
+		return false
+	}
 
-		// Promote new host
-		if p, ok := r.Players[playerID]; ok {
-			p.IsHost = true
-			r.HostID = playerID
-			r.LastActive = time.Now()
-			return true
-		}
+	p, ok := r.Players[playerID]
+	if !ok {
+		return false
 	}
-	return false
+
+	// Demote current host if exists
+	if currHost, ok := r.Players[r.HostID]; ok {
+		currHost.IsHost = false
+		currHost.tokenHost = false
+	}
+
+	// Promote new host
+	p.IsHost = true
+	p.IsCoHost = false
+	p.tokenHost = true
+	r.HostID = playerID
+	r.LastActive = time.Now()
+	return true
+}
+
+// ClaimFacilitator grants co-host rights to a player holding the facilitator invite
+func (r *Room) ClaimFacilitator(playerID, token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !secretMatches(token, r.FacilitatorHash) {
+		return false
+	}
+	p, ok := r.Players[playerID]
+	if !ok {
+		return false
+	}
+	if !p.IsHost {
+		p.IsCoHost = true
+	}
+	r.LastActive = time.Now()
+	return true
+}
+
+// CheckHostToken returns true if the token is the room's current host token
+func (r *Room) CheckHostToken(token string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.hostTokenValid(token)
+}
+
+// hostTokenValid checks a host token against the stored hash and expiry; callers must hold the lock
+func (r *Room) hostTokenValid(token string) bool {
+	if r.HostTokenExpiry != nil && time.Now().After(*r.HostTokenExpiry) {
+		return false
+	}
+	return secretMatches(token, r.HostTokenHash)
+}
+
+// IssueHostToken generates a new host token, invalidating the previous one.
+// The token and its expiry are returned once and only its hash is kept. A ttl
+// of 0 never expires.
+func (r *Room) IssueHostToken(ttl time.Duration) (string, *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := newSecretToken()
+	r.HostTokenHash = hashSecret(token)
+	r.HostTokenExpiry = nil
+	if ttl > 0 {
+		expiry := time.Now().Add(ttl)
+		r.HostTokenExpiry = &expiry
+	}
+	r.LastActive = time.Now()
+	return token, r.HostTokenExpiry
+}
+
+// IssueFacilitatorToken generates a new facilitator invite, invalidating the previous one
+func (r *Room) IssueFacilitatorToken() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := newSecretToken()
+	r.FacilitatorHash = hashSecret(token)
+	r.LastActive = time.Now()
+	return token
+}
+
+// RevokeFacilitators invalidates the facilitator invite and demotes all co-hosts
+func (r *Room) RevokeFacilitators() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FacilitatorHash = ""
+	for _, p := range r.Players {
+		p.IsCoHost = false
+	}
+	r.LastActive = time.Now()
+}
+
+// IsHost returns true if the player owns the room
+func (r *Room) IsHost(playerID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.HostID == playerID
+}
+
+// HoldsHostToken returns true if the player is the host and proved it with
+// the host token. Players who became host by joining first or because the
+// host left have not, so they cannot rotate the token or manage invites.
+func (r *Room) HoldsHostToken(playerID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.Players[playerID]
+	return ok && r.HostID == playerID && p.tokenHost
+}
+
+// CanModerate returns true if the player is the host or a co-host
+func (r *Room) CanModerate(playerID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.canModerate(playerID)
+}
+
+// canModerate is CanModerate for callers holding the lock
+func (r *Room) canModerate(playerID string) bool {
+	if r.HostID == playerID {
+		return true
+	}
+	p, ok := r.Players[playerID]
+	return ok && p.IsCoHost
+}
+
+// SetPassphrase protects the room with a passphrase (empty removes it)
+func (r *Room) SetPassphrase(passphrase string) {
+	hash := ""
+	if passphrase != "" {
+		hash = hashPassphrase(passphrase) // Slow, so outside the lock
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.PassphraseHash = hash
+}
+
+// CheckPassphrase returns true if the room is open or the passphrase matches
+func (r *Room) CheckPassphrase(passphrase string) bool {
+	r.mu.RLock()
+	hash := r.PassphraseHash
+	r.mu.RUnlock()
+
+	if hash == "" {
+		return true
+	}
+	return passphraseMatches(passphrase, hash)
+}
+
+// HasPassphrase returns true if joining requires a passphrase
+func (r *Room) HasPassphrase() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.PassphraseHash != ""
+}
+
+// PlayerList returns the room's players; unlike ranging over Players it is
+// safe while other goroutines join or leave
+func (r *Room) PlayerList() []*Player {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	players := make([]*Player, 0, len(r.Players))
+	for _, p := range r.Players {
+		players = append(players, p)
+	}
+	return players
 }
 
 // GetPlayer returns a player by ID
@@ -203,7 +392,7 @@ func (r *Room) Vote(playerID, vote string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if player, exists := r.Players[playerID]; exists {
+	if player, exists := r.Players[playerID]; exists && !player.IsObserver() {
 		player.SetVote(vote)
 		r.LastActive = time.Now()
 		return true
@@ -211,19 +400,120 @@ func (r *Room) Vote(playerID, vote string) bool {
 	return false
 }
 
-// Reveal reveals all votes (host only)
+// MaxHistory is the number of revealed rounds a room remembers
+const MaxHistory = 100
+
+// MaxQueue is the number of issues a room can have queued
+const MaxQueue = 200
+
+// MaxPlayerNameLength is the longest player name, in characters
+const MaxPlayerNameLength = 50
+
+// MaxExpiryHours is the longest a room may live without activity
+const MaxExpiryHours = 7 * 24
+
+// Reveal reveals all votes (host or co-host)
 func (r *Room) Reveal(playerID string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Only host can reveal
-	if r.HostID != playerID {
+	// Only host or co-hosts can reveal
+	if !r.canModerate(playerID) {
 		return false
 	}
 
+	r.reveal()
+	return true
+}
+
+// AutoReveal reveals the votes when the timer ends. It returns false if the
+// round was already revealed.
+func (r *Room) AutoReveal() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Revealed {
+		return false
+	}
+	r.reveal()
+	return true
+}
+
+// reveal marks the round revealed and records it in the history; callers must hold the lock
+func (r *Room) reveal() {
+	if !r.Revealed {
+		r.recordRound()
+	}
 	r.Revealed = true
 	r.LastActive = time.Now()
-	return true
+}
+
+// recordRound appends the current votes to the history; callers must hold the lock
+func (r *Room) recordRound() {
+	votes := make(map[string]models.RoundVote)
+	var sum float64
+	var count int
+	for _, player := range r.Players {
+		if !player.HasVoted {
+			continue
+		}
+		votes[player.ID] = models.RoundVote{Name: player.Name, Vote: player.Vote}
+		if val, err := strconv.ParseFloat(player.Vote, 64); err == nil {
+			sum += val
+			count++
+		}
+	}
+	if len(votes) == 0 {
+		return
+	}
+
+	round := models.RoundResult{
+		Issue:      r.CurrentIssue,
+		Votes:      votes,
+		RevealedAt: time.Now().UnixMilli(),
+	}
+	if count > 0 {
+		round.Average = sum / float64(count)
+	}
+
+	r.History = append(r.History, round)
+	if len(r.History) > MaxHistory {
+		r.History = r.History[len(r.History)-MaxHistory:]
+	}
+}
+
+// GetHistory returns a copy of the room's round history
+func (r *Room) GetHistory() []models.RoundResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.RoundResult(nil), r.History...)
+}
+
+// LastRound returns the most recent revealed round for an issue
+func (r *Room) LastRound(issueKey string) (models.RoundResult, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := len(r.History) - 1; i >= 0; i-- {
+		if round := r.History[i]; round.Issue != nil && round.Issue.Key == issueKey {
+			return round, true
+		}
+	}
+	return models.RoundResult{}, false
+}
+
+// IsRecentIssue reports whether the issue is the one being estimated or the
+// one of the last round revealed
+func (r *Room) IsRecentIssue(issueKey string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.CurrentIssue != nil && r.CurrentIssue.Key == issueKey {
+		return true
+	}
+	if n := len(r.History); n > 0 {
+		last := r.History[n-1]
+		return last.Issue != nil && last.Issue.Key == issueKey
+	}
+	return false
 }
 
 // Reset resets the room for a new round
@@ -257,6 +547,13 @@ func (r *Room) GetState(forPlayerID string) *models.RoomState {
 		Scale:           r.Scale,
 		TimerAutoReveal: r.TimerAutoReveal,
 		CurrentIssue:    r.CurrentIssue,
+		Queue:           append([]models.JiraIssue(nil), r.Queue...),
+		Persistent:      r.Persistent,
+		History:         append([]models.RoundResult(nil), r.History...),
+	}
+	if r.JiraActions.Any() {
+		actions := r.JiraActions
+		state.JiraActions = &actions
 	}
 
 	// Include timer end time if active
@@ -275,13 +572,30 @@ func (r *Room) IsEmpty() bool {
 	return len(r.Players) == 0
 }
 
-// IsExpired returns true if the room has expired
+// IsExpired returns true if the room has expired. Persistent rooms never expire.
 func (r *Room) IsExpired() bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	if r.Persistent {
+		return false
+	}
 	return time.Since(r.LastActive) > time.Duration(r.ExpiryHours)*time.Hour
 }
 
+// IsIdle returns true if nothing happened in the room for the given duration
+func (r *Room) IsIdle(timeout time.Duration) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return time.Since(r.LastActive) > timeout
+}
+
+// LastActivity returns when the room was last active
+func (r *Room) LastActivity() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.LastActive
+}
+
 // Broadcast sends a message to all players in the room
 func (r *Room) Broadcast(msg *models.ServerMessage) {
 	r.mu.RLock()
@@ -292,6 +606,21 @@ func (r *Room) Broadcast(msg *models.ServerMessage) {
 	}
 }
 
+// Close tells connected players the room is gone and closes their connections
+func (r *Room) Close(reason string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, player := range r.Players {
+		player.SendMessage(&models.ServerMessage{
+			Type:  models.MsgTypeError,
+			Error: reason,
+			Code:  models.ErrCodeRoomClosed,
+		})
+		player.closeConn()
+	}
+}
+
 // BroadcastExcept sends a message to all players except one
 func (r *Room) BroadcastExcept(msg *models.ServerMessage, exceptID string) {
 	r.mu.RLock()
@@ -343,13 +672,27 @@ func (r *Room) PlayerCount() int {
 	return len(r.Players)
 }
 
-// StartTimer starts a voting timer (host only)
+// ConnectedCount returns the number of players with an open connection
+func (r *Room) ConnectedCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, p := range r.Players {
+		if p.IsConnected() {
+			count++
+		}
+	}
+	return count
+}
+
+// StartTimer starts a voting timer (host or co-host)
 func (r *Room) StartTimer(playerID string, durationSec int, autoReveal bool) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Only host can start timer
-	if r.HostID != playerID {
+	// Only host or co-hosts can start timer
+	if !r.canModerate(playerID) {
 		return false
 	}
 
@@ -367,13 +710,13 @@ func (r *Room) StartTimer(playerID string, durationSec int, autoReveal bool) boo
 	return true
 }
 
-// StopTimer stops the current timer (host only)
+// StopTimer stops the current timer (host or co-host)
 func (r *Room) StopTimer(playerID string) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Only host can stop timer
-	if r.HostID != playerID {
+	// Only host or co-hosts can stop timer
+	if !r.canModerate(playerID) {
 		return false
 	}
 
@@ -403,21 +746,105 @@ func (r *Room) ClearTimer() {
 	r.timerCancel = nil
 }
 
-// SetIssue sets the current Jira issue (host only)
+// SetIssue sets the current Jira issue (host or co-host)
 func (r *Room) SetIssue(playerID string, issue *models.JiraIssue) bool {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Only host can set issue
-	if r.HostID != playerID {
+	// Only host or co-hosts can set issue
+	if !r.canModerate(playerID) {
 		return false
 	}
 
 	r.CurrentIssue = issue
+	if issue != nil {
+		r.Queue = removeIssue(r.Queue, issue.Key)
+	}
+	r.LastActive = time.Now()
+	return true
+}
+
+// GetIssue returns the issue being estimated, nil if none
+func (r *Room) GetIssue() *models.JiraIssue {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.CurrentIssue
+}
+
+// SetQueue replaces the issues waiting to be estimated (host or co-host). If
+// no issue is being estimated, the first one becomes the current issue.
+func (r *Room) SetQueue(playerID string, issues []models.JiraIssue) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.canModerate(playerID) {
+		return false
+	}
+
+	r.Queue = append([]models.JiraIssue(nil), issues[:min(len(issues), MaxQueue)]...)
+	if r.CurrentIssue != nil {
+		r.Queue = removeIssue(r.Queue, r.CurrentIssue.Key)
+	} else if len(r.Queue) > 0 {
+		next := r.Queue[0]
+		r.CurrentIssue = &next
+		r.Queue = r.Queue[1:]
+	}
 	r.LastActive = time.Now()
 	return true
 }
 
+// UpdateIssue replaces the details of the issue being estimated or queued
+// with the issue's key, after it changed in Jira. Queued issues are kept
+// without their HTML fields, like issues loaded into the queue. It reports
+// whether the room has the issue.
+func (r *Room) UpdateIssue(issue models.JiraIssue) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := false
+	if r.CurrentIssue != nil && r.CurrentIssue.Key == issue.Key {
+		current := issue
+		r.CurrentIssue = &current
+		found = true
+	}
+	for i := range r.Queue {
+		if r.Queue[i].Key == issue.Key {
+			r.Queue[i] = issue.WithoutMarkup()
+			r.Queue[i].URL = issue.URL // Built by the server's Jira client
+			found = true
+		}
+	}
+	return found
+}
+
+// RemoveIssue drops an issue deleted in Jira from the queue, and stops
+// estimating it if it is the current issue. It reports whether the room had
+// the issue.
+func (r *Room) RemoveIssue(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := false
+	if r.CurrentIssue != nil && r.CurrentIssue.Key == key {
+		r.CurrentIssue = nil
+		found = true
+	}
+	queued := len(r.Queue)
+	r.Queue = removeIssue(r.Queue, key)
+	return found || len(r.Queue) < queued
+}
+
+// removeIssue returns the queue without the issue with the given key
+func removeIssue(queue []models.JiraIssue, key string) []models.JiraIssue {
+	kept := queue[:0]
+	for _, issue := range queue {
+		if issue.Key != key {
+			kept = append(kept, issue)
+		}
+	}
+	return kept
+}
+
 // GetScale returns the room's voting scale
 func (r *Room) GetScale() *models.VotingScale {
 	r.mu.RLock()
@@ -425,6 +852,21 @@ func (r *Room) GetScale() *models.VotingScale {
 	return r.Scale
 }
 
+// GetJiraActions returns what is done in Jira when an estimate is saved
+func (r *Room) GetJiraActions() models.JiraActions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.JiraActions
+}
+
+// SetJiraActions sets what is done in Jira when an estimate is saved
+func (r *Room) SetJiraActions(actions models.JiraActions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.JiraActions = actions
+	r.LastActive = time.Now()
+}
+
 // SetScale sets the room's voting scale
 func (r *Room) SetScale(scale *models.VotingScale) {
 	r.mu.Lock()
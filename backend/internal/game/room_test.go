@@ -2,7 +2,9 @@ package game
 
 import (
 	"testing"
+	"time"
 
+	"github.com/poker/backend/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,19 +18,26 @@ func TestRoom_MaxPlayers(t *testing.T) {
 	// Looking at AddPlayer implementation: it assigns avatar.
 
 	// Create max players
-	for i := 0; i < MaxPlayers; i++ {
+	for i := 0; i < DefaultMaxPlayers; i++ {
 		p := NewPlayer("p"+string(rune(i)), "Player", "", nil, false)
 		success := room.AddPlayer(p)
 		assert.True(t, success, "Should be able to add player %d", i)
 	}
 
 	// Verify count
-	assert.Equal(t, MaxPlayers, room.PlayerCount())
+	assert.Equal(t, DefaultMaxPlayers, room.PlayerCount())
 
 	// Try to add one more
 	p := NewPlayer("excess", "Excess", "", nil, false)
 	success := room.AddPlayer(p)
 	assert.False(t, success, "Should not be able to add player above limit")
+
+	// A configured limit replaces the default
+	small := NewRoom("SMALL", 24)
+	small.MaxPlayers = 2
+	assert.True(t, small.AddPlayer(NewPlayer("a", "A", "", nil, false)))
+	assert.True(t, small.AddPlayer(NewPlayer("b", "B", "", nil, false)))
+	assert.False(t, small.AddPlayer(NewPlayer("c", "C", "", nil, false)))
 }
 
 func TestRoom_AddRemovePlayer(t *testing.T) {
@@ -163,3 +172,258 @@ func TestRoom_GetVotingResults(t *testing.T) {
 	// Average calculation (3+5)/2 = 4. '?' is ignored.
 	assert.Equal(t, float64(4), results.Average)
 }
+
+func TestRoom_Passphrase(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	assert.False(t, room.HasPassphrase())
+	assert.True(t, room.CheckPassphrase(""))
+
+	room.SetPassphrase("secret")
+	assert.True(t, room.HasPassphrase())
+	assert.NotEqual(t, "secret", room.PassphraseHash)
+	assert.True(t, room.CheckPassphrase("secret"))
+	assert.False(t, room.CheckPassphrase("wrong"))
+	assert.False(t, room.CheckPassphrase(""))
+
+	// Hashes are salted
+	hash := room.PassphraseHash
+	room.SetPassphrase("secret")
+	assert.NotEqual(t, hash, room.PassphraseHash)
+	assert.True(t, room.CheckPassphrase("secret"))
+}
+
+func TestRoom_ResumeDetachPlayer(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	p1, client1 := createTestPlayer(t, "p1", "Player 1")
+	defer client1.Close()
+	room.AddPlayer(p1)
+	oldConn := p1.Conn
+
+	// Unknown sessions do not match
+	player, _ := room.ResumePlayer("unknown", nil)
+	assert.Nil(t, player)
+
+	p2, client2 := createTestPlayer(t, "p2", "Player 1")
+	defer client2.Close()
+	player, prev := room.ResumePlayer(p1.SessionToken, p2.Conn)
+	assert.Equal(t, p1, player)
+	assert.Equal(t, oldConn, prev)
+
+	// The superseded connection no longer removes the player
+	assert.False(t, room.DetachPlayer(p1.ID, oldConn))
+	assert.Equal(t, 1, room.PlayerCount())
+
+	assert.True(t, room.DetachPlayer(p1.ID, p2.Conn))
+	assert.True(t, room.IsEmpty())
+}
+
+func TestRoom_HostToken(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	p1, client1 := createTestPlayer(t, "p1", "Player 1")
+	defer client1.Close()
+	p2, client2 := createTestPlayer(t, "p2", "Player 2")
+	defer client2.Close()
+	room.AddPlayer(p1)
+	room.AddPlayer(p2)
+
+	// The first player is host but has not proved the token
+	assert.True(t, room.IsHost(p1.ID))
+	assert.False(t, room.HoldsHostToken(p1.ID))
+
+	// No token issued yet
+	assert.False(t, room.ClaimHost(p2.ID, ""))
+
+	token, _ := room.IssueHostToken(0)
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, token, room.HostTokenHash)
+	assert.Nil(t, room.HostTokenExpiry)
+
+	assert.False(t, room.ClaimHost(p2.ID, "wrong"))
+	assert.True(t, room.ClaimHost(p2.ID, token))
+	assert.Equal(t, p2.ID, room.HostID)
+	assert.False(t, p1.IsHost)
+	assert.True(t, room.HoldsHostToken(p2.ID))
+
+	// Rotation invalidates the previous token
+	rotated, _ := room.IssueHostToken(0)
+	assert.False(t, room.ClaimHost(p1.ID, token))
+	assert.True(t, room.ClaimHost(p1.ID, rotated))
+	assert.False(t, room.HoldsHostToken(p2.ID))
+
+	// A player promoted because the host left does not hold the token
+	room.RemovePlayer(p1.ID)
+	assert.True(t, room.IsHost(p2.ID))
+	assert.False(t, room.HoldsHostToken(p2.ID))
+	room.AddPlayer(p1)
+
+	// Expired tokens are rejected
+	expiring, expiry := room.IssueHostToken(time.Hour)
+	assert.NotNil(t, expiry)
+	assert.Equal(t, room.HostTokenExpiry, expiry)
+	past := time.Now().Add(-time.Minute)
+	room.HostTokenExpiry = &past
+	assert.False(t, room.ClaimHost(p2.ID, expiring))
+}
+
+func TestRoom_Facilitator(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	host, client1 := createTestPlayer(t, "host", "Host")
+	defer client1.Close()
+	p2, client2 := createTestPlayer(t, "p2", "Facilitator")
+	defer client2.Close()
+	room.AddPlayer(host)
+	room.AddPlayer(p2)
+
+	assert.False(t, room.ClaimFacilitator(p2.ID, "anything"))
+	assert.False(t, room.Reveal(p2.ID))
+
+	token := room.IssueFacilitatorToken()
+	assert.True(t, room.ClaimFacilitator(p2.ID, token))
+	assert.True(t, p2.IsCoHost)
+	assert.True(t, room.CanModerate(p2.ID))
+	assert.False(t, room.IsHost(p2.ID))
+	assert.True(t, room.Reveal(p2.ID))
+	assert.True(t, room.StartTimer(p2.ID, 30, false))
+	assert.True(t, room.StopTimer(p2.ID))
+
+	room.RevokeFacilitators()
+	assert.False(t, p2.IsCoHost)
+	assert.False(t, room.CanModerate(p2.ID))
+	assert.False(t, room.ClaimFacilitator(p2.ID, token))
+}
+
+func TestRoomFromSnapshot_LegacyHostToken(t *testing.T) {
+	legacy := "3f2b8c1e-5d4a-4e7b-9c6f-1a2b3c4d5e6f"
+	room := RoomFromSnapshot(&RoomSnapshot{Code: "TEST", HostTokenHash: legacy, ExpiryHours: 24})
+	assert.Equal(t, hashSecret(legacy), room.HostTokenHash)
+
+	// Already hashed values are kept as they are
+	restored := RoomFromSnapshot(room.Snapshot())
+	assert.Equal(t, room.HostTokenHash, restored.HostTokenHash)
+
+	p, client := createTestPlayer(t, "p1", "Player 1")
+	defer client.Close()
+	restored.AddPlayer(p)
+	assert.True(t, restored.ClaimHost(p.ID, legacy))
+}
+
+func TestRoomFromSnapshot_LegacyPassphrase(t *testing.T) {
+	room := RoomFromSnapshot(&RoomSnapshot{Code: "TEST", PassphraseHash: hashSecret("sprint"), ExpiryHours: 24})
+	assert.NotEqual(t, hashSecret("sprint"), room.PassphraseHash)
+	assert.True(t, room.CheckPassphrase("sprint"))
+	assert.False(t, room.CheckPassphrase("wrong"))
+
+	// Upgraded hashes are kept as they are
+	restored := RoomFromSnapshot(room.Snapshot())
+	assert.Equal(t, room.PassphraseHash, restored.PassphraseHash)
+}
+
+func TestRoomFromSnapshot_SessionTokens(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	p, client := createTestPlayer(t, "p1", "Player 1")
+	defer client.Close()
+	room.AddPlayer(p)
+
+	// Snapshots hold only the hash of the session token
+	snapshot := room.Snapshot()
+	assert.Equal(t, hashSecret(p.SessionToken), snapshot.Players[0].SessionHash)
+	assert.NotContains(t, snapshot.Players[0].SessionHash, p.SessionToken)
+
+	restored := RoomFromSnapshot(snapshot)
+	player, _ := restored.ResumePlayer(p.SessionToken, nil)
+	assert.Equal(t, p.ID, player.ID)
+	assert.Equal(t, p.SessionToken, player.SessionToken)
+
+	// Tokens stored verbatim by older versions are hashed on the way in
+	legacy := "3f2b8c1e-5d4a-4e7b-9c6f-1a2b3c4d5e6f"
+	snapshot.Players[0].SessionHash = legacy
+	restored = RoomFromSnapshot(snapshot)
+	player, _ = restored.ResumePlayer(legacy, nil)
+	assert.Equal(t, p.ID, player.ID)
+}
+
+func TestRoom_History(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	p1, client1 := createTestPlayer(t, "p1", "Ada")
+	defer client1.Close()
+	p2, client2 := createTestPlayer(t, "p2", "Ada")
+	defer client2.Close()
+	room.AddPlayer(p1)
+	room.AddPlayer(p2)
+
+	// Rounds without votes are not recorded
+	assert.True(t, room.Reveal(p1.ID))
+	assert.Empty(t, room.GetHistory())
+	room.Reset()
+
+	room.SetIssue(p1.ID, &models.JiraIssue{Key: "PAY-1"})
+	room.Vote(p1.ID, "3")
+	room.Vote(p2.ID, "5")
+	assert.True(t, room.Reveal(p1.ID))
+	assert.True(t, room.Reveal(p1.ID)) // Revealing twice records one round
+
+	history := room.GetHistory()
+	assert.Len(t, history, 1)
+	assert.Equal(t, "PAY-1", history[0].Issue.Key)
+	// Players sharing a name keep their own votes
+	assert.Equal(t, map[string]models.RoundVote{p1.ID: {Name: "Ada", Vote: "3"}, p2.ID: {Name: "Ada", Vote: "5"}}, history[0].Votes)
+	assert.Equal(t, float64(4), history[0].Average)
+
+	// Timer auto-reveal records the round once
+	room.Reset()
+	room.Vote(p2.ID, "8")
+	assert.True(t, room.AutoReveal())
+	assert.False(t, room.AutoReveal())
+	assert.Len(t, room.GetHistory(), 2)
+	assert.Len(t, room.GetState(p1.ID).History, 2)
+}
+
+func TestRoom_Queue(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	host, client1 := createTestPlayer(t, "p1", "Ada")
+	defer client1.Close()
+	guest, client2 := createTestPlayer(t, "p2", "Grace")
+	defer client2.Close()
+	room.AddPlayer(host)
+	room.AddPlayer(guest)
+
+	issues := []models.JiraIssue{{Key: "PAY-1"}, {Key: "PAY-2"}, {Key: "PAY-3"}}
+	assert.False(t, room.SetQueue(guest.ID, issues))
+
+	// With no current issue the first one is taken off the queue
+	assert.True(t, room.SetQueue(host.ID, issues))
+	assert.Equal(t, "PAY-1", room.CurrentIssue.Key)
+	assert.Equal(t, []models.JiraIssue{{Key: "PAY-2"}, {Key: "PAY-3"}}, room.Queue)
+
+	// Setting a queued issue dequeues it
+	room.SetIssue(host.ID, &models.JiraIssue{Key: "PAY-3"})
+	assert.Equal(t, []models.JiraIssue{{Key: "PAY-2"}}, room.Queue)
+
+	// Reloading keeps the current issue and leaves it out of the queue
+	assert.True(t, room.SetQueue(host.ID, issues))
+	assert.Equal(t, "PAY-3", room.CurrentIssue.Key)
+	assert.Equal(t, []models.JiraIssue{{Key: "PAY-1"}, {Key: "PAY-2"}}, room.Queue)
+	assert.Len(t, issues, 3, "the caller's slice is not modified")
+}
+
+func TestRoom_UpdateRemoveIssue(t *testing.T) {
+	room := NewRoom("TEST", 24)
+	host, client := createTestPlayer(t, "p1", "Ada")
+	defer client.Close()
+	room.AddPlayer(host)
+	room.SetQueue(host.ID, []models.JiraIssue{{Key: "PAY-1", Summary: "Old"}, {Key: "PAY-2", Summary: "Old"}})
+
+	updated := models.JiraIssue{Key: "PAY-2", Summary: "New", Description: "<p>Details</p>", URL: "https://jira.example.com/browse/PAY-2"}
+	assert.True(t, room.UpdateIssue(updated))
+	assert.Equal(t, []models.JiraIssue{{Key: "PAY-2", Summary: "New", URL: updated.URL}}, room.Queue, "queued issues have no markup")
+	updated.Key = "PAY-1"
+	assert.True(t, room.UpdateIssue(updated))
+	assert.Equal(t, updated, *room.CurrentIssue)
+	assert.False(t, room.UpdateIssue(models.JiraIssue{Key: "PAY-9"}))
+
+	assert.True(t, room.RemoveIssue("PAY-2"))
+	assert.Empty(t, room.Queue)
+	assert.True(t, room.RemoveIssue("PAY-1"))
+	assert.Nil(t, room.CurrentIssue)
+	assert.False(t, room.RemoveIssue("PAY-1"))
+}
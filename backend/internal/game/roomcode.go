@@ -0,0 +1,199 @@
+package game
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Room code styles
+const (
+	CodeStyleRandom = "random" // Characters drawn from an alphabet, e.g. "K7QMX2PD"
+	CodeStyleWords  = "words"  // Dictionary words, e.g. "CANYON-LASSO-MESA-RODEO"
+)
+
+const (
+	// DefaultCodeAlphabet omits characters that are easily confused (0/O, 1/I/L)
+	DefaultCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	DefaultCodeLength   = 8 // ~8.5e11 codes with the default alphabet
+	DefaultCodeWords    = 4 // ~5.2e9 codes with the built-in word list
+
+	minCodeLength = 4
+	maxCodeLength = 32
+)
+
+// Vanity room name limits
+const (
+	minRoomNameLength = 3
+	maxRoomNameLength = 32
+)
+
+// ErrInvalidRoomName is returned for vanity names that are not valid room codes
+var ErrInvalidRoomName = errors.New("room names must be 3-32 letters, digits or dashes and start with a letter")
+
+// NormalizeRoomName validates a vanity room name (e.g. "payments") and returns
+// it as an upper case room code ("PAYMENTS")
+func NormalizeRoomName(name string) (string, error) {
+	return normalizeCode(name, true)
+}
+
+// NormalizeRoomCode validates a room code of any style, generated or vanity,
+// and returns it upper cased. Unlike vanity names, codes may start with a digit.
+func NormalizeRoomCode(code string) (string, error) {
+	return normalizeCode(code, false)
+}
+
+func normalizeCode(code string, letterFirst bool) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < minRoomNameLength || len(code) > maxRoomNameLength {
+		return "", ErrInvalidRoomName
+	}
+	for i, c := range code {
+		switch {
+		case c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && (i > 0 || !letterFirst):
+		case c == '-' && i > 0:
+		default:
+			return "", ErrInvalidRoomName
+		}
+	}
+	if strings.HasSuffix(code, "-") || strings.Contains(code, "--") {
+		return "", ErrInvalidRoomName
+	}
+	return code, nil
+}
+
+// CodeGenerator produces new room codes. Codes must be upper case, since
+// lookups are case-insensitive.
+type CodeGenerator interface {
+	Generate() string
+}
+
+// NewCodeGenerator creates a generator for the given style. For random codes
+// length is the number of characters, for word codes the number of words.
+func NewCodeGenerator(style string, length int, alphabet string) (CodeGenerator, error) {
+	switch style {
+	case "", CodeStyleRandom:
+		if length == 0 {
+			length = DefaultCodeLength
+		}
+		if alphabet == "" {
+			alphabet = DefaultCodeAlphabet
+		}
+		if length < minCodeLength || length > maxCodeLength {
+			return nil, fmt.Errorf("room code length must be between %d and %d", minCodeLength, maxCodeLength)
+		}
+		symbols, err := uniqueSymbols(strings.ToUpper(alphabet))
+		if err != nil {
+			return nil, err
+		}
+		if len(symbols) < 2 {
+			return nil, fmt.Errorf("room code alphabet needs at least 2 distinct characters")
+		}
+		return &randomCodes{alphabet: symbols, length: length}, nil
+
+	case CodeStyleWords:
+		if length == 0 {
+			length = DefaultCodeWords
+		}
+		if length < 2 || length > 8 {
+			return nil, fmt.Errorf("word room codes must have between 2 and 8 words")
+		}
+		return &wordCodes{words: length}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown room code style: %s", style)
+	}
+}
+
+// DefaultCodeGenerator returns the random generator with default settings
+func DefaultCodeGenerator() CodeGenerator {
+	return &randomCodes{alphabet: []rune(DefaultCodeAlphabet), length: DefaultCodeLength}
+}
+
+type randomCodes struct {
+	alphabet []rune
+	length   int
+}
+
+func (g *randomCodes) Generate() string {
+	code := make([]rune, g.length)
+	for i := range code {
+		code[i] = g.alphabet[randomIndex(len(g.alphabet))]
+	}
+	return string(code)
+}
+
+type wordCodes struct {
+	words int
+}
+
+func (g *wordCodes) Generate() string {
+	parts := make([]string, g.words)
+	for i := range parts {
+		parts[i] = codeWords[randomIndex(len(codeWords))]
+	}
+	return strings.Join(parts, "-")
+}
+
+// randomIndex returns a uniformly distributed index in [0, n)
+func randomIndex(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return int(i.Int64())
+}
+
+// uniqueSymbols returns the distinct runes of an alphabet, keeping their order.
+// Only A-Z and 0-9 are allowed so codes stay URL-safe.
+func uniqueSymbols(alphabet string) ([]rune, error) {
+	seen := make(map[rune]bool)
+	var symbols []rune
+	for _, r := range alphabet {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return nil, fmt.Errorf("room code alphabet may only contain A-Z and 0-9, got %q", r)
+		}
+		if !seen[r] {
+			seen[r] = true
+			symbols = append(symbols, r)
+		}
+	}
+	return symbols, nil
+}
+
+// codeWords is the dictionary for word-based room codes
+var codeWords = []string{
+	"ACORN", "ADOBE", "AGATE", "ALAMO", "AMBER", "ANVIL", "APRON", "ARROW", "ASPEN",
+	"BADGE", "BANDANA", "BANJO", "BARN", "BARREL", "BASIN", "BAYOU", "BEACON", "BEAN",
+	"BEAR", "BELL", "BISON", "BLANKET", "BLAZE", "BLUFF", "BOBCAT", "BOOT", "BOULDER",
+	"BRAND", "BRASS", "BRIDLE", "BRONCO", "BROOK", "BUCKLE", "BUFFALO", "BUGLE", "BULL",
+	"BURRO", "BUTTE", "CABIN", "CACTUS", "CAMPFIRE", "CANDLE", "CANOE", "CANYON", "CARAVAN",
+	"CARGO", "CATTLE", "CEDAR", "CHAPS", "CHISEL", "CINDER", "CLAY", "CLIFF", "CLOVER",
+	"COAL", "COBALT", "COLT", "COMET", "COMPASS", "CONDOR", "COPPER", "CORAL", "CORRAL",
+	"COUGAR", "COYOTE", "CRANE", "CREEK", "CROW", "CROWN", "CRYSTAL", "DAGGER", "DAISY",
+	"DAWN", "DEER", "DELTA", "DEPUTY", "DESERT", "DIAMOND", "DOLLAR", "DOVE", "DRIFT",
+	"DRUM", "DUNE", "DUST", "EAGLE", "EARTH", "EMBER", "FALCON", "FARM", "FEATHER", "FENCE",
+	"FERN", "FIDDLE", "FIELD", "FIRE", "FIREFLY", "FLINT", "FORGE", "FORT", "FOX",
+	"FRONTIER", "GALE", "GALLOP", "GARNET", "GECKO", "GEYSER", "GLACIER", "GOLD", "GORGE",
+	"GRAIN", "GRANITE", "GRAVEL", "GROVE", "GULCH", "HAMMER", "HARBOR", "HARVEST",
+	"HATCHET", "HAWK", "HAZEL", "HERON", "HICKORY", "HILL", "HOLSTER", "HONEY", "HORIZON",
+	"HORSE", "HOWL", "IRON", "IVORY", "JACKAL", "JADE", "JASPER", "JUNIPER", "KESTREL",
+	"KETTLE", "LAKE", "LANCE", "LANTERN", "LARIAT", "LASSO", "LEATHER", "LEDGE", "LIZARD",
+	"LOCKET", "LODGE", "LOOM", "MAPLE", "MARSH", "MARSHAL", "MEADOW", "MEDAL", "MESA",
+	"MINER", "MIRAGE", "MOOSE", "MOSS", "MOTH", "MOUNTAIN", "MULE", "MUSTANG", "NOMAD",
+	"NUGGET", "OAK", "OASIS", "ONYX", "ORCHARD", "OTTER", "OUTPOST", "OWL", "PAINT",
+	"PANTHER", "PARDNER", "PASS", "PEAK", "PEBBLE", "PECAN", "PELICAN", "PEPPER", "PINE",
+	"PIONEER", "PISTOL", "PLAINS", "PLUME", "PONY", "POPPY", "POST", "PRAIRIE", "PRANCE",
+	"QUAIL", "QUARRY", "QUARTZ", "QUILL", "RAIL", "RAIN", "RANCH", "RANGE", "RATTLER",
+	"RAVEN", "RIDGE", "RIVER", "RIVET", "ROAD", "ROCK", "RODEO", "ROPE", "ROSE", "RUBY",
+	"RUST", "SADDLE", "SAGE", "SALOON", "SAND", "SAPPHIRE", "SCOUT", "SERAPE", "SETTLER",
+	"SHADOW", "SHERIFF", "SIERRA", "SILVER", "SKILLET", "SKY", "SMOKE", "SNAKE", "SPARROW",
+	"SPIRIT", "SPRING", "SPRUCE", "SPUR", "STAGE", "STALLION", "STAR", "STEEL", "STETSON",
+	"STONE", "STORM", "STREAM", "SUMMIT", "SUN", "TALON", "THISTLE", "THUNDER", "TIMBER",
+	"TOPAZ", "TRACK", "TRADER", "TRAIL", "TRAIN", "TUMBLE", "TURQUOISE", "VALLEY", "VELVET",
+	"VIPER", "WAGON", "WALNUT", "WATER", "WELL", "WHEAT", "WHEEL", "WHISTLE", "WILLOW",
+	"WIND", "WOLF", "WOOD", "WRANGLER", "WREN", "YUCCA", "ZEPHYR",
+}
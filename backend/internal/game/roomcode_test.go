@@ -0,0 +1,91 @@
+package game
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCodeGenerator_Random(t *testing.T) {
+	gen, err := NewCodeGenerator(CodeStyleRandom, 0, "")
+	assert.Nil(t, err)
+
+	code := gen.Generate()
+	assert.Len(t, code, DefaultCodeLength)
+	assert.Regexp(t, regexp.MustCompile("^["+DefaultCodeAlphabet+"]+$"), code)
+
+	gen, err = NewCodeGenerator(CodeStyleRandom, 5, "ab")
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[AB]{5}$"), gen.Generate())
+
+	_, err = NewCodeGenerator(CodeStyleRandom, 2, "")
+	assert.NotNil(t, err, "too short")
+	_, err = NewCodeGenerator(CodeStyleRandom, 8, "A")
+	assert.NotNil(t, err, "single character alphabet")
+	_, err = NewCodeGenerator(CodeStyleRandom, 8, "AB/")
+	assert.NotNil(t, err, "not URL-safe")
+	_, err = NewCodeGenerator("emoji", 8, "")
+	assert.NotNil(t, err)
+}
+
+func TestNewCodeGenerator_Words(t *testing.T) {
+	gen, err := NewCodeGenerator(CodeStyleWords, 3, "")
+	assert.Nil(t, err)
+
+	parts := strings.Split(gen.Generate(), "-")
+	assert.Len(t, parts, 3)
+	for _, p := range parts {
+		assert.Contains(t, codeWords, p)
+	}
+
+	_, err = NewCodeGenerator(CodeStyleWords, 1, "")
+	assert.NotNil(t, err)
+}
+
+func TestCodeWords_Unique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, w := range codeWords {
+		assert.False(t, seen[w], "duplicate word %s", w)
+		assert.Equal(t, strings.ToUpper(w), w)
+		seen[w] = true
+	}
+	assert.GreaterOrEqual(t, len(codeWords), 256)
+}
+
+func TestHub_CodeGenerator(t *testing.T) {
+	gen, _ := NewCodeGenerator(CodeStyleWords, 2, "")
+	hub := NewHubWithConfig(HubConfig{DefaultExpiry: 24, Codes: gen}, nil)
+	defer hub.Stop()
+
+	room := hub.CreateRoom(1)
+	assert.Len(t, strings.Split(room.Code, "-"), 2)
+	assert.Equal(t, room, hub.GetRoom(strings.ToLower(room.Code)))
+}
+
+func TestNormalizeRoomName(t *testing.T) {
+	code, err := NormalizeRoomName(" payments ")
+	assert.Nil(t, err)
+	assert.Equal(t, "PAYMENTS", code)
+
+	code, err = NormalizeRoomName("team-42")
+	assert.Nil(t, err)
+	assert.Equal(t, "TEAM-42", code)
+
+	for _, name := range []string{"", "ab", "42TEAM", "-TEAM", "TEAM-", "TEAM--A", "TEAM A", "TÉAM", strings.Repeat("A", 33)} {
+		_, err := NormalizeRoomName(name)
+		assert.Equal(t, ErrInvalidRoomName, err, name)
+	}
+}
+
+func TestNormalizeRoomCode(t *testing.T) {
+	code, err := NormalizeRoomCode("7k3mx2pd")
+	assert.Nil(t, err)
+	assert.Equal(t, "7K3MX2PD", code)
+
+	for _, name := range []string{"", "ab", "-TEAM", "TEAM-", "TEAM--A", "TEAM A", strings.Repeat("A", 33)} {
+		_, err := NormalizeRoomCode(name)
+		assert.Equal(t, ErrInvalidRoomName, err, name)
+	}
+}
@@ -0,0 +1,69 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newSecretToken returns a random URL-safe token with 256 bits of entropy
+func newSecretToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashSecret returns the hex-encoded SHA-256 digest of a secret
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// secretMatches compares a candidate secret against a stored hash in constant time
+func secretMatches(candidate, hash string) bool {
+	if candidate == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashSecret(candidate)), []byte(hash)) == 1
+}
+
+// hashPassphrase returns a salted bcrypt hash of a passphrase. Passphrases are
+// chosen by people and easy to guess, so unlike random tokens they get a slow
+// hash. It is taken of the passphrase's SHA-256 digest, which keeps long
+// passphrases within bcrypt's 72 bytes and lets the digests older versions
+// stored be upgraded without knowing the passphrase.
+func hashPassphrase(passphrase string) string {
+	return hashPassphraseDigest(hashSecret(passphrase))
+}
+
+func hashPassphraseDigest(digest string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(digest), bcrypt.DefaultCost)
+	if err != nil {
+		panic("bcrypt failed: " + err.Error())
+	}
+	return string(hash)
+}
+
+// passphraseMatches compares a candidate passphrase against a hash from hashPassphrase
+func passphraseMatches(candidate, hash string) bool {
+	if candidate == "" || hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(hashSecret(candidate))) == nil
+}
+
+// isSecretHash reports whether s looks like a value produced by hashSecret.
+// Older databases stored host tokens verbatim (UUIDs), which never match.
+func isSecretHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,170 @@
+package game
+
+import (
+	"sort"
+	"time"
+
+	"github.com/poker/backend/internal/models"
+)
+
+// RoomSnapshot is a point-in-time copy of a room's durable state. Stores
+// save and load snapshots rather than live rooms, so they never touch a
+// room's lock or connections.
+type RoomSnapshot struct {
+	Code            string               `json:"code"`
+	HostID          string               `json:"hostId"`
+	HostTokenHash   string               `json:"hostTokenHash,omitempty"`
+	HostTokenExpiry *time.Time           `json:"hostTokenExpiry,omitempty"`
+	FacilitatorHash string               `json:"facilitatorHash,omitempty"`
+	PassphraseHash  string               `json:"passphraseHash,omitempty"`
+	CreatedAt       time.Time            `json:"createdAt"`
+	LastActive      time.Time            `json:"lastActive"`
+	ExpiryHours     int                  `json:"expiryHours"`
+	Scale           models.VotingScale   `json:"scale"`
+	TimerEndTime    *time.Time           `json:"timerEndTime,omitempty"`
+	TimerAutoReveal bool                 `json:"timerAutoReveal"`
+	Revealed        bool                 `json:"revealed"`
+	CurrentIssue    *models.JiraIssue    `json:"currentIssue,omitempty"`
+	Queue           []models.JiraIssue   `json:"queue,omitempty"`
+	JiraActions     models.JiraActions   `json:"jiraActions"`
+	Persistent      bool                 `json:"persistent"`
+	History         []models.RoundResult `json:"history,omitempty"`
+	Players         []PlayerSnapshot     `json:"players"`
+}
+
+// PlayerSnapshot is the durable state of a player. Connections are not part
+// of it; restored players are offline until they resume their session.
+type PlayerSnapshot struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Avatar      string            `json:"avatar"`
+	Role        models.PlayerRole `json:"role"`
+	SessionHash string            `json:"sessionHash,omitempty"`
+	IsHost      bool              `json:"isHost"`
+	IsCoHost    bool              `json:"isCoHost"`
+	Vote        string            `json:"vote,omitempty"`
+	HasVoted    bool              `json:"hasVoted"`
+}
+
+// Snapshot returns a copy of the room's durable state. Players are ordered by
+// ID so snapshots of the same room compare equal.
+func (r *Room) Snapshot() *RoomSnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := &RoomSnapshot{
+		Code:            r.Code,
+		HostID:          r.HostID,
+		HostTokenHash:   r.HostTokenHash,
+		HostTokenExpiry: copyTime(r.HostTokenExpiry),
+		FacilitatorHash: r.FacilitatorHash,
+		PassphraseHash:  r.PassphraseHash,
+		CreatedAt:       r.CreatedAt,
+		LastActive:      r.LastActive,
+		ExpiryHours:     r.ExpiryHours,
+		TimerEndTime:    copyTime(r.TimerEndTime),
+		TimerAutoReveal: r.TimerAutoReveal,
+		Revealed:        r.Revealed,
+		Queue:           append([]models.JiraIssue(nil), r.Queue...),
+		JiraActions:     r.JiraActions,
+		Persistent:      r.Persistent,
+		History:         append([]models.RoundResult(nil), r.History...),
+		Players:         make([]PlayerSnapshot, 0, len(r.Players)),
+	}
+	if r.Scale != nil {
+		s.Scale = r.Scale.Clone()
+	}
+	if r.CurrentIssue != nil {
+		issue := *r.CurrentIssue
+		s.CurrentIssue = &issue
+	}
+
+	for _, p := range r.Players {
+		s.Players = append(s.Players, PlayerSnapshot{
+			ID:          p.ID,
+			Name:        p.Name,
+			Avatar:      p.Avatar,
+			Role:        p.Role,
+			SessionHash: p.SessionHash,
+			IsHost:      p.IsHost,
+			IsCoHost:    p.IsCoHost,
+			Vote:        p.Vote,
+			HasVoted:    p.HasVoted,
+		})
+	}
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i].ID < s.Players[j].ID })
+
+	return s
+}
+
+// RoomFromSnapshot reconstructs a room from a snapshot. A scale without values
+// is resolved from the presets, host and session tokens stored verbatim by
+// older versions are hashed on the way in, and passphrase digests are rehashed.
+func RoomFromSnapshot(s *RoomSnapshot) *Room {
+	scale := s.Scale
+	if len(scale.Values) == 0 {
+		preset, ok := models.PresetScales[scale.Type]
+		if !ok {
+			preset = models.PresetScales[models.ScaleFibonacci]
+		}
+		scale = preset
+	}
+
+	hostTokenHash := s.HostTokenHash
+	if hostTokenHash != "" && !isSecretHash(hostTokenHash) {
+		hostTokenHash = hashSecret(hostTokenHash)
+	}
+
+	// Older versions stored the passphrase's bare SHA-256 digest
+	passphraseHash := s.PassphraseHash
+	if isSecretHash(passphraseHash) {
+		passphraseHash = hashPassphraseDigest(passphraseHash)
+	}
+
+	room := &Room{
+		Code:            s.Code,
+		Players:         make(map[string]*Player),
+		Revealed:        s.Revealed,
+		HostID:          s.HostID,
+		HostTokenHash:   hostTokenHash,
+		HostTokenExpiry: copyTime(s.HostTokenExpiry),
+		FacilitatorHash: s.FacilitatorHash,
+		PassphraseHash:  passphraseHash,
+		CreatedAt:       s.CreatedAt,
+		LastActive:      s.LastActive,
+		ExpiryHours:     s.ExpiryHours,
+		Scale:           &scale,
+		TimerEndTime:    copyTime(s.TimerEndTime),
+		TimerAutoReveal: s.TimerAutoReveal,
+		CurrentIssue:    s.CurrentIssue,
+		Queue:           append([]models.JiraIssue(nil), s.Queue...),
+		JiraActions:     s.JiraActions,
+		Persistent:      s.Persistent,
+		History:         append([]models.RoundResult(nil), s.History...),
+		usedAvatars:     make(map[string]bool),
+	}
+
+	for _, ps := range s.Players {
+		p := NewPlayer(ps.ID, ps.Name, ps.Avatar, nil, ps.IsHost)
+		p.Role = ps.Role
+		p.SessionToken = "" // Only the client knows it
+		p.SessionHash = ps.SessionHash
+		if p.SessionHash != "" && !isSecretHash(p.SessionHash) {
+			p.SessionHash = hashSecret(p.SessionHash)
+		}
+		p.IsCoHost = ps.IsCoHost
+		p.Vote = ps.Vote
+		p.HasVoted = ps.HasVoted
+		room.RestorePlayer(p)
+	}
+
+	return room
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/poker/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomSnapshot_RoundTrip(t *testing.T) {
+	room := NewRoomWithScale("TEST", 24, models.ScaleTShirt)
+	room.Persistent = true
+	room.SetPassphrase("sprint")
+	hostToken, _ := room.IssueHostToken(time.Hour)
+	room.RestorePlayer(NewPlayer("p2", "Grace", "outlaw", nil, false))
+	room.RestorePlayer(NewPlayer("p1", "Ada", "sheriff", nil, true))
+	room.HostID = "p1"
+	room.SetIssue("p1", &models.JiraIssue{Key: "PAY-1", Summary: "Checkout"})
+	room.Vote("p1", "M")
+	room.Reveal("p1")
+
+	snapshot := room.Snapshot()
+	assert.Equal(t, []string{"p1", "p2"}, []string{snapshot.Players[0].ID, snapshot.Players[1].ID})
+
+	restored := RoomFromSnapshot(snapshot)
+	assert.Equal(t, snapshot, restored.Snapshot())
+	assert.True(t, restored.CheckHostToken(hostToken))
+	assert.True(t, restored.CheckPassphrase("sprint"))
+	assert.Equal(t, "M", restored.GetPlayer("p1").Vote)
+	assert.Len(t, restored.GetHistory(), 1)
+
+	// Snapshots are copies
+	snapshot.Scale.Values[0] = "XXS"
+	snapshot.CurrentIssue.Key = "PAY-2"
+	assert.Equal(t, "XS", room.Scale.Values[0])
+	assert.Equal(t, "PAY-1", room.CurrentIssue.Key)
+}
+
+func TestRoomFromSnapshot_ResolvesScale(t *testing.T) {
+	room := RoomFromSnapshot(&RoomSnapshot{Code: "TEST", Scale: models.VotingScale{Type: models.ScalePowers2}})
+	assert.Equal(t, models.PresetScales[models.ScalePowers2].Values, room.Scale.Values)
+
+	room = RoomFromSnapshot(&RoomSnapshot{Code: "TEST", Scale: models.VotingScale{Type: "unknown"}})
+	assert.Equal(t, models.ScaleFibonacci, room.Scale.Type)
+}
+
+func TestRoomSnapshot_LegacyHistory(t *testing.T) {
+	// Older versions kept each round's votes by player name
+	var snapshot RoomSnapshot
+	data := `{"code":"TEST","history":[{"votes":{"Ada":"3"},"revealedAt":1}]}`
+	assert.Nil(t, json.Unmarshal([]byte(data), &snapshot))
+	assert.Equal(t, map[string]models.RoundVote{"Ada": {Name: "Ada", Vote: "3"}}, snapshot.History[0].Votes)
+	assert.Equal(t, int64(1), snapshot.History[0].RevealedAt)
+
+	// Current rounds read back as written
+	round := models.RoundResult{Votes: map[string]models.RoundVote{"p1": {Name: "Ada", Vote: "3"}}, RevealedAt: 2}
+	encoded, _ := json.Marshal(round)
+	var decoded models.RoundResult
+	assert.Nil(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, round, decoded)
+}
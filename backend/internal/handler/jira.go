@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/poker/backend/internal/game"
 	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/models"
 )
 
 const (
@@ -13,14 +20,17 @@ const (
 	errInvalidPayload    = "invalid payload"
 	errJiraSearchFailed  = "failed to search jira"
 	errJiraUpdateFailed  = "failed to update jira"
+	errJiraRequestFailed = "jira request failed"
 )
 
 type JiraHandler struct {
 	client *jira.Client
+	hub    *game.Hub    // Resolves votes through the scale of the room they were cast in
+	outbox *jira.Outbox // Writes estimates back, retrying while Jira is unavailable
 }
 
-func NewJiraHandler(client *jira.Client) *JiraHandler {
-	return &JiraHandler{client: client}
+func NewJiraHandler(client *jira.Client, hub *game.Hub, outbox *jira.Outbox) *JiraHandler {
+	return &JiraHandler{client: client, hub: hub, outbox: outbox}
 }
 
 func (h *JiraHandler) Search(c *gin.Context) {
@@ -29,22 +39,139 @@ func (h *JiraHandler) Search(c *gin.Context) {
 		return
 	}
 
-	query := c.Query("q")
-	if query == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "query required"})
+	opts := jira.SearchOptions{
+		Query:      strings.TrimSpace(c.Query("q")),
+		Project:    c.Query("project"),
+		Filter:     c.Query("filter"),
+		IssueTypes: listQuery(c, "type"),
+		Statuses:   listQuery(c, "status"),
+		Sprint:     c.Query("sprint"),
+		Assignee:   c.Query("assignee"),
+		PageToken:  c.Query("pageToken"),
+	}
+	if v := c.Query("unestimated"); v != "" {
+		unestimated, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unestimated must be true or false"})
+			return
+		}
+		opts.Unestimated = unestimated
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		opts.MaxResults = limit
+	}
+
+	if opts.Query == "" && !opts.HasFilters() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query or filter required"})
 		return
 	}
 
-	issues, err := h.client.SearchIssues(query)
+	result, err := h.client.Search(c.Request.Context(), opts)
 	if err != nil {
 		log.Printf("Jira search error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errJiraSearchFailed, "details": err.Error()})
+		c.JSON(jiraErrorStatus(err), gin.H{"error": errJiraSearchFailed, "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListBoards lists the Jira boards, optionally of one project (?project=KEY)
+func (h *JiraHandler) ListBoards(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errJiraNotConfigured})
+		return
+	}
+
+	boards, err := h.client.ListBoards(c.Request.Context(), c.Query("project"))
+	if err != nil {
+		log.Printf("Jira list boards error: %v", err)
+		c.JSON(jiraErrorStatus(err), gin.H{"error": errJiraRequestFailed, "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"boards": boards})
+}
+
+// ListSprints lists the sprints of a board, by default the active and future
+// ones (?state=active,future,closed)
+func (h *JiraHandler) ListSprints(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errJiraNotConfigured})
+		return
+	}
+
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || boardID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid board id"})
+		return
+	}
+	states := listQuery(c, "state")
+	if len(states) == 0 {
+		states = []string{"active", "future"}
+	}
+
+	sprints, err := h.client.ListSprints(c.Request.Context(), boardID, states...)
+	if err != nil {
+		log.Printf("Jira list sprints error for board %d: %v", boardID, err)
+		c.JSON(jiraErrorStatus(err), gin.H{"error": errJiraRequestFailed, "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"issues": issues})
+	c.JSON(http.StatusOK, gin.H{"sprints": sprints})
+}
+
+// jiraErrorStatus returns the status for a failed Jira request: 503 while
+// the client holds requests back after repeated failures, so the caller
+// can tell Jira is down from a bad request
+func jiraErrorStatus(err error) int {
+	if errors.Is(err, jira.ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// modelIssue converts a Jira issue to the form shown in rooms
+func modelIssue(issue *jira.Issue) models.JiraIssue {
+	return models.JiraIssue{
+		Key:                issue.Key,
+		Summary:            issue.Summary,
+		Type:               issue.Type,
+		Priority:           issue.Priority,
+		Status:             issue.Status,
+		Labels:             issue.Labels,
+		Points:             issue.Points,
+		Description:        issue.Description,
+		AcceptanceCriteria: issue.AcceptanceCriteria,
+		URL:                issue.URL,
+	}
+}
+
+// listQuery returns a query parameter given repeatedly or comma-separated
+func listQuery(c *gin.Context, key string) []string {
+	var values []string
+	for _, param := range c.QueryArray(key) {
+		for _, v := range strings.Split(param, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
 }
 
+// UpdateEstimation writes an estimate to the issue's estimate fields. The
+// estimate is a number of points, or a vote resolved to points through the
+// scale of the room it was cast in, so t-shirt sizes and custom cards can be
+// written back. The room's Jira actions follow, for the host only and only on
+// the issue the room is estimating or just estimated. The write goes through the
+// outbox: if Jira is rate limiting or unavailable it is queued for retries
+// (202), and the write in the response tells the host what failed.
 func (h *JiraHandler) UpdateEstimation(c *gin.Context) {
 	if h.client == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errJiraNotConfigured})
@@ -58,7 +185,9 @@ func (h *JiraHandler) UpdateEstimation(c *gin.Context) {
 	}
 
 	var body struct {
-		Points float64 `json:"points" binding:"required"`
+		Points *float64 `json:"points"`
+		Value  string   `json:"value"` // A vote, resolved through the room's scale
+		Room   string   `json:"room"`
 	}
 
 	if err := c.BindJSON(&body); err != nil {
@@ -66,18 +195,199 @@ func (h *JiraHandler) UpdateEstimation(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPayload})
 		return
 	}
+	if body.Points == nil && body.Value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "points or value required"})
+		return
+	}
 
 	// Validate story points
-	if body.Points < 0 {
+	if body.Points != nil && *body.Points < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "story points must be non-negative"})
 		return
 	}
 
-	if err := h.client.UpdateStoryPoints(key, body.Points); err != nil {
-		log.Printf("Jira update error for key %s: %v", key, err)
+	var room *game.Room
+	if body.Room != "" {
+		if h.hub != nil {
+			var err error
+			if room, err = h.hub.LookupRoom(body.Room); err != nil {
+				rejectAtCapacity(c)
+				return
+			}
+		}
+		if room == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+			return
+		}
+	}
+
+	var estimate jira.Estimate
+	switch {
+	case body.Points != nil:
+		estimate = jira.PointsEstimate(*body.Points)
+		if body.Value != "" {
+			estimate.Value = body.Value
+		}
+	case room != nil:
+		scale := room.GetScale()
+		if !slices.Contains(scale.Values, body.Value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "value is not on the room's scale"})
+			return
+		}
+		estimate.Value = body.Value
+		if points, ok := scale.PointsFor(body.Value); ok {
+			estimate.Points = &points
+		}
+	default:
+		var numeric models.VotingScale
+		estimate.Value = body.Value
+		if points, ok := numeric.PointsFor(body.Value); ok {
+			estimate.Points = &points
+		}
+	}
+	if estimate.Points == nil && h.client.NeedsPoints() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no story points for " + estimate.Value})
+		return
+	}
+
+	write := &jira.Write{IssueKey: key, Estimate: estimate}
+	if room != nil {
+		write.Room = room.Code
+		// Estimates of other issues are written without the actions
+		if actions := room.GetJiraActions(); actions.Any() && room.IsRecentIssue(key) {
+			if !room.CheckHostToken(bearerToken(c)) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "the room's host token is required for its jira actions"})
+				return
+			}
+			write.Actions = jiraActions(actions)
+		}
+		if round, ok := room.LastRound(key); ok {
+			write.Votes = roundVotes(round)
+		}
+	}
+	write, err := h.outbox.Submit(write)
+	if err != nil {
+		log.Printf("Jira write error for key %s: %v", key, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": errJiraUpdateFailed, "details": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "updated", "key": key, "points": body.Points})
+	// Once the estimate is in Jira the request succeeded, even if an action failed
+	resp := gin.H{"key": key, "value": estimate.Value, "points": estimate.Points, "write": write}
+	switch {
+	case slices.Contains(write.Done, jira.StepEstimate):
+		resp["status"] = "updated"
+		c.JSON(http.StatusOK, resp)
+	case write.Status == jira.WritePending:
+		resp["status"] = "queued"
+		c.JSON(http.StatusAccepted, resp)
+	default:
+		resp["error"] = errJiraUpdateFailed
+		resp["details"] = write.LastError
+		c.JSON(http.StatusBadGateway, resp)
+	}
+}
+
+// ListWrites lists the pending and failed Jira write-backs of a room
+// (?room=CODE), for its host
+func (h *JiraHandler) ListWrites(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errJiraNotConfigured})
+		return
+	}
+
+	room, ok := h.hostRoom(c)
+	if !ok {
+		return
+	}
+	code := room.Code
+
+	writes, err := h.outbox.List(code)
+	if err != nil {
+		log.Printf("Jira outbox list error for room %s: %v", code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errJiraRequestFailed})
+		return
+	}
+	if writes == nil {
+		writes = []*jira.Write{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"writes": writes})
+}
+
+// RetryWrite makes an immediate attempt at a pending or failed write-back of
+// a room (?room=CODE), for its host
+func (h *JiraHandler) RetryWrite(c *gin.Context) {
+	if h.client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errJiraNotConfigured})
+		return
+	}
+
+	room, ok := h.hostRoom(c)
+	if !ok {
+		return
+	}
+
+	write, err := h.outbox.Retry(c.Param("id"), room.Code)
+	if errors.Is(err, jira.ErrWriteNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, jira.ErrWriteBusy) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Jira outbox retry error for %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errJiraRequestFailed})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"write": write})
+}
+
+// hostRoom returns the room of the request (?room=CODE) if the request
+// carries its host token, responding with the error otherwise
+func (h *JiraHandler) hostRoom(c *gin.Context) (*game.Room, bool) {
+	code := c.Query("room")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "room required"})
+		return nil, false
+	}
+	var room *game.Room
+	if h.hub != nil {
+		var err error
+		if room, err = h.hub.LookupRoom(code); err != nil {
+			rejectAtCapacity(c)
+			return nil, false
+		}
+	}
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return nil, false
+	}
+	if !room.CheckHostToken(bearerToken(c)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid host token"})
+		return nil, false
+	}
+	return room, true
+}
+
+// jiraActions converts a room's Jira actions for the client
+func jiraActions(actions models.JiraActions) jira.Actions {
+	return jira.Actions{Comment: actions.Comment, Label: actions.Label, Transition: actions.Transition}
+}
+
+// roundVotes returns the votes of a round for the Jira comment, in player order
+func roundVotes(round models.RoundResult) jira.Votes {
+	ids := make([]string, 0, len(round.Votes))
+	for id := range round.Votes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	votes := make(jira.Votes, len(ids))
+	for i, id := range ids {
+		votes[i] = jira.Vote{Name: round.Votes[id].Name, Vote: round.Votes[id].Vote}
+	}
+	return votes
 }
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poker/backend/internal/game"
+	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupJiraRouter serves the Jira routes against a fake Jira that answers
+// searches with no issues and records the request bodies it was sent
+func setupJiraRouter(t *testing.T, config jira.Config) (*gin.Engine, *game.Hub, *[]map[string]interface{}) {
+	var bodies []map[string]interface{}
+	fake := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issues":[],"nextPageToken":"next"}`))
+	}))
+	t.Cleanup(fake.Close)
+
+	config.BaseURL = fake.URL
+	config.Flavor = jira.FlavorCloud
+	config.Email = "bot@example.com"
+	config.APIToken = "token"
+	client, err := jira.NewClient(config)
+	assert.Nil(t, err)
+
+	gin.SetMode(gin.TestMode)
+	hub := game.NewHub(24, nil)
+	t.Cleanup(hub.Stop)
+	r := gin.New()
+	h := NewJiraHandler(client, hub, jira.NewOutbox(client, jira.NewMemoryOutboxStore()))
+	r.GET("/jira/search", h.Search)
+	r.POST("/jira/issue/:key/estimate", h.UpdateEstimation)
+	r.GET("/jira/outbox", h.ListWrites)
+	r.POST("/jira/outbox/:id/retry", h.RetryWrite)
+	NewWebSocketHandler(hub) // Applies issue changes to rooms
+	r.POST("/jira/webhook", NewJiraWebhookHandler(client, hub, testWebhookSecret).Receive)
+	return r, hub, &bodies
+}
+
+func TestJiraHandler_Search(t *testing.T) {
+	router, _, bodies := setupJiraRouter(t, jira.Config{})
+
+	search := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/jira/search"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Filters alone are enough
+	w := search("?project=WEB&type=Story,Bug&status=To%20Do&sprint=open&unestimated=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"issues":[],"nextPageToken":"next"}`, w.Body.String())
+	assert.Equal(t, `project = "WEB" AND issuetype in ("Story", "Bug") AND status in ("To Do") AND `+
+		`sprint in openSprints() AND cf[10016] is EMPTY ORDER BY updated DESC`, (*bodies)[0]["jql"])
+
+	// Saved filters are referred to by ID or quoted name
+	assert.Equal(t, http.StatusOK, search("?filter=10042").Code)
+	assert.Equal(t, "filter = 10042 ORDER BY updated DESC", (*bodies)[1]["jql"])
+	assert.Equal(t, http.StatusOK, search("?filter=Team%20backlog").Code)
+	assert.Equal(t, `filter = "Team backlog" ORDER BY updated DESC`, (*bodies)[2]["jql"])
+
+	assert.Equal(t, http.StatusBadRequest, search("").Code)
+	assert.Equal(t, http.StatusBadRequest, search("?q=login&limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, search("?q=login&unestimated=maybe").Code)
+	assert.Len(t, *bodies, 3)
+}
+
+func TestJiraHandler_UpdateEstimation(t *testing.T) {
+	router, hub, bodies := setupJiraRouter(t, jira.Config{
+		EstimateFields: []jira.EstimateField{
+			{ID: "customfield_10016", Type: jira.FieldNumber},
+			{ID: "timetracking", Type: jira.FieldTimeTracking},
+			{ID: "customfield_10200", Type: jira.FieldSelect},
+		},
+		HoursPerPoint: 2,
+	})
+	room := hub.CreateRoomWithScale(24, models.ScaleTShirt)
+
+	estimate := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jira/issue/WEB-1/estimate", strings.NewReader(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// T-shirt sizes are written through the scale's points mapping
+	w := estimate(`{"value":"L","room":"` + room.Code + `"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]interface{}{
+		"customfield_10016": 5.0,
+		"timetracking":      map[string]interface{}{"originalEstimate": "10h"},
+		"customfield_10200": map[string]interface{}{"value": "L"},
+	}, (*bodies)[0]["fields"])
+
+	// Plain points still work
+	w = estimate(`{"points":0.25}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string]interface{}{
+		"customfield_10016": 0.25,
+		"timetracking":      map[string]interface{}{"originalEstimate": "30m"},
+		"customfield_10200": map[string]interface{}{"value": "0.25"},
+	}, (*bodies)[1]["fields"])
+
+	assert.Equal(t, http.StatusBadRequest, estimate(`{"value":"?","room":"`+room.Code+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, estimate(`{"value":"XXXL","room":"`+room.Code+`"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, estimate(`{"value":"M"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, estimate(`{"points":-1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, estimate(`{}`).Code)
+	assert.Equal(t, http.StatusNotFound, estimate(`{"value":"M","room":"NOPE1234"}`).Code)
+	assert.Len(t, *bodies, 2)
+}
+
+func TestJiraHandler_UpdateEstimationActions(t *testing.T) {
+	router, hub, bodies := setupJiraRouter(t, jira.Config{})
+	room := hub.CreateRoomWithScale(24, models.ScaleTShirt)
+	room.SetJiraActions(models.JiraActions{Comment: true, Label: "estimated", Transition: "Ready"})
+	host := game.NewPlayer("p1", "Ada", "", nil, true)
+	room.AddPlayer(host)
+	room.SetIssue(host.ID, &models.JiraIssue{Key: "WEB-1", Summary: "Checkout"})
+	room.Vote(host.ID, "M")
+	room.Reveal(host.ID)
+
+	hostToken, _ := hub.IssueHostToken(room)
+	estimate := func(key, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jira/issue/"+key+"/estimate", strings.NewReader(`{"value":"M","room":"`+room.Code+`"}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Only the host runs the actions
+	assert.Equal(t, http.StatusForbidden, estimate("WEB-1", "").Code)
+	assert.Equal(t, http.StatusForbidden, estimate("WEB-1", "guess").Code)
+	assert.Empty(t, *bodies)
+
+	w := estimate("WEB-1", hostToken)
+
+	// The estimate is saved even though the fake has no workflow to transition through
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Status string     `json:"status"`
+		Write  jira.Write `json:"write"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "updated", resp.Status)
+	assert.Equal(t, []string{jira.StepEstimate, jira.StepComment, jira.StepLabel}, resp.Write.Done)
+	assert.Equal(t, jira.WriteFailed, resp.Write.Status)
+	assert.Contains(t, resp.Write.LastError, "transition")
+
+	comment, _ := json.Marshal((*bodies)[1]["body"])
+	assert.Contains(t, string(comment), "Participants: Ada (M)")
+	assert.Equal(t, map[string]interface{}{"labels": []interface{}{map[string]interface{}{"add": "estimated"}}}, (*bodies)[2]["update"])
+
+	// The failed write is listed for the room's host and can be retried
+	outbox := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w = outbox("GET", "/jira/outbox?room="+strings.ToLower(room.Code), hostToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Writes []jira.Write `json:"writes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if assert.Len(t, list.Writes, 1) {
+		assert.Equal(t, resp.Write.ID, list.Writes[0].ID)
+	}
+
+	w = outbox("POST", "/jira/outbox/"+resp.Write.ID+"/retry?room="+room.Code, hostToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, jira.WriteFailed, resp.Write.Status)
+	assert.Equal(t, 1, resp.Write.Attempts) // Retrying by hand starts afresh
+	for _, body := range (*bodies)[3:] {
+		assert.Empty(t, body) // Only the transition is tried again
+	}
+
+	// Only the host sees and retries the room's writes, and only the room's
+	other := hub.CreateRoom(24)
+	otherToken, _ := hub.IssueHostToken(other)
+	assert.Equal(t, http.StatusBadRequest, outbox("GET", "/jira/outbox", hostToken).Code)
+	assert.Equal(t, http.StatusForbidden, outbox("GET", "/jira/outbox?room="+room.Code, "").Code)
+	assert.Equal(t, http.StatusForbidden, outbox("GET", "/jira/outbox?room="+room.Code, otherToken).Code)
+	assert.Equal(t, http.StatusForbidden, outbox("POST", "/jira/outbox/"+resp.Write.ID+"/retry?room="+room.Code, "").Code)
+	assert.Equal(t, http.StatusNotFound, outbox("POST", "/jira/outbox/"+resp.Write.ID+"/retry?room="+other.Code, otherToken).Code)
+	assert.Equal(t, http.StatusNotFound, outbox("POST", "/jira/outbox/nope/retry?room="+room.Code, hostToken).Code)
+
+	// Issues the room is not estimating get the estimate alone
+	sent := len(*bodies)
+	assert.Equal(t, http.StatusOK, estimate("WEB-9", "").Code)
+	assert.Len(t, *bodies, sent+1)
+}
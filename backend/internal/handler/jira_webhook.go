@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poker/backend/internal/game"
+	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/models"
+)
+
+// EventIssueChanged updates the rooms holding an issue that changed in Jira;
+// its data is an issueChange
+const EventIssueChanged = "jira_issue_changed"
+
+// maxWebhookSize caps the body of Jira webhook requests
+const maxWebhookSize = 1 << 20
+
+// issueChange is an issue updated or deleted in Jira
+type issueChange struct {
+	Key   string            `json:"key"`
+	Issue *models.JiraIssue `json:"issue,omitempty"` // Nil when deleted
+}
+
+// JiraWebhookHandler receives issue events from Jira webhooks
+type JiraWebhookHandler struct {
+	client *jira.Client
+	hub    *game.Hub
+	secret string // Shared with the webhook; requests are refused without it
+}
+
+func NewJiraWebhookHandler(client *jira.Client, hub *game.Hub, secret string) *JiraWebhookHandler {
+	return &JiraWebhookHandler{client: client, hub: hub, secret: secret}
+}
+
+// Receive handles a webhook request (POST /jira/webhook). Updated issues
+// replace the current and queued issues of the rooms holding them, deleted
+// ones are taken off; the rooms' players get the new state.
+func (h *JiraWebhookHandler) Receive(c *gin.Context) {
+	if h.client == nil || h.secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Jira webhook not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "webhook payload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPayload})
+		return
+	}
+	if !jira.VerifyWebhook(h.secret, body, c.GetHeader("X-Hub-Signature"), c.Query("secret")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook signature"})
+		return
+	}
+
+	event, err := h.client.ParseWebhook(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPayload})
+		return
+	}
+	if (event.Type != jira.WebhookIssueUpdated && event.Type != jira.WebhookIssueDeleted) || event.IssueKey == "" {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	change := issueChange{Key: event.IssueKey}
+	if event.Issue != nil {
+		issue := modelIssue(event.Issue)
+		change.Issue = &issue
+	}
+	data, err := json.Marshal(change)
+	if err == nil {
+		err = h.hub.DispatchAll(EventIssueChanged, data)
+	}
+	if err != nil {
+		log.Printf("Jira webhook dispatch error for %s: %v", event.IssueKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update rooms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "accepted"})
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poker/backend/internal/game"
+	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+// postWebhook sends a webhook body signed with the secret
+func postWebhook(router *gin.Engine, body, secret string) *httptest.ResponseRecorder {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jira/webhook", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestJiraWebhookHandler_Receive(t *testing.T) {
+	router, hub, _ := setupJiraRouter(t, jira.Config{})
+	room := hub.CreateRoom(24)
+	host := game.NewPlayer("p1", "Ada", "", nil, true)
+	room.AddPlayer(host)
+	room.SetQueue(host.ID, []models.JiraIssue{{Key: "WEB-1", Summary: "Checkout"}, {Key: "WEB-2", Summary: "Refunds"}})
+	other := hub.CreateRoom(24)
+
+	updated := `{"webhookEvent":"jira:issue_updated","issue":{"key":"WEB-1","fields":{"summary":"Checkout v2","customfield_10016":8,"description":"Pay now"}}}`
+	w := postWebhook(router, updated, testWebhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	issue := room.GetIssue()
+	assert.Equal(t, "Checkout v2", issue.Summary)
+	assert.Equal(t, 8.0, *issue.Points)
+	assert.Equal(t, "<p>Pay now</p>", issue.Description)
+	assert.Nil(t, other.GetIssue())
+
+	w = postWebhook(router, `{"webhookEvent":"jira:issue_deleted","issue":{"key":"WEB-2","fields":{}}}`, testWebhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, room.GetState(host.ID).Queue)
+
+	// Other events are acknowledged and ignored
+	w = postWebhook(router, `{"webhookEvent":"sprint_started"}`, testWebhookSecret)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "ignored")
+
+	// Requests must be signed with the secret, or carry it in the URL
+	assert.Equal(t, http.StatusUnauthorized, postWebhook(router, updated, "guess").Code)
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jira/webhook", strings.NewReader(updated))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/jira/webhook?secret="+testWebhookSecret, strings.NewReader(updated))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, http.StatusBadRequest, postWebhook(router, `not json`, testWebhookSecret).Code)
+}
+
+func TestJiraWebhookHandler_NoSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client, _ := jira.NewClient(jira.Config{BaseURL: "http://jira.example.com", Flavor: jira.FlavorCloud, Email: "bot@example.com", APIToken: "token"})
+	r := gin.New()
+	r.POST("/jira/webhook", NewJiraWebhookHandler(client, nil, "").Receive)
+
+	w := postWebhook(r, `{}`, "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -1,15 +1,23 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/poker/backend/internal/game"
 	"github.com/poker/backend/internal/models"
 )
 
+// capacityRetryAfter is the Retry-After (in seconds) sent when the server has no room capacity left
+const capacityRetryAfter = 60
+
+// maxImportSize caps the body of room imports
+const maxImportSize = 1 << 20
+
 // RoomHandler handles room-related HTTP requests
 type RoomHandler struct {
 	hub *game.Hub
@@ -22,7 +30,48 @@ func NewRoomHandler(hub *game.Hub) *RoomHandler {
 
 // CreateRoomRequest represents the request body for room creation
 type CreateRoomRequest struct {
-	Scale string `json:"scale"`
+	Scale       string              `json:"scale"`
+	Values      []string            `json:"values"`      // Card values of a custom scale
+	Points      map[string]float64  `json:"points"`      // Story points of scale values, for Jira
+	Passphrase  string              `json:"passphrase"`  // Optional, required in the join handshake
+	JiraActions *models.JiraActions `json:"jiraActions"` // Refused: only reserved rooms have Jira actions
+}
+
+// customScale returns the scale described by a request's custom values or
+// points mapping, or nil if the request names a plain preset. Points given
+// for a preset override its own mapping.
+func customScale(scaleType string, values []string, points map[string]float64) (*models.VotingScale, error) {
+	var scale models.VotingScale
+	switch {
+	case models.VotingScaleType(scaleType) == models.ScaleCustom || (scaleType == "" && len(values) > 0):
+		scale = models.VotingScale{Type: models.ScaleCustom, Name: "Custom", Values: values}
+	case len(values) > 0:
+		return nil, errors.New("values can only be given for a custom scale")
+	case len(points) == 0:
+		return nil, nil
+	default:
+		if scaleType == "" {
+			scaleType = string(models.ScaleFibonacci)
+		}
+		preset, ok := models.PresetScales[models.VotingScaleType(scaleType)]
+		if !ok {
+			return nil, errors.New("unknown scale: " + scaleType)
+		}
+		scale = preset.Clone()
+	}
+
+	if len(points) > 0 {
+		if scale.Points == nil {
+			scale.Points = make(map[string]float64, len(points))
+		}
+		for v, p := range points {
+			scale.Points[v] = p
+		}
+	}
+	if err := scale.Validate(); err != nil {
+		return nil, err
+	}
+	return &scale, nil
 }
 
 // CreateRoom creates a new room
@@ -41,6 +90,18 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 	}
 
 	scaleType := c.DefaultQuery("scale", req.Scale)
+	custom, err := customScale(scaleType, req.Values, req.Points)
+	if err == nil && req.JiraActions != nil {
+		err = req.JiraActions.Validate()
+	}
+	if err == nil && req.JiraActions != nil && req.JiraActions.Any() {
+		// Anyone can create a room, so only reserved rooms change issues in Jira
+		err = errors.New("jira actions can only be set on reserved rooms")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if scaleType == "" {
 		scaleType = string(models.ScaleFibonacci)
 	}
@@ -48,17 +109,248 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 	log.Printf("Creating room with scale: '%s' (from body: '%s')", scaleType, req.Scale)
 
 	room := h.hub.CreateRoomWithScale(expiryHours, models.VotingScaleType(scaleType))
+	if room == nil {
+		rejectAtCapacity(c)
+		return
+	}
+	if custom != nil {
+		room.SetScale(custom)
+	}
+	if req.Passphrase != "" {
+		room.SetPassphrase(req.Passphrase)
+	}
+	if custom != nil || req.Passphrase != "" {
+		h.hub.SaveRoom(room)
+	}
+
+	// Tokens are only ever returned here; the room keeps their hashes
+	hostToken, hostTokenExpiry := h.hub.IssueHostToken(room)
+	facilitatorToken := h.hub.IssueFacilitatorToken(room)
 
 	log.Printf("Room created: %s with scale: %v", room.Code, room.Scale)
 
-	c.JSON(http.StatusCreated, gin.H{
-		"code":        room.Code,
-		"hostToken":   room.HostToken,
-		"expiryHours": room.ExpiryHours,
-		"scale":       room.Scale,
+	resp := gin.H{
+		"code":             room.Code,
+		"hostToken":        hostToken,
+		"facilitatorToken": facilitatorToken,
+		"expiryHours":      room.ExpiryHours,
+		"scale":            room.Scale,
+	}
+	if hostTokenExpiry != nil {
+		resp["hostTokenExpiresAt"] = hostTokenExpiry.UnixMilli()
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ReserveRoomRequest represents the request body for reserving a named room
+type ReserveRoomRequest struct {
+	Scale       string              `json:"scale"`
+	Values      []string            `json:"values"`      // Card values of a custom scale
+	Points      map[string]float64  `json:"points"`      // Story points of scale values, for Jira
+	Passphrase  *string             `json:"passphrase"`  // Empty string removes the passphrase
+	JiraActions *models.JiraActions `json:"jiraActions"` // Replaces the room's Jira actions
+}
+
+// ReserveRoom creates a persistent team room under a vanity name (PUT /rooms/:code).
+// Once reserved, the room can only be reconfigured by presenting its host
+// token as a bearer token; the tokens are returned only on creation.
+func (h *RoomHandler) ReserveRoom(c *gin.Context) {
+	var req ReserveRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("JSON parse (may be empty): %v", err)
+	}
+
+	code, err := game.NormalizeRoomName(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	custom, err := customScale(req.Scale, req.Values, req.Points)
+	if err == nil && req.JiraActions != nil {
+		err = req.JiraActions.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := h.hub.LookupRoom(code)
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
+	if existing != nil {
+		h.updateReservedRoom(c, existing, req, custom)
+		return
+	}
+
+	scaleType := models.VotingScaleType(req.Scale)
+	if scaleType == "" {
+		scaleType = models.ScaleFibonacci
+	}
+
+	room, err := h.hub.CreatePersistentRoom(code, scaleType)
+	if errors.Is(err, game.ErrRoomNameTaken) {
+		// Reserved concurrently
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
+	if custom != nil {
+		room.SetScale(custom)
+	}
+	if req.Passphrase != nil && *req.Passphrase != "" {
+		room.SetPassphrase(*req.Passphrase)
+	}
+	if req.JiraActions != nil {
+		room.SetJiraActions(*req.JiraActions)
+	}
+
+	hostToken, hostTokenExpiry := h.hub.IssueHostToken(room)
+	facilitatorToken := h.hub.IssueFacilitatorToken(room)
+
+	resp := gin.H{
+		"code":             room.Code,
+		"hostToken":        hostToken,
+		"facilitatorToken": facilitatorToken,
+		"persistent":       true,
+		"scale":            room.GetScale(),
+	}
+	if hostTokenExpiry != nil {
+		resp["hostTokenExpiresAt"] = hostTokenExpiry.UnixMilli()
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// updateReservedRoom applies new settings to an existing room for the holder of its host token
+func (h *RoomHandler) updateReservedRoom(c *gin.Context, room *game.Room, req ReserveRoomRequest, custom *models.VotingScale) {
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": game.ErrRoomNameTaken.Error()})
+		return
+	}
+	if !room.CheckHostToken(token) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid host token"})
+		return
+	}
+
+	if custom != nil {
+		room.SetScale(custom)
+	} else if req.Scale != "" {
+		scale, ok := models.PresetScales[models.VotingScaleType(req.Scale)]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scale: " + req.Scale})
+			return
+		}
+		room.SetScale(&scale)
+	}
+	if req.Passphrase != nil {
+		room.SetPassphrase(*req.Passphrase)
+	}
+	if req.JiraActions != nil {
+		room.SetJiraActions(*req.JiraActions)
+	}
+	h.hub.SaveRoom(room)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":               room.Code,
+		"persistent":         room.Persistent,
+		"scale":              room.GetScale(),
+		"passphraseRequired": room.HasPassphrase(),
 	})
 }
 
+// ExportRoom returns the room as a versioned JSON snapshot for the holder of
+// its host token (GET /rooms/:code/export)
+func (h *RoomHandler) ExportRoom(c *gin.Context) {
+	room, err := h.hub.LookupRoom(c.Param("code"))
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
+	if room == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+		return
+	}
+
+	token := bearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "host token required"})
+		return
+	}
+	if !room.CheckHostToken(token) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid host token"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="room-`+room.Code+`.json"`)
+	c.JSON(http.StatusOK, room.Export())
+}
+
+// ImportRoom recreates a room from an export (POST /rooms/import). The body is
+// the export itself; the optional code query parameter requests a room code.
+// New host and facilitator tokens are returned as on creation.
+func (h *RoomHandler) ImportRoom(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var export game.RoomExport
+	if err := c.ShouldBindJSON(&export); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export: " + err.Error()})
+		return
+	}
+
+	room, err := h.hub.ImportRoom(&export, c.Query("code"))
+	switch {
+	case errors.Is(err, game.ErrRoomNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, game.ErrTooManyRooms):
+		rejectAtCapacity(c)
+		return
+	case errors.Is(err, game.ErrSaveFailed):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hostToken, hostTokenExpiry := h.hub.IssueHostToken(room)
+	facilitatorToken := h.hub.IssueFacilitatorToken(room)
+
+	resp := gin.H{
+		"code":             room.Code,
+		"hostToken":        hostToken,
+		"facilitatorToken": facilitatorToken,
+		"expiryHours":      room.ExpiryHours,
+		"persistent":       room.Persistent,
+		"scale":            room.GetScale(),
+	}
+	if hostTokenExpiry != nil {
+		resp["hostTokenExpiresAt"] = hostTokenExpiry.UnixMilli()
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// rejectAtCapacity answers 503 when no room can be created or loaded right now
+func rejectAtCapacity(c *gin.Context) {
+	c.Header("Retry-After", strconv.Itoa(capacityRetryAfter))
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is at room capacity, try again later"})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
 // GetScales returns available voting scales
 func (h *RoomHandler) GetScales(c *gin.Context) {
 	scales := make([]models.VotingScale, 0, len(models.PresetScales))
@@ -74,17 +366,23 @@ func (h *RoomHandler) GetScales(c *gin.Context) {
 func (h *RoomHandler) GetRoom(c *gin.Context) {
 	code := c.Param("code")
 
-	room := h.hub.GetRoom(code)
+	room, err := h.hub.LookupRoom(code)
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
 	if room == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"code":        room.Code,
-		"playerCount": room.PlayerCount(),
-		"expiryHours": room.ExpiryHours,
-		"scale":       room.GetScale(),
+		"code":               room.Code,
+		"playerCount":        room.PlayerCount(),
+		"expiryHours":        room.ExpiryHours,
+		"scale":              room.GetScale(),
+		"passphraseRequired": room.HasPassphrase(),
+		"persistent":         room.Persistent,
 	})
 }
 
@@ -92,15 +390,20 @@ func (h *RoomHandler) GetRoom(c *gin.Context) {
 func (h *RoomHandler) CheckRoom(c *gin.Context) {
 	code := c.Param("code")
 
-	room := h.hub.GetRoom(code)
+	room, err := h.hub.LookupRoom(code)
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
 	if room == nil {
 		c.JSON(http.StatusNotFound, gin.H{"exists": false})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"exists":      true,
-		"playerCount": room.PlayerCount(),
+		"exists":             true,
+		"playerCount":        room.PlayerCount(),
+		"passphraseRequired": room.HasPassphrase(),
 	})
 }
 
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/poker/backend/internal/game"
@@ -20,8 +21,11 @@ func setupTestRouter() (*gin.Engine, *game.Hub) {
 	r := gin.New()
 
 	r.POST("/rooms", handler.CreateRoom)
+	r.PUT("/rooms/:code", handler.ReserveRoom)
 	r.GET("/rooms/:code", handler.GetRoom)
 	r.GET("/rooms/:code/check", handler.CheckRoom)
+	r.GET("/rooms/:code/export", handler.ExportRoom)
+	r.POST("/rooms/import", handler.ImportRoom)
 	r.GET("/scales", handler.GetScales)
 
 	return r, hub
@@ -44,6 +48,14 @@ func TestRoomHandler_CreateRoom(t *testing.T) {
 	assert.NotEmpty(t, resp["code"])
 	assert.Equal(t, float64(24), resp["expiryHours"]) // JSON numbers are float64
 
+	// Tokens are returned once and only their hashes are kept
+	hostToken, _ := resp["hostToken"].(string)
+	assert.NotEmpty(t, hostToken)
+	assert.NotEmpty(t, resp["facilitatorToken"])
+	assert.Nil(t, resp["hostTokenExpiresAt"])
+	created := hub.GetRoom(resp["code"].(string))
+	assert.NotEqual(t, hostToken, created.HostTokenHash)
+
 	// 2. Custom scale and expiry via query/body
 	w = httptest.NewRecorder()
 	body := `{"scale": "tshirt"}`
@@ -65,6 +77,268 @@ func TestRoomHandler_CreateRoom(t *testing.T) {
 	assert.Equal(t, models.ScaleTShirt, room.Scale.Type)
 }
 
+func TestRoomHandler_CreateRoom_CustomScale(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/rooms", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	scaleOf := func(w *httptest.ResponseRecorder) *models.VotingScale {
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return hub.GetRoom(resp["code"].(string)).GetScale()
+	}
+
+	// Custom cards with their points
+	w := create(`{"scale": "custom", "values": ["tiny", "big", "?"], "points": {"tiny": 1, "big": 8}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	scale := scaleOf(w)
+	assert.Equal(t, models.ScaleCustom, scale.Type)
+	assert.Equal(t, []string{"tiny", "big", "?"}, scale.Values)
+	points, ok := scale.PointsFor("big")
+	assert.True(t, ok)
+	assert.Equal(t, 8.0, points)
+	_, ok = scale.PointsFor("?")
+	assert.False(t, ok)
+
+	// Points override a preset's mapping without changing the preset
+	w = create(`{"scale": "tshirt", "points": {"XL": 10}}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	scale = scaleOf(w)
+	assert.Equal(t, 10.0, scale.Points["XL"])
+	assert.Equal(t, 5.0, scale.Points["L"])
+	assert.Equal(t, 8.0, models.PresetScales[models.ScaleTShirt].Points["XL"])
+
+	for _, body := range []string{
+		`{"scale": "custom"}`,
+		`{"scale": "custom", "values": ["1", "1"]}`,
+		`{"scale": "custom", "values": ["much too long"]}`,
+		`{"scale": "custom", "values": ["S"], "points": {"M": 3}}`,
+		`{"scale": "custom", "values": ["S"], "points": {"S": -3}}`,
+		`{"scale": "tshirt", "values": ["S"]}`,
+		`{"scale": "cards", "points": {"S": 1}}`,
+		`{"jiraActions": {"comment": true}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, create(body).Code, body)
+	}
+}
+
+func TestRoomHandler_CreateRoom_AtCapacity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := game.NewHubWithConfig(game.HubConfig{DefaultExpiry: 24, MaxRooms: 1}, nil)
+	defer hub.Stop()
+	handler := NewRoomHandler(hub)
+	router := gin.New()
+	router.POST("/rooms", handler.CreateRoom)
+	router.PUT("/rooms/:code", handler.ReserveRoom)
+
+	// Keep the only room busy so it cannot be evicted
+	room := hub.CreateRoom(24)
+	room.AddPlayer(game.NewPlayer("p1", "Player", "", nil, false))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/rooms", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/rooms/PAYMENTS", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+// storedRooms is a RoomRepository holding rooms that are only in storage
+type storedRooms map[string]*game.RoomSnapshot
+
+func (s storedRooms) SaveRoom(room *game.RoomSnapshot) error          { return nil }
+func (s storedRooms) GetRoom(code string) (*game.RoomSnapshot, error) { return s[code], nil }
+func (s storedRooms) RoomExists(code string) (bool, error)            { return s[code] != nil, nil }
+func (s storedRooms) DeleteRoom(code string) error                    { return nil }
+func (s storedRooms) DeleteExpiredRooms(now time.Time) (int, error)   { return 0, nil }
+
+func TestRoomHandler_LoadAtCapacity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored := game.NewRoom("STORED", 24)
+	hub := game.NewHubWithConfig(game.HubConfig{DefaultExpiry: 24, MaxRooms: 1}, storedRooms{"STORED": stored.Snapshot()})
+	defer hub.Stop()
+	handler := NewRoomHandler(hub)
+	router := gin.New()
+	router.GET("/rooms/:code", handler.GetRoom)
+	router.GET("/rooms/:code/check", handler.CheckRoom)
+
+	room := hub.CreateRoom(24)
+	room.AddPlayer(game.NewPlayer("p1", "Player", "", nil, false))
+
+	// A stored room that cannot be loaded is not reported as missing
+	for _, path := range []string{"/rooms/STORED", "/rooms/STORED/check"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"), path)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/rooms/MISSING", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRoomHandler_ReserveRoom(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+
+	reserve := func(code, body, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/rooms/"+code, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Invalid names are rejected
+	assert.Equal(t, http.StatusBadRequest, reserve("a", "", "").Code)
+
+	// First reservation creates the persistent room and returns its tokens
+	w := reserve("payments", `{"scale": "tshirt"}`, "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "PAYMENTS", resp["code"])
+	assert.Equal(t, true, resp["persistent"])
+	hostToken := resp["hostToken"].(string)
+	assert.NotEmpty(t, hostToken)
+
+	room := hub.GetRoom("PAYMENTS")
+	assert.NotNil(t, room)
+	assert.True(t, room.Persistent)
+	assert.Equal(t, models.ScaleTShirt, room.Scale.Type)
+
+	// Without the host token the name is taken
+	assert.Equal(t, http.StatusConflict, reserve("PAYMENTS", `{"scale": "fibonacci"}`, "").Code)
+	assert.Equal(t, http.StatusForbidden, reserve("PAYMENTS", `{"scale": "fibonacci"}`, "wrong").Code)
+	assert.Equal(t, models.ScaleTShirt, room.Scale.Type)
+
+	// The token holder can change the settings
+	w = reserve("PAYMENTS", `{"scale": "fibonacci", "passphrase": "sprint"}`, hostToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.ScaleFibonacci, room.Scale.Type)
+	assert.True(t, room.CheckPassphrase("sprint"))
+	assert.Equal(t, http.StatusBadRequest, reserve("PAYMENTS", `{"scale": "cards"}`, hostToken).Code)
+
+	// and switch to custom cards
+	w = reserve("PAYMENTS", `{"values": ["S", "L"], "points": {"S": 1, "L": 3}}`, hostToken)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.ScaleCustom, room.GetScale().Type)
+	assert.Equal(t, 3.0, room.GetScale().Points["L"])
+
+	// and the Jira actions
+	w = reserve("PAYMENTS", `{"jiraActions": {"comment": true, "label": "estimated", "transition": "Ready"}}`, hostToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.JiraActions{Comment: true, Label: "estimated", Transition: "Ready"}, room.GetJiraActions())
+	assert.Equal(t, http.StatusBadRequest, reserve("PAYMENTS", `{"jiraActions": {"label": "two words"}}`, hostToken).Code)
+	assert.Equal(t, "estimated", room.GetJiraActions().Label)
+}
+
+func TestRoomHandler_ExportImport(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+
+	room := hub.CreateRoomWithScale(24, models.ScaleTShirt)
+	hostToken, _ := hub.IssueHostToken(room)
+	room.SetPassphrase("sprint")
+	room.Queue = []models.JiraIssue{{Key: "PAY-2", Summary: "Refunds"}}
+	host := game.NewPlayer("p1", "Ada", "", nil, false)
+	room.AddPlayer(host)
+	room.ClaimHost(host.ID, hostToken)
+	room.SetIssue(host.ID, &models.JiraIssue{Key: "PAY-1", Summary: "Checkout"})
+	room.Vote(host.ID, "M")
+	room.Reveal(host.ID)
+
+	export := func(code, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/rooms/"+code+"/export", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	importRoom := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/rooms/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exports are for the host only
+	assert.Equal(t, http.StatusNotFound, export("NOPE", hostToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, export(room.Code, "").Code)
+	assert.Equal(t, http.StatusForbidden, export(room.Code, "wrong").Code)
+
+	w := export(room.Code, hostToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.NotContains(t, body, room.HostTokenHash)
+	assert.NotContains(t, body, room.PassphraseHash)
+	assert.NotContains(t, body, host.SessionToken)
+
+	var exported game.RoomExport
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	assert.Equal(t, game.ExportVersion, exported.Version)
+	assert.Equal(t, "PAY-1", exported.Room.CurrentIssue.Key)
+	assert.Len(t, exported.Room.Queue, 1)
+	assert.Len(t, exported.Room.History, 1)
+	assert.Equal(t, "M", exported.Room.Players[0].Vote)
+
+	// Import under a requested code, which must be free
+	assert.Equal(t, http.StatusConflict, importRoom("?code="+room.Code, body).Code)
+	w = importRoom("?code=staging-1", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, "STAGING-1", resp["code"])
+	newToken, _ := resp["hostToken"].(string)
+	assert.NotEmpty(t, newToken)
+
+	imported := hub.GetRoom("STAGING-1")
+	assert.NotNil(t, imported)
+	assert.Equal(t, models.ScaleTShirt, imported.GetScale().Type)
+	assert.Equal(t, "PAY-2", imported.Queue[0].Key)
+	assert.Len(t, imported.GetHistory(), 1)
+	assert.Nil(t, imported.GetPlayer("p1")) // Players get new IDs
+	if players := imported.PlayerList(); assert.Len(t, players, 1) {
+		assert.Equal(t, "Ada", players[0].Name)
+		assert.Equal(t, "M", players[0].Vote)
+		assert.Equal(t, players[0].ID, imported.HostID)
+		assert.Contains(t, imported.GetHistory()[0].Votes, players[0].ID)
+	}
+	assert.True(t, imported.CheckHostToken(newToken))
+	assert.False(t, imported.CheckHostToken(hostToken))
+	assert.False(t, imported.HasPassphrase())
+
+	// Or under a new code
+	w = importRoom("", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.NotEqual(t, room.Code, resp["code"])
+
+	// Unknown versions and malformed bodies are rejected
+	assert.Equal(t, http.StatusBadRequest, importRoom("", strings.Replace(body, `"version":1`, `"version":99`, 1)).Code)
+	assert.Equal(t, http.StatusBadRequest, importRoom("", "{").Code)
+	assert.Equal(t, http.StatusBadRequest, importRoom("?code=-x", body).Code)
+}
+
 func TestRoomHandler_GetRoom(t *testing.T) {
 	router, hub := setupTestRouter()
 	defer hub.Stop()
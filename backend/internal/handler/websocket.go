@@ -1,103 +1,409 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/poker/backend/internal/game"
+	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/middleware"
 	"github.com/poker/backend/internal/models"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins in development
-	},
+// protocolError is a request failure reported back to the client with a stable code
+type protocolError struct {
+	Code    models.ErrorCode
+	Message string
+}
+
+func (e *protocolError) Error() string {
+	return e.Message
+}
+
+func newProtocolError(code models.ErrorCode, message string) *protocolError {
+	return &protocolError{Code: code, Message: message}
+}
+
+const (
+	defaultJoinTimeout    = 10 * time.Second
+	defaultRateLimitWarns = 3
+	maxMessageSize        = 512 * 1024 // 512 KB
+	maxPendingMessages    = 16         // Messages a connection may have waiting behind a slow one
+)
+
+// RateLimitAction is what happens when a player exceeds its message rate limit
+type RateLimitAction string
+
+const (
+	RateLimitDrop       RateLimitAction = "drop"       // Silently drop the message
+	RateLimitWarn       RateLimitAction = "warn"       // Send an error, disconnect after MaxRateLimitWarnings
+	RateLimitDisconnect RateLimitAction = "disconnect" // Disconnect immediately
+)
+
+// ParseRateLimitAction parses a WS_RATE_LIMIT_ACTION value; empty means warn
+func ParseRateLimitAction(value string) (RateLimitAction, error) {
+	switch action := RateLimitAction(strings.ToLower(strings.TrimSpace(value))); action {
+	case "":
+		return RateLimitWarn, nil
+	case RateLimitDrop, RateLimitWarn, RateLimitDisconnect:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown rate limit action %q (expected warn, disconnect or drop)", value)
+}
+
+// WebSocketConfig holds the connection policy for the WebSocket endpoint
+type WebSocketConfig struct {
+	Origins              *middleware.OriginPolicy // Allowed browser origins; nil allows all
+	JoinTimeout          time.Duration            // Time allowed for the join handshake
+	MaxConnsPerIP        int                      // Concurrent connections per client IP (0 = unlimited)
+	JoinRate             float64                  // Connection attempts per second per IP (0 = unlimited)
+	JoinBurst            int                      // Burst of connection attempts per IP
+	Limiter              middleware.Limiter       // Limiter for join attempts, in process when nil
+	RateLimitAction      RateLimitAction          // Defaults to RateLimitWarn
+	MaxRateLimitWarnings int                      // Warnings before a warned player is disconnected
+	Issues               IssueLoader              // Loads queues from Jira; nil when Jira is not configured
+}
+
+// IssueLoader fetches issues from Jira: the details of one issue, or the
+// issues of a sprint, board backlog or filter
+type IssueLoader interface {
+	GetIssue(ctx context.Context, key string) (*jira.Issue, error)
+	LoadIssues(ctx context.Context, source jira.IssueSource) ([]jira.Issue, error)
 }
 
 // WebSocketHandler handles WebSocket connections
 type WebSocketHandler struct {
-	hub *game.Hub
+	hub             *game.Hub
+	upgrader        websocket.Upgrader
+	joinTimeout     time.Duration
+	conns           *middleware.ConnLimiter
+	joinLimiter     middleware.Limiter
+	joinPolicy      middleware.Policy
+	rateLimitAction RateLimitAction
+	maxWarnings     int
+	issues          IssueLoader
 }
 
-// NewWebSocketHandler creates a new WebSocket handler
+// NewWebSocketHandler creates a new WebSocket handler accepting all origins
 func NewWebSocketHandler(hub *game.Hub) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub}
+	return NewWebSocketHandlerWithConfig(hub, WebSocketConfig{})
 }
 
-// HandleConnection handles a new WebSocket connection
+// NewWebSocketHandlerWithConfig creates a new WebSocket handler with the given policy
+func NewWebSocketHandlerWithConfig(hub *game.Hub, config WebSocketConfig) *WebSocketHandler {
+	if config.Origins == nil {
+		config.Origins = middleware.NewOriginPolicy([]string{"*"})
+	}
+	if config.JoinTimeout <= 0 {
+		config.JoinTimeout = defaultJoinTimeout
+	}
+	if config.RateLimitAction == "" {
+		config.RateLimitAction = RateLimitWarn
+	}
+	if config.MaxRateLimitWarnings <= 0 {
+		config.MaxRateLimitWarnings = defaultRateLimitWarns
+	}
+
+	var joinLimiter middleware.Limiter
+	if config.JoinRate > 0 {
+		if config.JoinBurst <= 0 {
+			config.JoinBurst = 1
+		}
+		joinLimiter = config.Limiter
+		if joinLimiter == nil {
+			joinLimiter = middleware.NewMemoryLimiter(middleware.DefaultLimiterCapacity)
+		}
+	}
+
+	h := &WebSocketHandler{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Subprotocols:    []string{models.SubprotocolV1},
+			CheckOrigin:     config.Origins.CheckOrigin,
+		},
+		joinTimeout: config.JoinTimeout,
+		conns:       middleware.NewConnLimiter(config.MaxConnsPerIP),
+		joinLimiter: joinLimiter,
+		joinPolicy: middleware.Policy{
+			Name:  "ws-join",
+			Limit: middleware.Limit{Rate: config.JoinRate, Burst: config.JoinBurst},
+		},
+		rateLimitAction: config.RateLimitAction,
+		maxWarnings:     config.MaxRateLimitWarnings,
+		issues:          config.Issues,
+	}
+	hub.HandleEvent(EventIssueChanged, h.applyIssueChange)
+	return h
+}
+
+// HandleConnection handles a new WebSocket connection. The client joins by
+// sending a join message first; the name/hostToken query parameters are a
+// deprecated fallback for older clients.
 func (h *WebSocketHandler) HandleConnection(c *gin.Context) {
-	roomCode := c.Query("room")
-	playerName := c.Query("name")
+	ip := c.ClientIP()
+	if h.joinLimiter != nil && !middleware.AllowRequest(c, h.joinLimiter, h.joinPolicy) {
+		log.Printf("Join rate limit exceeded for %s", ip)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many connection attempts"})
+		return
+	}
 
-	if roomCode == "" || playerName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "room and name are required"})
+	roomCode := c.Query("room")
+	if roomCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "room is required"})
 		return
 	}
 
-	room := h.hub.GetRoom(roomCode)
+	room, err := h.hub.LookupRoom(roomCode)
+	if err != nil {
+		rejectAtCapacity(c)
+		return
+	}
 	if room == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
 		return
 	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if !h.conns.Acquire(ip) {
+		log.Printf("Connection limit reached for %s", ip)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many open connections"})
+		return
+	}
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			h.conns.Release(ip)
+		}
+	}()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	playerID := uuid.New().String()
-	player := game.NewPlayer(playerID, playerName, "", conn, false)
+	// Set read limit to prevent massive messages
+	conn.SetReadLimit(maxMessageSize)
 
-	if !room.AddPlayer(player) {
-		log.Printf("Room %s is full, rejecting player %s", roomCode, playerName)
-		conn.WriteJSON(gin.H{"type": "error", "error": "room is full"})
-		conn.Close()
+	protocol, ok := negotiatedProtocol(c.Request, conn)
+	if !ok {
+		log.Printf("Unsupported protocol requested: %v", websocket.Subprotocols(c.Request))
+		rejectConnection(conn, "", newProtocolError(models.ErrCodeUnsupportedProtocol, "unsupported protocol version"))
 		return
 	}
 
-	// Check if reclaiming host status
-	hostToken := c.Query("hostToken")
-	if hostToken != "" {
-		if room.ClaimHost(playerID, hostToken) {
-			log.Printf("Player %s reclaimed host status in room %s", playerName, roomCode)
+	var join *models.JoinPayload
+	var requestID string
+	legacyJoin := c.Query("name") != ""
+	if legacyJoin {
+		// Deprecated: query parameters end up in proxy logs, host token included
+		log.Printf("Deprecated query-string join for room %s", room.Code)
+		join = &models.JoinPayload{
+			Name:             c.Query("name"),
+			HostToken:        c.Query("hostToken"),
+			FacilitatorToken: c.Query("facilitatorToken"),
+		}
+	} else {
+		var msg *models.ClientMessage
+		msg, join, err = h.readJoin(conn)
+		if msg != nil {
+			requestID = msg.RequestID
+		}
+		if err != nil {
+			log.Printf("Join handshake failed for room %s: %v", room.Code, err)
+			rejectConnection(conn, requestID, err)
+			return
 		}
 	}
 
-	log.Printf("Player %s (%s) joined room %s", playerName, playerID, roomCode)
+	player, err := h.admit(room, conn, protocol, join)
+	if err != nil {
+		log.Printf("Rejecting player %s from room %s: %v", join.Name, room.Code, err)
+		rejectConnection(conn, requestID, err)
+		return
+	}
+
+	log.Printf("Player %s (%s) joined room %s", player.Name, player.ID, room.Code)
+
+	// Handshake clients learn their identity before the first sync
+	if !legacyJoin {
+		player.SendMessage(&models.ServerMessage{
+			Type:      models.MsgTypeWelcome,
+			RequestID: requestID,
+			Payload: models.WelcomePayload{
+				PlayerID:     player.ID,
+				SessionToken: player.SessionToken,
+				Role:         player.Role,
+				IsHost:       player.IsHost,
+			},
+		})
+	}
 
 	// Send initial state to the joining player
 	h.sendState(player, room)
 
 	// Send full state sync to all other players (ensures consistency, avoids race conditions)
-	for _, p := range room.Players {
-		if p.ID != playerID {
+	for _, p := range room.PlayerList() {
+		if p.ID != player.ID {
 			h.sendState(p, room)
 		}
 	}
 
-	// Handle messages
-	go h.handleMessages(player, room)
+	// Handle messages; the connection slot is held until the player disconnects
+	handedOff = true
+	go func() {
+		defer h.conns.Release(ip)
+		h.handleMessages(player, room, conn)
+	}()
+}
+
+// negotiatedProtocol returns the protocol version selected during the upgrade.
+// Clients that asked only for subprotocols we do not speak are rejected.
+func negotiatedProtocol(r *http.Request, conn *websocket.Conn) (int, bool) {
+	switch conn.Subprotocol() {
+	case models.SubprotocolV1:
+		return models.ProtocolV1, true
+	case "":
+		return models.ProtocolV0, len(websocket.Subprotocols(r)) == 0
+	default:
+		return 0, false
+	}
+}
+
+// readJoin waits for the join handshake, which must be the first message
+func (h *WebSocketHandler) readJoin(conn *websocket.Conn) (*models.ClientMessage, *models.JoinPayload, error) {
+	conn.SetReadDeadline(time.Now().Add(h.joinTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil, nil, newProtocolError(models.ErrCodeJoinTimeout, "join handshake timed out")
+		}
+		return nil, nil, err
+	}
+
+	msg, err := models.ParseClientMessage(data)
+	if err != nil {
+		return nil, nil, newProtocolError(models.ErrCodeInvalidMessage, "malformed message")
+	}
+	if msg.Type != models.MsgTypeJoin {
+		return msg, nil, newProtocolError(models.ErrCodeJoinRequired, "first message must be a join")
+	}
+
+	var join models.JoinPayload
+	if err := msg.DecodePayload(&join); err != nil {
+		return msg, nil, newProtocolError(models.ErrCodeInvalidPayload, "invalid join payload")
+	}
+	return msg, &join, nil
+}
+
+// admit validates a join request and adds (or resumes) the player in the room
+func (h *WebSocketHandler) admit(room *game.Room, conn *websocket.Conn, protocol int, join *models.JoinPayload) (*game.Player, error) {
+	name := strings.TrimSpace(join.Name)
+	if name == "" || utf8.RuneCountInString(name) > game.MaxPlayerNameLength {
+		return nil, newProtocolError(models.ErrCodeInvalidPayload, "name is required (max 50 characters)")
+	}
+
+	role := join.Role
+	if role == "" {
+		role = models.RoleVoter
+	}
+	if role != models.RoleVoter && role != models.RoleObserver {
+		return nil, newProtocolError(models.ErrCodeInvalidPayload, "unknown role: "+string(role))
+	}
+
+	if !room.CheckPassphrase(join.Passphrase) {
+		return nil, newProtocolError(models.ErrCodeInvalidPassphrase, "invalid passphrase")
+	}
+
+	player, oldConn := room.ResumePlayer(join.SessionToken, conn)
+	if player != nil {
+		if oldConn != nil {
+			oldConn.Close()
+		}
+		player.Protocol = protocol
+		log.Printf("Player %s resumed session in room %s", player.Name, room.Code)
+	} else {
+		player = game.NewPlayer(uuid.New().String(), name, "", conn, false)
+		player.Role = role
+		player.Protocol = protocol
+
+		if !room.AddPlayer(player) {
+			return nil, newProtocolError(models.ErrCodeRoomFull, "room is full")
+		}
+	}
+
+	// Check if reclaiming host status
+	if join.HostToken != "" {
+		if room.ClaimHost(player.ID, join.HostToken) {
+			log.Printf("Player %s reclaimed host status in room %s", player.Name, room.Code)
+		}
+	}
+	if join.FacilitatorToken != "" {
+		if room.ClaimFacilitator(player.ID, join.FacilitatorToken) {
+			log.Printf("Player %s joined room %s as co-host", player.Name, room.Code)
+		}
+	}
+
+	return player, nil
+}
+
+// rejectConnection reports why a connection is refused and closes it
+func rejectConnection(conn *websocket.Conn, requestID string, err error) {
+	msg := &models.ServerMessage{
+		Type:      models.MsgTypeError,
+		RequestID: requestID,
+		Error:     err.Error(),
+		Code:      models.ErrCodeInternal,
+	}
+	if perr, ok := err.(*protocolError); ok {
+		msg.Code = perr.Code
+	}
+	conn.WriteJSON(msg)
+	conn.Close()
 }
 
 // handleMessages handles incoming messages from a player
-func (h *WebSocketHandler) handleMessages(player *game.Player, room *game.Room) {
+func (h *WebSocketHandler) handleMessages(player *game.Player, room *game.Room, conn *websocket.Conn) {
+	// Messages are handled off the read loop, in order, so the loop notices
+	// the connection closing while one waits for Jira and cancels its
+	// requests. Later messages wait behind it, so a reveal cannot overtake
+	// the issue change sent before it. The player only leaves once the
+	// handler has stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan *models.ClientMessage, maxPendingMessages)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for msg := range messages {
+			if ctx.Err() == nil {
+				h.processMessage(ctx, player, room, msg)
+			}
+		}
+	}()
 	defer func() {
-		h.handleDisconnect(player, room)
+		cancel()
+		close(messages)
+		<-stopped
+		h.handleDisconnect(player, room, conn)
 	}()
 
-	// Set read limit to prevent massive messages
-	player.Conn.SetReadLimit(512 * 1024) // 512 KB
-
+	strikes := 0
 	for {
-		var msg models.ClientMessage
-		err := player.Conn.ReadJSON(&msg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
@@ -107,118 +413,276 @@ func (h *WebSocketHandler) handleMessages(player *game.Player, room *game.Room)
 
 		// Rate limit check
 		if !player.RateLimiter.Allow() {
-			log.Printf("Rate limit exceeded for player %s", player.Name)
-			// Optional: enable this if you want to notify the client
-			// player.SendMessage(&models.ServerMessage{
-			// 	Type:  models.MsgTypeError,
-			// 	Error: "rate limit exceeded",
-			// })
+			strikes++
+			if !h.handleRateLimited(player, conn, strikes) {
+				break
+			}
+			continue
+		}
+
+		msg, err := models.ParseClientMessage(data)
+		if err != nil {
+			h.sendError(player, "", newProtocolError(models.ErrCodeInvalidMessage, "malformed message"))
 			continue
 		}
 
-		h.processMessage(player, room, &msg)
+		select {
+		case messages <- msg:
+		default:
+			h.sendError(player, msg.RequestID, newProtocolError(models.ErrCodeRateLimited, "too many requests in progress"))
+		}
 	}
 }
 
-// processMessage processes a client message
-func (h *WebSocketHandler) processMessage(player *game.Player, room *game.Room, msg *models.ClientMessage) {
+// handleRateLimited applies the configured action to a player exceeding its
+// message rate limit. It returns false if the player must be disconnected.
+func (h *WebSocketHandler) handleRateLimited(player *game.Player, conn *websocket.Conn, strikes int) bool {
+	log.Printf("Rate limit exceeded for player %s (%d times)", player.Name, strikes)
+
+	switch h.rateLimitAction {
+	case RateLimitDrop:
+		return true
+	case RateLimitWarn:
+		if strikes <= h.maxWarnings {
+			h.sendError(player, "", newProtocolError(models.ErrCodeRateLimited, "rate limit exceeded, slow down"))
+			return true
+		}
+	}
+
+	log.Printf("Disconnecting player %s for exceeding the rate limit", player.Name)
+	h.sendError(player, "", newProtocolError(models.ErrCodeRateLimited, "rate limit exceeded, disconnecting"))
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded"),
+		time.Now().Add(time.Second))
+	return false
+}
+
+// processMessage processes a client message and answers with an ack or an error
+func (h *WebSocketHandler) processMessage(ctx context.Context, player *game.Player, room *game.Room, msg *models.ClientMessage) {
 	log.Printf("Received message type: '%s' from player %s", msg.Type, player.Name)
 
+	if err := h.dispatch(ctx, player, room, msg); err != nil {
+		h.sendError(player, msg.RequestID, err)
+		return
+	}
+	h.sendAck(player, msg.RequestID)
+}
+
+// dispatch decodes the message payload and routes it to its handler. ctx is
+// cancelled when the connection closes.
+func (h *WebSocketHandler) dispatch(ctx context.Context, player *game.Player, room *game.Room, msg *models.ClientMessage) error {
 	switch msg.Type {
 	case models.MsgTypeVote:
-		h.handleVote(player, room, msg.Vote)
+		if player.IsObserver() {
+			return newProtocolError(models.ErrCodeNotVoter, "observers cannot vote")
+		}
+		var payload models.VotePayload
+		if err := msg.DecodePayload(&payload); err != nil {
+			return newProtocolError(models.ErrCodeInvalidPayload, "invalid vote payload")
+		}
+		return h.handleVote(player, room, payload.Vote)
 
 	case models.MsgTypeReveal:
-		h.handleReveal(player, room)
+		return h.handleReveal(player, room)
 
 	case models.MsgTypeReset:
-		h.handleReset(player, room)
+		return h.handleReset(player, room)
 
 	case models.MsgTypeStartTimer:
-		h.handleStartTimer(player, room, msg.TimerDuration, msg.AutoReveal)
+		var payload models.StartTimerPayload
+		if err := msg.DecodePayload(&payload); err != nil {
+			return newProtocolError(models.ErrCodeInvalidPayload, "invalid timer payload")
+		}
+		return h.handleStartTimer(player, room, payload.TimerDuration, payload.AutoReveal)
 
 	case models.MsgTypeStopTimer:
-		h.handleStopTimer(player, room)
+		return h.handleStopTimer(player, room)
+
+	case models.MsgTypeJoin:
+		return newProtocolError(models.ErrCodeInvalidMessage, "already joined")
 
 	case models.MsgTypeSetIssue:
-		h.handleSetIssue(player, room, msg.Issue)
+		var payload models.SetIssuePayload
+		if err := msg.DecodePayload(&payload); err != nil || payload.Issue == nil {
+			return newProtocolError(models.ErrCodeInvalidPayload, "invalid issue payload")
+		}
+		return h.handleSetIssue(ctx, player, room, payload.Issue)
+
+	case models.MsgTypeLoadIssues:
+		var payload models.LoadIssuesPayload
+		if err := msg.DecodePayload(&payload); err != nil || payload.ID <= 0 {
+			return newProtocolError(models.ErrCodeInvalidPayload, "invalid load issues payload")
+		}
+		return h.handleLoadIssues(ctx, player, room, &payload)
+
+	case models.MsgTypeRotateHostToken:
+		return h.handleRotateHostToken(player, room, msg.RequestID)
+
+	case models.MsgTypeCreateInvite:
+		return h.handleCreateInvite(player, room, msg.RequestID)
+
+	case models.MsgTypeRevokeInvite:
+		return h.handleRevokeInvite(player, room)
 
 	default:
 		log.Printf("Unknown message type: '%s'", msg.Type)
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "unknown message type: " + string(msg.Type),
-		})
+		return newProtocolError(models.ErrCodeUnknownType, "unknown message type: "+string(msg.Type))
+	}
+}
+
+// sendError reports a failed request to the player, echoing its request ID
+func (h *WebSocketHandler) sendError(player *game.Player, requestID string, err error) {
+	perr, ok := err.(*protocolError)
+	if !ok {
+		perr = newProtocolError(models.ErrCodeInternal, err.Error())
 	}
+	player.SendMessage(&models.ServerMessage{
+		Type:      models.MsgTypeError,
+		RequestID: requestID,
+		Error:     perr.Message,
+		Code:      perr.Code,
+	})
+}
+
+// sendAck confirms a successful request. Legacy clients and requests without
+// an ID are not acknowledged.
+func (h *WebSocketHandler) sendAck(player *game.Player, requestID string) {
+	if player.Protocol < models.ProtocolV1 || requestID == "" {
+		return
+	}
+	player.SendMessage(&models.ServerMessage{
+		Type:      models.MsgTypeAck,
+		RequestID: requestID,
+	})
 }
 
 // handleVote handles a vote from a player
-func (h *WebSocketHandler) handleVote(player *game.Player, room *game.Room, vote string) {
-	if room.Vote(player.ID, vote) {
-		hasVoted := vote != ""
-		// Notify all players that this player has voted (or unvoted)
-		room.Broadcast(&models.ServerMessage{
-			Type: models.MsgTypeVoted,
-			Payload: map[string]interface{}{
-				"playerId": player.ID,
-				"hasVoted": hasVoted,
-			},
-		})
-		if hasVoted {
-			log.Printf("Player %s voted in room %s", player.Name, room.Code)
-		} else {
-			log.Printf("Player %s unvoted in room %s", player.Name, room.Code)
-		}
+func (h *WebSocketHandler) handleVote(player *game.Player, room *game.Room, vote string) error {
+	if !room.Vote(player.ID, vote) {
+		return newProtocolError(models.ErrCodeNotInRoom, "player is not in the room")
 	}
+
+	hasVoted := vote != ""
+	// Notify all players that this player has voted (or unvoted)
+	room.Broadcast(&models.ServerMessage{
+		Type: models.MsgTypeVoted,
+		Payload: map[string]interface{}{
+			"playerId": player.ID,
+			"hasVoted": hasVoted,
+		},
+	})
+	if hasVoted {
+		log.Printf("Player %s voted in room %s", player.Name, room.Code)
+	} else {
+		log.Printf("Player %s unvoted in room %s", player.Name, room.Code)
+	}
+	return nil
 }
 
 // handleReveal handles reveal request from host
-func (h *WebSocketHandler) handleReveal(player *game.Player, room *game.Room) {
-	if room.Reveal(player.ID) {
-		results := room.GetVotingResults()
-		room.Broadcast(&models.ServerMessage{
-			Type:    models.MsgTypeRevealed,
-			Payload: results,
-		})
-		log.Printf("Votes revealed in room %s by %s", room.Code, player.Name)
-	} else {
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "only the host can reveal votes",
-		})
+func (h *WebSocketHandler) handleReveal(player *game.Player, room *game.Room) error {
+	if !room.Reveal(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can reveal votes")
 	}
+	// Persist the round so it survives in the room history
+	h.hub.SaveRoom(room)
+
+	results := room.GetVotingResults()
+	room.Broadcast(&models.ServerMessage{
+		Type:    models.MsgTypeRevealed,
+		Payload: results,
+	})
+	log.Printf("Votes revealed in room %s by %s", room.Code, player.Name)
+	return nil
 }
 
 // handleReset handles reset request
-func (h *WebSocketHandler) handleReset(player *game.Player, room *game.Room) {
-	// Only host can reset
-	if player.ID != room.HostID {
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "only the host can reset",
-		})
-		return
+func (h *WebSocketHandler) handleReset(player *game.Player, room *game.Room) error {
+	// Only host or co-hosts can reset
+	if !room.CanModerate(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can reset")
 	}
 
 	room.Reset()
 
 	// Send full state to all players
-	for _, p := range room.Players {
+	for _, p := range room.PlayerList() {
 		h.sendState(p, room)
 	}
 
 	log.Printf("Room %s reset by %s", room.Code, player.Name)
+	return nil
+}
+
+// handleRotateHostToken issues a new host token to the host, invalidating the old one
+func (h *WebSocketHandler) handleRotateHostToken(player *game.Player, room *game.Room, requestID string) error {
+	if !room.HoldsHostToken(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can rotate the host token")
+	}
+
+	token, expiry := h.hub.IssueHostToken(room)
+	payload := models.TokenPayload{Kind: models.TokenKindHost, Token: token}
+	if expiry != nil {
+		expiresAt := expiry.UnixMilli()
+		payload.ExpiresAt = &expiresAt
+	}
+	player.SendMessage(&models.ServerMessage{
+		Type:      models.MsgTypeToken,
+		RequestID: requestID,
+		Payload:   payload,
+	})
+
+	log.Printf("Host token rotated in room %s", room.Code)
+	return nil
+}
+
+// handleCreateInvite issues a new facilitator invite to the host. Co-hosts
+// admitted with the previous invite keep their rights until revoked.
+func (h *WebSocketHandler) handleCreateInvite(player *game.Player, room *game.Room, requestID string) error {
+	if !room.HoldsHostToken(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can invite facilitators")
+	}
+
+	token := h.hub.IssueFacilitatorToken(room)
+	player.SendMessage(&models.ServerMessage{
+		Type:      models.MsgTypeToken,
+		RequestID: requestID,
+		Payload:   models.TokenPayload{Kind: models.TokenKindFacilitator, Token: token},
+	})
+
+	log.Printf("Facilitator invite created in room %s", room.Code)
+	return nil
+}
+
+// handleRevokeInvite invalidates the facilitator invite and demotes all co-hosts
+func (h *WebSocketHandler) handleRevokeInvite(player *game.Player, room *game.Room) error {
+	if !room.HoldsHostToken(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can revoke facilitators")
+	}
+
+	room.RevokeFacilitators()
+	h.hub.SaveRoom(room)
+
+	for _, p := range room.PlayerList() {
+		h.sendState(p, room)
+	}
+
+	log.Printf("Facilitators revoked in room %s", room.Code)
+	return nil
 }
 
 // handleDisconnect handles player disconnection
-func (h *WebSocketHandler) handleDisconnect(player *game.Player, room *game.Room) {
-	player.Conn.Close()
-	room.RemovePlayer(player.ID)
+func (h *WebSocketHandler) handleDisconnect(player *game.Player, room *game.Room, conn *websocket.Conn) {
+	conn.Close()
+	if !room.DetachPlayer(player.ID, conn) {
+		// The player resumed its session on a newer connection
+		return
+	}
 
 	log.Printf("Player %s left room %s", player.Name, room.Code)
 
 	// Send full state sync to all remaining players (ensures consistency)
-	for _, p := range room.Players {
+	for _, p := range room.PlayerList() {
 		h.sendState(p, room)
 	}
 
@@ -238,37 +702,31 @@ func (h *WebSocketHandler) sendState(player *game.Player, room *game.Room) {
 }
 
 // handleStartTimer handles timer start request from host
-func (h *WebSocketHandler) handleStartTimer(player *game.Player, room *game.Room, duration int, autoReveal bool) {
+func (h *WebSocketHandler) handleStartTimer(player *game.Player, room *game.Room, duration int, autoReveal bool) error {
 	if duration <= 0 || duration > 300 { // Max 5 minutes
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "invalid timer duration (1-300 seconds)",
-		})
-		return
+		return newProtocolError(models.ErrCodeInvalidTimer, "invalid timer duration (1-300 seconds)")
 	}
 
-	if room.StartTimer(player.ID, duration, autoReveal) {
-		endTime := time.Now().Add(time.Duration(duration) * time.Second).UnixMilli()
+	if !room.StartTimer(player.ID, duration, autoReveal) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can start the timer")
+	}
 
-		// Broadcast timer started to all players
-		room.Broadcast(&models.ServerMessage{
-			Type: models.MsgTypeTimerSync,
-			Payload: models.TimerState{
-				EndTime:    endTime,
-				AutoReveal: autoReveal,
-			},
-		})
+	endTime := time.Now().Add(time.Duration(duration) * time.Second).UnixMilli()
 
-		log.Printf("Timer started in room %s by %s: %ds (auto-reveal: %v)", room.Code, player.Name, duration, autoReveal)
+	// Broadcast timer started to all players
+	room.Broadcast(&models.ServerMessage{
+		Type: models.MsgTypeTimerSync,
+		Payload: models.TimerState{
+			EndTime:    endTime,
+			AutoReveal: autoReveal,
+		},
+	})
 
-		// Start goroutine to handle timer end
-		go h.handleTimerEnd(room, duration, autoReveal)
-	} else {
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "only the host can start the timer",
-		})
-	}
+	log.Printf("Timer started in room %s by %s: %ds (auto-reveal: %v)", room.Code, player.Name, duration, autoReveal)
+
+	// Start goroutine to handle timer end
+	go h.handleTimerEnd(room, duration, autoReveal)
+	return nil
 }
 
 // handleTimerEnd handles when timer reaches zero
@@ -287,8 +745,8 @@ func (h *WebSocketHandler) handleTimerEnd(room *game.Room, duration int, autoRev
 		})
 
 		// Auto-reveal if enabled
-		if autoReveal && !room.Revealed {
-			room.Revealed = true
+		if autoReveal && room.AutoReveal() {
+			h.hub.SaveRoom(room)
 			results := room.GetVotingResults()
 			room.Broadcast(&models.ServerMessage{
 				Type:    models.MsgTypeRevealed,
@@ -305,38 +763,131 @@ func (h *WebSocketHandler) handleTimerEnd(room *game.Room, duration int, autoRev
 }
 
 // handleStopTimer handles timer stop request from host
-func (h *WebSocketHandler) handleStopTimer(player *game.Player, room *game.Room) {
-	if room.StopTimer(player.ID) {
-		// Broadcast timer stopped to all players
-		room.Broadcast(&models.ServerMessage{
-			Type: models.MsgTypeTimerSync,
-			Payload: models.TimerState{
-				EndTime:    0,
-				AutoReveal: false,
-			},
-		})
-		log.Printf("Timer stopped in room %s by %s", room.Code, player.Name)
-	} else {
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "only the host can stop the timer",
-		})
+func (h *WebSocketHandler) handleStopTimer(player *game.Player, room *game.Room) error {
+	if !room.StopTimer(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can stop the timer")
 	}
+
+	// Broadcast timer stopped to all players
+	room.Broadcast(&models.ServerMessage{
+		Type: models.MsgTypeTimerSync,
+		Payload: models.TimerState{
+			EndTime:    0,
+			AutoReveal: false,
+		},
+	})
+	log.Printf("Timer stopped in room %s by %s", room.Code, player.Name)
+	return nil
 }
 
 // handleSetIssue handles setting the current Jira issue
-func (h *WebSocketHandler) handleSetIssue(player *game.Player, room *game.Room, issue *models.JiraIssue) {
-	if room.SetIssue(player.ID, issue) {
-		// Broadcast new state to all (or just payload with issue? Sync is safer)
-		// For now simple sync
-		for _, p := range room.Players {
-			h.sendState(p, room)
+func (h *WebSocketHandler) handleSetIssue(ctx context.Context, player *game.Player, room *game.Room, issue *models.JiraIssue) error {
+	if !room.CanModerate(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can set the issue")
+	}
+
+	issue = h.issueDetails(ctx, issue)
+	if err := ctx.Err(); err != nil {
+		// The connection closed while Jira was asked
+		return err
+	}
+	if !room.SetIssue(player.ID, issue) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can set the issue")
+	}
+
+	// Broadcast new state to all (or just payload with issue? Sync is safer)
+	// For now simple sync
+	for _, p := range room.PlayerList() {
+		h.sendState(p, room)
+	}
+	log.Printf("Issue set in room %s by %s: %s", room.Code, player.Name, issue.Key)
+	return nil
+}
+
+// handleLoadIssues replaces the room's queue with issues loaded from Jira
+func (h *WebSocketHandler) handleLoadIssues(ctx context.Context, player *game.Player, room *game.Room, payload *models.LoadIssuesPayload) error {
+	if !room.CanModerate(player.ID) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can load issues")
+	}
+
+	source := jira.IssueSource{Kind: jira.SourceKind(payload.Source), ID: payload.ID, SkipEstimated: payload.SkipEstimated}
+	switch source.Kind {
+	case jira.SourceSprint, jira.SourceBacklog, jira.SourceFilter:
+	default:
+		return newProtocolError(models.ErrCodeInvalidPayload, "unknown issue source: "+payload.Source)
+	}
+	if h.issues == nil {
+		return newProtocolError(models.ErrCodeJira, errJiraNotConfigured)
+	}
+
+	issues, err := h.issues.LoadIssues(ctx, source)
+	if err != nil {
+		log.Printf("Jira load issues error for room %s: %v", room.Code, err)
+		if errors.Is(err, jira.ErrUnavailable) {
+			return newProtocolError(models.ErrCodeJira, "jira is unavailable, try again shortly")
 		}
-		log.Printf("Issue set in room %s by %s: %s", room.Code, player.Name, issue.Key)
+		return newProtocolError(models.ErrCodeJira, "failed to load issues from jira")
+	}
+
+	queue := make([]models.JiraIssue, 0, len(issues))
+	for _, issue := range issues {
+		queue = append(queue, modelIssue(&issue))
+	}
+	// The first issue becomes current if none is, and is shown in full
+	if room.GetIssue() == nil && len(queue) > 0 {
+		queue[0] = *h.issueDetails(ctx, &queue[0])
+	}
+	if !room.SetQueue(player.ID, queue) {
+		return newProtocolError(models.ErrCodeNotHost, "only the host can load issues")
+	}
+	h.hub.SaveRoom(room)
+
+	for _, p := range room.PlayerList() {
+		h.sendState(p, room)
+	}
+	log.Printf("Loaded %d issues from %s %d into room %s", len(queue), source.Kind, source.ID, room.Code)
+	return nil
+}
+
+// applyIssueChange updates a room holding an issue that changed in Jira
+func (h *WebSocketHandler) applyIssueChange(room *game.Room, data json.RawMessage) {
+	var change issueChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		log.Printf("Invalid issue change for room %s: %v", room.Code, err)
+		return
+	}
+
+	var changed bool
+	if change.Issue != nil {
+		changed = room.UpdateIssue(*change.Issue)
 	} else {
-		player.SendMessage(&models.ServerMessage{
-			Type:  models.MsgTypeError,
-			Error: "only the host can set the issue",
-		})
+		changed = room.RemoveIssue(change.Key)
+	}
+	if !changed {
+		return
+	}
+	h.hub.SaveRoom(room)
+
+	for _, p := range room.PlayerList() {
+		h.sendState(p, room)
+	}
+	log.Printf("Updated issue %s in room %s from Jira", change.Key, room.Code)
+}
+
+// issueDetails returns the issue with its details fetched from Jira. Only the
+// key and summary are taken from the client, so players never see markup a
+// client made up; they are all that is shown when Jira is unavailable.
+func (h *WebSocketHandler) issueDetails(ctx context.Context, issue *models.JiraIssue) *models.JiraIssue {
+	basic := &models.JiraIssue{Key: issue.Key, Summary: issue.Summary}
+	if h.issues == nil || issue.Key == "" {
+		return basic
+	}
+
+	fetched, err := h.issues.GetIssue(ctx, issue.Key)
+	if err != nil {
+		log.Printf("Jira issue details error for %s: %v", issue.Key, err)
+		return basic
 	}
+	detailed := modelIssue(fetched)
+	return &detailed
 }
@@ -1,11 +1,19 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/poker/backend/internal/jira"
+	"github.com/poker/backend/internal/middleware"
 	"github.com/poker/backend/internal/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -219,3 +227,661 @@ func TestWebSocketHandler_StopTimer(t *testing.T) {
 	payload := msg.Payload.(map[string]interface{})
 	assert.Equal(t, float64(0), payload["endTime"])
 }
+
+func TestWebSocketHandler_ProtocolV1(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	baseURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+
+	ws, _, err := dialer.Dial(baseURL+"&name=Host", nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+	assert.Equal(t, models.SubprotocolV1, ws.Subprotocol())
+
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeSync, msg.Type)
+
+	// Typed payload with a request ID is acknowledged after the broadcast
+	ws.WriteJSON(map[string]interface{}{
+		"type":      models.MsgTypeVote,
+		"requestId": "req-1",
+		"payload":   models.VotePayload{Vote: "8"},
+	})
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeVoted, msg.Type)
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeAck, msg.Type)
+	assert.Equal(t, "req-1", msg.RequestID)
+
+	// Guest gets a coded error echoing its request ID
+	ws2, _, err := dialer.Dial(baseURL+"&name=Guest", nil)
+	assert.Nil(t, err)
+	defer ws2.Close()
+	ws2.ReadJSON(&msg)
+	ws.ReadJSON(&msg)
+
+	ws2.WriteJSON(models.ClientMessage{Type: models.MsgTypeReveal, RequestID: "req-2"})
+	ws2.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeError, msg.Type)
+	assert.Equal(t, models.ErrCodeNotHost, msg.Code)
+	assert.Equal(t, "req-2", msg.RequestID)
+	assert.NotEmpty(t, msg.Error)
+
+	// Missing issue payload is rejected
+	ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeSetIssue, RequestID: "req-3"})
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeInvalidPayload, msg.Code)
+	assert.Equal(t, "req-3", msg.RequestID)
+
+	// Unknown types are reported with a stable code
+	ws.WriteJSON(models.ClientMessage{Type: "dance"})
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeUnknownType, msg.Code)
+}
+
+func TestWebSocketHandler_UnsupportedProtocol(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"scrum-poker.v99"}}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code + "&name=Future"
+	ws, _, err := dialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeError, msg.Type)
+	assert.Equal(t, models.ErrCodeUnsupportedProtocol, msg.Code)
+	assert.Equal(t, 0, room.PlayerCount())
+}
+
+func TestWebSocketHandler_JoinHandshake(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	room.SetPassphrase("open sesame")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+
+	// Wrong passphrase is refused before the player is added
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	ws.WriteJSON(map[string]interface{}{
+		"type":      models.MsgTypeJoin,
+		"requestId": "join-1",
+		"payload":   models.JoinPayload{Name: "Intruder", Passphrase: "guess"},
+	})
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeInvalidPassphrase, msg.Code)
+	assert.Equal(t, "join-1", msg.RequestID)
+	ws.Close()
+	assert.Equal(t, 0, room.PlayerCount())
+
+	// Correct passphrase joins as observer
+	ws, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+	ws.WriteJSON(map[string]interface{}{
+		"type":      models.MsgTypeJoin,
+		"requestId": "join-2",
+		"payload": models.JoinPayload{
+			Name:       "Watcher",
+			Role:       models.RoleObserver,
+			Passphrase: "open sesame",
+		},
+	})
+	var welcome struct {
+		Type      models.MessageType    `json:"type"`
+		RequestID string                `json:"requestId"`
+		Payload   models.WelcomePayload `json:"payload"`
+	}
+	ws.ReadJSON(&welcome)
+	assert.Equal(t, models.MsgTypeWelcome, welcome.Type)
+	assert.Equal(t, "join-2", welcome.RequestID)
+	assert.Equal(t, models.RoleObserver, welcome.Payload.Role)
+	assert.NotEmpty(t, welcome.Payload.SessionToken)
+
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeSync, msg.Type)
+	assert.Equal(t, 1, room.PlayerCount())
+
+	// Observers cannot vote
+	ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeVote, Vote: "5"})
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeNotVoter, msg.Code)
+
+	// Reconnecting with the session token resumes the same player
+	ws2, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws2.Close()
+	ws2.WriteJSON(models.ClientMessage{
+		Type: models.MsgTypeJoin,
+		Payload: mustJSON(t, models.JoinPayload{
+			Name:         "Watcher",
+			SessionToken: welcome.Payload.SessionToken,
+			Passphrase:   "open sesame",
+		}),
+	})
+	var resumed struct {
+		Payload models.WelcomePayload `json:"payload"`
+	}
+	ws2.ReadJSON(&resumed)
+	assert.Equal(t, welcome.Payload.PlayerID, resumed.Payload.PlayerID)
+	ws2.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeSync, msg.Type)
+	assert.Equal(t, 1, room.PlayerCount())
+}
+
+func TestWebSocketHandler_JoinRequired(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	wsHandler.joinTimeout = 100 * time.Millisecond
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+
+	// Any other first message is refused
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+	ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeVote, Vote: "5"})
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeJoinRequired, msg.Code)
+
+	// Silent clients are dropped after the join timeout
+	ws2, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws2.Close()
+	ws2.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeJoinTimeout, msg.Code)
+	assert.Equal(t, 0, room.PlayerCount())
+}
+
+func TestWebSocketHandler_HostTokens(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	hostToken, _ := hub.IssueHostToken(room)
+	inviteToken := hub.IssueFacilitatorToken(room)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+
+	var msg models.ServerMessage
+	join := func(payload models.JoinPayload) *websocket.Conn {
+		ws, _, err := dialer.Dial(wsURL, nil)
+		assert.Nil(t, err)
+		ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeJoin, Payload: mustJSON(t, payload)})
+		ws.ReadJSON(&msg) // welcome
+		ws.ReadJSON(&msg) // sync
+		return ws
+	}
+
+	guest := join(models.JoinPayload{Name: "Guest"})
+	defer guest.Close()
+	host := join(models.JoinPayload{Name: "Host", HostToken: hostToken})
+	defer host.Close()
+	guest.ReadJSON(&msg)
+	facilitator := join(models.JoinPayload{Name: "Facilitator", FacilitatorToken: inviteToken})
+	defer facilitator.Close()
+	guest.ReadJSON(&msg)
+	host.ReadJSON(&msg)
+
+	hostID := room.HostID
+	assert.NotEmpty(t, hostID)
+	var facilitatorID string
+	for _, p := range room.PlayerList() {
+		if p.Name == "Facilitator" {
+			facilitatorID = p.ID
+		}
+	}
+	assert.True(t, room.CanModerate(facilitatorID))
+	assert.False(t, room.IsHost(facilitatorID))
+
+	// Co-hosts cannot rotate the host token
+	facilitator.WriteJSON(models.ClientMessage{Type: models.MsgTypeRotateHostToken, RequestID: "rot-1"})
+	facilitator.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeNotHost, msg.Code)
+
+	// The host receives the new token privately
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeRotateHostToken, RequestID: "rot-2"})
+	var issued struct {
+		Type      models.MessageType  `json:"type"`
+		RequestID string              `json:"requestId"`
+		Payload   models.TokenPayload `json:"payload"`
+	}
+	host.ReadJSON(&issued)
+	assert.Equal(t, models.MsgTypeToken, issued.Type)
+	assert.Equal(t, "rot-2", issued.RequestID)
+	assert.Equal(t, models.TokenKindHost, issued.Payload.Kind)
+	assert.NotEmpty(t, issued.Payload.Token)
+	assert.NotEqual(t, hostToken, issued.Payload.Token)
+	host.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeAck, msg.Type)
+
+	// The old token no longer claims the room
+	assert.False(t, room.ClaimHost(facilitatorID, hostToken))
+
+	// Revoking demotes co-hosts for everyone
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeRevokeInvite})
+	host.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeSync, msg.Type)
+	assert.False(t, room.CanModerate(facilitatorID))
+	assert.Equal(t, hostID, room.HostID)
+}
+
+func TestWebSocketHandler_PromotedGuestCannotRotate(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandler(hub)
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	hostToken, _ := hub.IssueHostToken(room)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+
+	var msg models.ServerMessage
+	host, _, err := dialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeJoin, Payload: mustJSON(t, models.JoinPayload{Name: "Host", HostToken: hostToken})})
+	host.ReadJSON(&msg) // welcome
+	host.ReadJSON(&msg) // sync
+	guest := dialJoin(t, dialer, wsURL, "Guest")
+	defer guest.Close()
+
+	// The host leaves and the guest is promoted
+	host.Close()
+	guest.ReadJSON(&msg)
+	assert.Eventually(t, func() bool { return room.PlayerCount() == 1 }, time.Second, 10*time.Millisecond)
+	guestID := room.PlayerList()[0].ID
+	assert.True(t, room.IsHost(guestID))
+
+	// Without the host token the guest cannot take it over or manage invites
+	guest.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for i, msgType := range []models.MessageType{models.MsgTypeRotateHostToken, models.MsgTypeCreateInvite, models.MsgTypeRevokeInvite} {
+		requestID := fmt.Sprintf("req-%d", i)
+		guest.WriteJSON(models.ClientMessage{Type: msgType, RequestID: requestID})
+		var reply models.ServerMessage
+		for reply.RequestID != requestID {
+			reply = models.ServerMessage{}
+			if !assert.Nil(t, guest.ReadJSON(&reply)) {
+				t.FailNow()
+			}
+		}
+		assert.Equal(t, models.MsgTypeError, reply.Type, msgType)
+		assert.Equal(t, models.ErrCodeNotHost, reply.Code, msgType)
+	}
+	assert.True(t, room.CheckHostToken(hostToken))
+}
+
+// dialJoin connects to a room and joins it with a join message, returning
+// once the player has been welcomed and sent the room state
+func dialJoin(t *testing.T, dialer websocket.Dialer, wsURL, name string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := dialer.Dial(wsURL, nil)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeJoin, Payload: mustJSON(t, models.JoinPayload{Name: name})})
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeWelcome, msg.Type)
+	ws.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeSync, msg.Type)
+	return ws
+}
+
+func mustJSON(t *testing.T, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	assert.Nil(t, err)
+	return data
+}
+
+func TestWebSocketHandler_OriginPolicy(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{
+		Origins: middleware.NewOriginPolicy([]string{"https://*.pages.dev"}),
+	})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code + "&name=Guest"
+
+	header := http.Header{"Origin": []string{"https://evil.example.com"}}
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, 0, room.PlayerCount())
+
+	header = http.Header{"Origin": []string{"https://preview.pages.dev"}}
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	assert.Nil(t, err)
+	defer ws.Close()
+}
+
+func TestWebSocketHandler_ConnectionLimits(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{MaxConnsPerIP: 1})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code + "&name=Guest"
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+
+	// Second concurrent connection from the same IP is refused
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// Closing the first connection frees the slot
+	ws.Close()
+	assert.Eventually(t, func() bool { return room.PlayerCount() == 0 }, time.Second, 10*time.Millisecond)
+	ws, _, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	ws.Close()
+}
+
+func TestWebSocketHandler_JoinRateLimit(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{JoinRate: 0.1, JoinBurst: 1})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code + "&name=Guest"
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestWebSocketHandler_MessageRateLimit(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{
+		RateLimitAction:      RateLimitWarn,
+		MaxRateLimitWarnings: 1,
+	})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code + "&name=Spammer"
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	assert.Nil(t, err)
+	defer ws.Close()
+
+	var msg models.ServerMessage
+	ws.ReadJSON(&msg)
+
+	// Exhaust the burst of 10, then get warned once and disconnected
+	for i := 0; i < 12; i++ {
+		ws.WriteJSON(models.ClientMessage{Type: models.MsgTypeVote, Vote: "5"})
+	}
+
+	var codes []models.ErrorCode
+	for {
+		var m models.ServerMessage
+		if err := ws.ReadJSON(&m); err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+			break
+		}
+		if m.Type == models.MsgTypeError {
+			codes = append(codes, m.Code)
+		}
+	}
+	assert.Equal(t, []models.ErrorCode{models.ErrCodeRateLimited, models.ErrCodeRateLimited}, codes)
+	assert.Eventually(t, func() bool { return room.PlayerCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestParseRateLimitAction(t *testing.T) {
+	for value, expected := range map[string]RateLimitAction{
+		"":            RateLimitWarn,
+		"warn":        RateLimitWarn,
+		" Disconnect": RateLimitDisconnect,
+		"drop":        RateLimitDrop,
+	} {
+		action, err := ParseRateLimitAction(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, action, value)
+	}
+
+	_, err := ParseRateLimitAction("kick")
+	assert.Error(t, err)
+}
+
+// fakeIssues serves fixed issues for any source, recording the requests
+type fakeIssues struct {
+	issues  []jira.Issue
+	details map[string]*jira.Issue
+	sources []jira.IssueSource
+}
+
+func (f *fakeIssues) GetIssue(ctx context.Context, key string) (*jira.Issue, error) {
+	if issue, ok := f.details[key]; ok {
+		return issue, nil
+	}
+	return nil, errors.New("issue not found: " + key)
+}
+
+func (f *fakeIssues) LoadIssues(ctx context.Context, source jira.IssueSource) ([]jira.Issue, error) {
+	f.sources = append(f.sources, source)
+	return f.issues, nil
+}
+
+func TestWebSocketHandler_LoadIssues(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	loader := &fakeIssues{issues: []jira.Issue{
+		{Key: "WEB-1", Summary: "Login"},
+		{Key: "WEB-2", Summary: "Logout"},
+		{Key: "WEB-3", Summary: "Signup"},
+	}, details: map[string]*jira.Issue{
+		"WEB-1": {Key: "WEB-1", Summary: "Login", Status: "To Do", Description: "<p>Details</p>"},
+	}}
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{Issues: loader})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	baseURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+	var msg models.ServerMessage
+
+	host := dialJoin(t, dialer, baseURL, "Host")
+	defer host.Close()
+	guest := dialJoin(t, dialer, baseURL, "Guest")
+	defer guest.Close()
+	host.ReadJSON(&msg)
+
+	// Only moderators load issues, and only from known sources
+	load := models.LoadIssuesPayload{Source: "sprint", ID: 7, SkipEstimated: true}
+	guest.WriteJSON(models.ClientMessage{Type: models.MsgTypeLoadIssues, Payload: mustJSON(t, load)})
+	guest.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeNotHost, msg.Code)
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeLoadIssues, Payload: mustJSON(t, models.LoadIssuesPayload{Source: "epic", ID: 7})})
+	host.ReadJSON(&msg)
+	assert.Equal(t, models.ErrCodeInvalidPayload, msg.Code)
+	assert.Empty(t, loader.sources)
+
+	// The first issue becomes current and the rest are queued for everyone
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeLoadIssues, RequestID: "load-1", Payload: mustJSON(t, load)})
+	var sync struct {
+		Type    models.MessageType `json:"type"`
+		Payload models.RoomState   `json:"payload"`
+	}
+	guest.ReadJSON(&sync)
+	assert.Equal(t, models.MsgTypeSync, sync.Type)
+	assert.Equal(t, "WEB-1", sync.Payload.CurrentIssue.Key)
+	assert.Equal(t, "<p>Details</p>", sync.Payload.CurrentIssue.Description, "the current issue is shown in full")
+	assert.Equal(t, []models.JiraIssue{{Key: "WEB-2", Summary: "Logout"}, {Key: "WEB-3", Summary: "Signup"}}, sync.Payload.Queue)
+	assert.Equal(t, []jira.IssueSource{{Kind: jira.SourceSprint, ID: 7, SkipEstimated: true}}, loader.sources)
+	host.ReadJSON(&msg)
+	host.ReadJSON(&msg)
+	assert.Equal(t, models.MsgTypeAck, msg.Type)
+
+	// Setting a queued issue takes it off the queue. Details come from Jira
+	// only, so markup sent by a client never reaches other players.
+	forged := &models.JiraIssue{Key: "WEB-3", Summary: "Signup", Description: "<script>alert(1)</script>"}
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeSetIssue, Payload: mustJSON(t, models.SetIssuePayload{Issue: forged})})
+	sync.Payload = models.RoomState{}
+	guest.ReadJSON(&sync)
+	assert.Equal(t, &models.JiraIssue{Key: "WEB-3", Summary: "Signup"}, sync.Payload.CurrentIssue)
+	assert.Equal(t, []models.JiraIssue{{Key: "WEB-2", Summary: "Logout"}}, sync.Payload.Queue)
+}
+
+// gatedIssues holds issue lookups until released or the connection's
+// context is cancelled
+type gatedIssues struct {
+	fakeIssues
+	release   chan struct{}
+	cancelled chan struct{}
+}
+
+func (g *gatedIssues) GetIssue(ctx context.Context, key string) (*jira.Issue, error) {
+	select {
+	case <-g.release:
+		return g.fakeIssues.GetIssue(ctx, key)
+	case <-ctx.Done():
+		close(g.cancelled)
+		return nil, ctx.Err()
+	}
+}
+
+func TestWebSocketHandler_JiraMessagesInOrder(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	loader := &gatedIssues{
+		fakeIssues: fakeIssues{details: map[string]*jira.Issue{"WEB-2": {Key: "WEB-2", Summary: "Logout"}}},
+		release:    make(chan struct{}),
+		cancelled:  make(chan struct{}),
+	}
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{Issues: loader})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?room=" + room.Code
+	host := dialJoin(t, dialer, wsURL, "Host")
+	host.SetReadDeadline(time.Now().Add(5 * time.Second))
+	readUntil := func(requestID string) models.ServerMessage {
+		var msg models.ServerMessage
+		for msg.RequestID != requestID {
+			msg = models.ServerMessage{}
+			if !assert.Nil(t, host.ReadJSON(&msg)) {
+				t.FailNow()
+			}
+		}
+		return msg
+	}
+
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeVote, RequestID: "vote-1", Payload: mustJSON(t, models.VotePayload{Vote: "5"})})
+	readUntil("vote-1")
+
+	// A reveal sent after an issue change waits for the issue to be fetched
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeSetIssue, RequestID: "issue-1", Payload: mustJSON(t, models.SetIssuePayload{Issue: &models.JiraIssue{Key: "WEB-2"}})})
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeReveal, RequestID: "reveal-1"})
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, room.GetHistory(), "reveal overtook the issue change")
+	close(loader.release)
+
+	assert.Equal(t, models.MsgTypeAck, readUntil("issue-1").Type)
+	assert.Equal(t, models.MsgTypeAck, readUntil("reveal-1").Type)
+	if history := room.GetHistory(); assert.Len(t, history, 1) {
+		assert.Equal(t, "WEB-2", history[0].Issue.Key)
+	}
+}
+
+func TestWebSocketHandler_JiraCancelledOnDisconnect(t *testing.T) {
+	router, hub := setupTestRouter()
+	defer hub.Stop()
+	loader := &gatedIssues{release: make(chan struct{}), cancelled: make(chan struct{})}
+	wsHandler := NewWebSocketHandlerWithConfig(hub, WebSocketConfig{Issues: loader})
+	router.GET("/ws", wsHandler.HandleConnection)
+
+	room := hub.CreateRoom(24)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{models.SubprotocolV1}}
+	host := dialJoin(t, dialer, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws?room="+room.Code, "Host")
+
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeSetIssue, Payload: mustJSON(t, models.SetIssuePayload{Issue: &models.JiraIssue{Key: "WEB-2"}})})
+	host.WriteJSON(models.ClientMessage{Type: models.MsgTypeReveal})
+	time.Sleep(50 * time.Millisecond)
+
+	// The request is cancelled when the connection closes, and the player
+	// only leaves once the messages behind it are dropped
+	host.Close()
+	select {
+	case <-loader.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("jira request outlived the connection")
+	}
+	assert.Eventually(t, func() bool { return room.PlayerCount() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, room.GetIssue())
+	assert.Empty(t, room.GetHistory())
+}
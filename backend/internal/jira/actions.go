@@ -0,0 +1,175 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions are done after an estimate is written; each is optional
+type Actions struct {
+	Comment    bool   `json:"comment,omitempty"`    // Comment with the vote distribution and participants
+	Label      string `json:"label,omitempty"`      // Label to add
+	Transition string `json:"transition,omitempty"` // Status to move the issue to
+}
+
+// VoteSummary describes the round an estimate was agreed in
+type VoteSummary struct {
+	Estimate Estimate
+	Votes    Votes
+}
+
+// Vote is a participant's vote in the round
+type Vote struct {
+	Name string `json:"name"`
+	Vote string `json:"vote"`
+}
+
+// Votes are the votes of a round. Participants may share a name.
+type Votes []Vote
+
+// UnmarshalJSON also reads the votes of writes queued by older versions,
+// which were keyed by participant name
+func (v *Votes) UnmarshalJSON(data []byte) error {
+	var byName map[string]string
+	if json.Unmarshal(data, &byName) == nil {
+		*v = make(Votes, 0, len(byName))
+		for name, vote := range byName {
+			*v = append(*v, Vote{Name: name, Vote: vote})
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]Vote)(v))
+}
+
+// Comment returns the summary as comment text, one statement per line
+func (s VoteSummary) Comment() string {
+	estimate := s.Estimate.Value
+	if p := s.Estimate.Points; p != nil && strconv.FormatFloat(*p, 'f', -1, 64) != estimate {
+		estimate += fmt.Sprintf(" (%s points)", strconv.FormatFloat(*p, 'f', -1, 64))
+	}
+	lines := []string{"Estimated at " + estimate + " in planning poker."}
+	if len(s.Votes) == 0 {
+		return lines[0]
+	}
+
+	counts := make(map[string]int)
+	for _, v := range s.Votes {
+		counts[v.Vote]++
+	}
+	values := make([]string, 0, len(counts))
+	for vote := range counts {
+		values = append(values, vote)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	distribution := make([]string, len(values))
+	for i, vote := range values {
+		distribution[i] = fmt.Sprintf("%s ×%d", vote, counts[vote])
+	}
+	votes := append(Votes(nil), s.Votes...)
+	sort.SliceStable(votes, func(i, j int) bool { return votes[i].Name < votes[j].Name })
+	participants := make([]string, len(votes))
+	for i, v := range votes {
+		participants[i] = fmt.Sprintf("%s (%s)", v.Name, v.Vote)
+	}
+
+	return strings.Join(append(lines,
+		"Votes: "+strings.Join(distribution, ", "),
+		"Participants: "+strings.Join(participants, ", "),
+	), "\n")
+}
+
+// AddComment comments on an issue. Each line of text becomes a paragraph.
+func (c *Client) AddComment(ctx context.Context, issueKey, text string) error {
+	// Server takes wiki markup, Cloud a document
+	var body interface{} = text
+	if c.Flavor(ctx) != FlavorServer {
+		paragraphs := []map[string]interface{}{}
+		for _, line := range strings.Split(text, "\n") {
+			paragraphs = append(paragraphs, map[string]interface{}{
+				"type":    "paragraph",
+				"content": []map[string]interface{}{{"type": "text", "text": line}},
+			})
+		}
+		body = map[string]interface{}{"type": "doc", "version": 1, "content": paragraphs}
+	}
+
+	if err := c.post(ctx, c.apiPath(ctx, "issue/"+url.PathEscape(issueKey)+"/comment"), map[string]interface{}{"body": body}, nil); err != nil {
+		return fmt.Errorf("jira comment failed: %w", err)
+	}
+	return nil
+}
+
+// AddLabel adds a label to an issue, keeping its other labels
+func (c *Client) AddLabel(ctx context.Context, issueKey, label string) error {
+	payload := map[string]interface{}{
+		"update": map[string]interface{}{
+			"labels": []map[string]string{{"add": label}},
+		},
+	}
+	err := c.put(ctx, c.apiPath(ctx, "issue/"+url.PathEscape(issueKey)), payload)
+	c.Invalidate(issueKey)
+	if err != nil {
+		return fmt.Errorf("jira label failed: %w", err)
+	}
+	return nil
+}
+
+// TransitionTo moves an issue to a status through the workflow transition
+// leading to it, matched by status or transition name. An issue already in
+// the status is left alone.
+func (c *Client) TransitionTo(ctx context.Context, issueKey, status string) error {
+	path := c.apiPath(ctx, "issue/"+url.PathEscape(issueKey)+"/transitions")
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create transitions request: %w", err)
+	}
+	var response struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := c.do(req, &response); err != nil {
+		return fmt.Errorf("jira transitions failed: %w", err)
+	}
+
+	for _, t := range response.Transitions {
+		if strings.EqualFold(t.To.Name, status) || strings.EqualFold(t.Name, status) {
+			payload := map[string]interface{}{"transition": map[string]string{"id": t.ID}}
+			err := c.post(ctx, path, payload, nil)
+			c.Invalidate(issueKey)
+			if err != nil {
+				return fmt.Errorf("jira transition failed: %w", err)
+			}
+			return nil
+		}
+	}
+
+	issue, err := c.GetIssue(ctx, issueKey)
+	if err == nil && strings.EqualFold(issue.Status, status) {
+		return nil
+	}
+	return fmt.Errorf("no transition of %s to %q", issueKey, status)
+}
+
+// put sends a JSON request that has no response body
+func (c *Client) put(ctx context.Context, path string, payload interface{}) error {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "PUT", path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.do(req, nil)
+}
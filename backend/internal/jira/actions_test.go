@@ -0,0 +1,61 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVoteSummary_Comment(t *testing.T) {
+	points := 3.0
+	summary := VoteSummary{
+		Estimate: Estimate{Value: "M", Points: &points},
+		Votes:    Votes{{Name: "Cy", Vote: "L"}, {Name: "Ada", Vote: "M"}, {Name: "Bo", Vote: "M"}, {Name: "Ada", Vote: "L"}},
+	}
+	assert.Equal(t, "Estimated at M (3 points) in planning poker.\n"+
+		"Votes: L ×2, M ×2\n"+
+		"Participants: Ada (M), Ada (L), Bo (M), Cy (L)", summary.Comment())
+
+	assert.Equal(t, "Estimated at 5 in planning poker.", VoteSummary{Estimate: PointsEstimate(5)}.Comment())
+}
+
+func TestVotes_UnmarshalJSON(t *testing.T) {
+	var votes Votes
+	assert.Nil(t, json.Unmarshal([]byte(`[{"name":"Ada","vote":"5"},{"name":"Ada","vote":"3"}]`), &votes))
+	assert.Equal(t, Votes{{Name: "Ada", Vote: "5"}, {Name: "Ada", Vote: "3"}}, votes)
+
+	// Writes queued by older versions kept votes by name
+	assert.Nil(t, json.Unmarshal([]byte(`{"Ada":"5"}`), &votes))
+	assert.Equal(t, Votes{{Name: "Ada", Vote: "5"}}, votes)
+}
+
+func TestClient_Actions(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First", "labels": []interface{}{"payments"}, "status": map[string]string{"name": "To Do"}})
+
+		summary := VoteSummary{Estimate: PointsEstimate(5), Votes: Votes{{Name: "Ada", Vote: "5"}}}
+		assert.Nil(t, client.AddComment(context.Background(), "WEB-1", summary.Comment()))
+		assert.Nil(t, client.AddLabel(context.Background(), "WEB-1", "estimated"))
+		assert.Nil(t, client.TransitionTo(context.Background(), "WEB-1", "ready"))
+
+		// Cloud takes comments as documents, Server as text
+		if f.cloud() {
+			doc := f.comments["WEB-1"][0].(map[string]interface{})
+			assert.Equal(t, "doc", doc["type"])
+			assert.Len(t, doc["content"], 3)
+		} else {
+			assert.Equal(t, summary.Comment(), f.comments["WEB-1"][0])
+		}
+		issue, err := client.GetIssue(context.Background(), "WEB-1")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"payments", "estimated"}, issue.Labels)
+		assert.Equal(t, "Ready", issue.Status)
+
+		// Already in the status is fine
+		assert.Nil(t, client.TransitionTo(context.Background(), "WEB-1", "Ready"))
+		assert.EqualError(t, client.TransitionTo(context.Background(), "WEB-1", "Done"), `no transition of WEB-1 to "Done"`)
+		assert.NotNil(t, client.AddComment(context.Background(), "WEB-404", "Hello"))
+	})
+}
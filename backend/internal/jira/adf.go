@@ -0,0 +1,215 @@
+package jira
+
+import (
+	"encoding/json"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adfNode is a node of an Atlassian Document Format document
+type adfNode struct {
+	Type    string                 `json:"type"`
+	Text    string                 `json:"text,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Marks   []adfMark              `json:"marks,omitempty"`
+	Content []adfNode              `json:"content,omitempty"`
+}
+
+type adfMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// RenderRichText renders a rich text field as HTML that is safe to insert
+// into a page: every piece of text is escaped, only a fixed set of tags is
+// produced and links are limited to http(s) and mailto. Cloud returns rich
+// text as an Atlassian Document Format document, Server as plain text with
+// wiki markup, which is shown as text.
+func RenderRichText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return renderPlainText(text)
+	}
+
+	var doc adfNode
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	renderNodes(&b, doc.Content)
+	return b.String()
+}
+
+// renderPlainText turns text into paragraphs split on blank lines
+func renderPlainText(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if para = strings.TrimSpace(para); para == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		b.WriteString("</p>")
+	}
+	return b.String()
+}
+
+func renderNodes(b *strings.Builder, nodes []adfNode) {
+	for i := range nodes {
+		renderNode(b, &nodes[i])
+	}
+}
+
+// blockTags maps block nodes to the element they render as
+var blockTags = map[string]string{
+	"paragraph":   "p",
+	"bulletList":  "ul",
+	"orderedList": "ol",
+	"listItem":    "li",
+	"blockquote":  "blockquote",
+	"table":       "table",
+	"tableRow":    "tr",
+	"tableHeader": "th",
+	"tableCell":   "td",
+	"panel":       "aside",
+	"taskList":    "ul",
+	"taskItem":    "li",
+}
+
+func renderNode(b *strings.Builder, n *adfNode) {
+	if tag, ok := blockTags[n.Type]; ok {
+		b.WriteString("<" + tag + ">")
+		renderNodes(b, n.Content)
+		b.WriteString("</" + tag + ">")
+		return
+	}
+
+	switch n.Type {
+	case "text":
+		renderText(b, n)
+	case "heading":
+		level := int(attrNumber(n.Attrs, "level"))
+		if level < 1 || level > 6 {
+			level = 3
+		}
+		tag := "h" + strconv.Itoa(level)
+		b.WriteString("<" + tag + ">")
+		renderNodes(b, n.Content)
+		b.WriteString("</" + tag + ">")
+	case "codeBlock":
+		b.WriteString("<pre><code>")
+		renderNodes(b, n.Content)
+		b.WriteString("</code></pre>")
+	case "hardBreak":
+		b.WriteString("<br>")
+	case "rule":
+		b.WriteString("<hr>")
+	case "mention":
+		b.WriteString(html.EscapeString(attrString(n.Attrs, "text")))
+	case "emoji":
+		text := attrString(n.Attrs, "text")
+		if text == "" {
+			text = attrString(n.Attrs, "shortName")
+		}
+		b.WriteString(html.EscapeString(text))
+	case "status":
+		b.WriteString("<strong>" + html.EscapeString(attrString(n.Attrs, "text")) + "</strong>")
+	case "date":
+		if ms, err := strconv.ParseInt(attrString(n.Attrs, "timestamp"), 10, 64); err == nil {
+			b.WriteString(time.UnixMilli(ms).UTC().Format("2006-01-02"))
+		}
+	case "inlineCard", "blockCard", "embedCard":
+		href := attrString(n.Attrs, "url")
+		if safeURL(href) {
+			b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="noopener noreferrer">` + html.EscapeString(href) + "</a>")
+		}
+	case "expand", "nestedExpand":
+		if title := attrString(n.Attrs, "title"); title != "" {
+			b.WriteString("<p><strong>" + html.EscapeString(title) + "</strong></p>")
+		}
+		renderNodes(b, n.Content)
+	case "mediaSingle", "mediaGroup", "media":
+		// Attachments need the user's Jira session to load, so are only hinted at
+		if n.Type != "media" {
+			b.WriteString("<p><em>[attachment]</em></p>")
+		}
+	default:
+		renderNodes(b, n.Content)
+	}
+}
+
+// markTags maps text marks to the element they render as
+var markTags = map[string]string{
+	"strong":    "strong",
+	"em":        "em",
+	"code":      "code",
+	"strike":    "s",
+	"underline": "u",
+}
+
+func renderText(b *strings.Builder, n *adfNode) {
+	var closing []string
+	for _, m := range n.Marks {
+		if tag, ok := markTags[m.Type]; ok {
+			b.WriteString("<" + tag + ">")
+			closing = append(closing, "</"+tag+">")
+			continue
+		}
+		switch m.Type {
+		case "link":
+			if href := attrString(m.Attrs, "href"); safeURL(href) {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="noopener noreferrer">`)
+				closing = append(closing, "</a>")
+			}
+		case "subsup":
+			tag := "sub"
+			if attrString(m.Attrs, "type") == "sup" {
+				tag = "sup"
+			}
+			b.WriteString("<" + tag + ">")
+			closing = append(closing, "</"+tag+">")
+		}
+	}
+
+	b.WriteString(html.EscapeString(n.Text))
+	for i := len(closing) - 1; i >= 0; i-- {
+		b.WriteString(closing[i])
+	}
+}
+
+// safeURL reports whether a link target cannot run script
+func safeURL(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func attrString(attrs map[string]interface{}, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+func attrNumber(attrs map[string]interface{}, key string) float64 {
+	if v, ok := attrs[key].(float64); ok {
+		return v
+	}
+	return 0
+}
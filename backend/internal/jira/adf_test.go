@@ -0,0 +1,62 @@
+package jira
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderRichText(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "empty", raw: `null`, want: ``},
+		{
+			name: "plain text is escaped",
+			raw:  `"Fix <b>this</b>\nnow\n\nThen that"`,
+			want: `<p>Fix &lt;b&gt;this&lt;/b&gt;<br>now</p><p>Then that</p>`,
+		},
+		{
+			name: "document",
+			raw: `{"type":"doc","version":1,"content":[
+				{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Goal"}]},
+				{"type":"paragraph","content":[
+					{"type":"text","text":"Use "},
+					{"type":"text","text":"SSO","marks":[{"type":"strong"},{"type":"link","attrs":{"href":"https://example.com/sso"}}]},
+					{"type":"hardBreak"},
+					{"type":"mention","attrs":{"text":"@ada"}}
+				]},
+				{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"a < b"}]}]}]},
+				{"type":"codeBlock","content":[{"type":"text","text":"x := <-ch"}]}
+			]}`,
+			want: `<h2>Goal</h2>` +
+				`<p>Use <strong><a href="https://example.com/sso" rel="noopener noreferrer">SSO</a></strong><br>@ada</p>` +
+				`<ul><li><p>a &lt; b</p></li></ul>` +
+				`<pre><code>x := &lt;-ch</code></pre>`,
+		},
+		{
+			name: "script links are dropped",
+			raw: `{"type":"doc","content":[{"type":"paragraph","content":[
+				{"type":"text","text":"click","marks":[{"type":"link","attrs":{"href":"javascript:alert(1)"}}]},
+				{"type":"inlineCard","attrs":{"url":"javascript:alert(2)"}}
+			]}]}`,
+			want: `<p>click</p>`,
+		},
+		{
+			name: "attributes cannot break out",
+			raw: `{"type":"doc","content":[{"type":"paragraph","content":[
+				{"type":"text","text":"x","marks":[{"type":"link","attrs":{"href":"https://e.com/\"onmouseover=\"alert(1)"}}]}
+			]}]}`,
+			want: `<p><a href="https://e.com/&#34;onmouseover=&#34;alert(1)" rel="noopener noreferrer">x</a></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RenderRichText(json.RawMessage(tt.raw)))
+		})
+	}
+}
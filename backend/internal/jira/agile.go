@@ -0,0 +1,213 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxSourceIssues is the most issues loaded from one sprint, backlog or filter
+	MaxSourceIssues = 200
+
+	// agilePageSize is the page size requested from the Agile API
+	agilePageSize = 50
+
+	// maxListed is the most boards or sprints listed
+	maxListed = 500
+)
+
+// SourceKind says where issues to estimate are loaded from
+type SourceKind string
+
+const (
+	SourceSprint  SourceKind = "sprint"  // A sprint, by ID
+	SourceBacklog SourceKind = "backlog" // A board's backlog, by board ID
+	SourceFilter  SourceKind = "filter"  // A saved filter, by ID
+)
+
+// IssueSource identifies a list of issues to estimate
+type IssueSource struct {
+	Kind          SourceKind `json:"kind"`
+	ID            int        `json:"id"`
+	SkipEstimated bool       `json:"skipEstimated,omitempty"` // Leave out issues that have story points
+}
+
+// Board is a Scrum or Kanban board
+type Board struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"` // "scrum" or "kanban"
+	ProjectKey string `json:"projectKey,omitempty"`
+}
+
+// Sprint is a sprint of a Scrum board
+type Sprint struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	State     string `json:"state"` // "active", "future" or "closed"
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+	Goal      string `json:"goal,omitempty"`
+}
+
+// agileGet fetches a page of the Agile API into v
+func (c *Client) agileGet(ctx context.Context, path string, query url.Values, v interface{}) error {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", "rest/agile/1.0/"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := c.do(req, v); err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	return nil
+}
+
+// ListBoards returns the boards of a project, or all boards visible to the
+// client when projectKey is empty
+func (c *Client) ListBoards(ctx context.Context, projectKey string) ([]Board, error) {
+	var boards []Board
+	for startAt := 0; ; {
+		query := url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(agilePageSize)},
+		}
+		if projectKey != "" {
+			query.Set("projectKeyOrId", projectKey)
+		}
+
+		var page struct {
+			IsLast bool `json:"isLast"`
+			Values []struct {
+				ID       int    `json:"id"`
+				Name     string `json:"name"`
+				Type     string `json:"type"`
+				Location struct {
+					ProjectKey string `json:"projectKey"`
+				} `json:"location"`
+			} `json:"values"`
+		}
+		if err := c.agileGet(ctx, "board", query, &page); err != nil {
+			return nil, fmt.Errorf("jira list boards failed: %w", err)
+		}
+
+		for _, b := range page.Values {
+			boards = append(boards, Board{ID: b.ID, Name: b.Name, Type: b.Type, ProjectKey: b.Location.ProjectKey})
+		}
+		startAt += len(page.Values)
+		if page.IsLast || len(page.Values) == 0 || len(boards) >= maxListed {
+			return boards, nil
+		}
+	}
+}
+
+// ListSprints returns the sprints of a board in the given states ("active",
+// "future", "closed"), or in any state when none are given
+func (c *Client) ListSprints(ctx context.Context, boardID int, states ...string) ([]Sprint, error) {
+	var sprints []Sprint
+	for startAt := 0; ; {
+		query := url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(agilePageSize)},
+		}
+		if len(states) > 0 {
+			query.Set("state", strings.Join(states, ","))
+		}
+
+		var page struct {
+			IsLast bool     `json:"isLast"`
+			Values []Sprint `json:"values"`
+		}
+		if err := c.agileGet(ctx, "board/"+strconv.Itoa(boardID)+"/sprint", query, &page); err != nil {
+			return nil, fmt.Errorf("jira list sprints failed: %w", err)
+		}
+
+		sprints = append(sprints, page.Values...)
+		startAt += len(page.Values)
+		if page.IsLast || len(page.Values) == 0 || len(sprints) >= maxListed {
+			return sprints, nil
+		}
+	}
+}
+
+// LoadIssues returns the issues of a sprint, backlog or filter in their rank
+// order, at most MaxSourceIssues of them
+func (c *Client) LoadIssues(ctx context.Context, source IssueSource) ([]Issue, error) {
+	if source.ID <= 0 {
+		return nil, fmt.Errorf("invalid %s id: %d", source.Kind, source.ID)
+	}
+
+	switch source.Kind {
+	case SourceSprint:
+		return c.agileIssues(ctx, "sprint/"+strconv.Itoa(source.ID)+"/issue", source.SkipEstimated)
+	case SourceBacklog:
+		return c.agileIssues(ctx, "board/"+strconv.Itoa(source.ID)+"/backlog", source.SkipEstimated)
+	case SourceFilter:
+		return c.filterIssues(ctx, source.ID, source.SkipEstimated)
+	default:
+		return nil, fmt.Errorf("unknown issue source: %q", source.Kind)
+	}
+}
+
+// agileIssues pages through an Agile API issue list
+func (c *Client) agileIssues(ctx context.Context, path string, skipEstimated bool) ([]Issue, error) {
+	var issues []Issue
+	for startAt := 0; len(issues) < MaxSourceIssues; {
+		query := url.Values{
+			"startAt":    {strconv.Itoa(startAt)},
+			"maxResults": {strconv.Itoa(agilePageSize)},
+			"fields":     {strings.Join(c.listFields(), ",")},
+		}
+		if skipEstimated {
+			query.Set("jql", unestimatedClause(c.config.StoryPointsField))
+		}
+
+		var page struct {
+			Total  int        `json:"total"`
+			Issues []apiIssue `json:"issues"`
+		}
+		if err := c.agileGet(ctx, path, query, &page); err != nil {
+			return nil, fmt.Errorf("jira load issues failed: %w", err)
+		}
+
+		for i := range page.Issues {
+			issues = append(issues, c.toIssue(&page.Issues[i]))
+		}
+		startAt += len(page.Issues)
+		if len(page.Issues) == 0 || startAt >= page.Total {
+			break
+		}
+	}
+	return truncateIssues(issues), nil
+}
+
+// filterIssues pages through the results of a saved filter
+func (c *Client) filterIssues(ctx context.Context, filterID int, skipEstimated bool) ([]Issue, error) {
+	jql := "filter = " + strconv.Itoa(filterID)
+	if skipEstimated {
+		jql += " AND " + unestimatedClause(c.config.StoryPointsField)
+	}
+	var issues []Issue
+	pageToken := ""
+	for len(issues) < MaxSourceIssues {
+		page, err := c.searchJQL(ctx, jql, min(c.config.MaxSearchLimit, MaxSourceIssues), pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("jira load issues failed: %w", err)
+		}
+		issues = append(issues, page.Issues...)
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return truncateIssues(issues), nil
+}
+
+func truncateIssues(issues []Issue) []Issue {
+	if len(issues) > MaxSourceIssues {
+		return issues[:MaxSourceIssues]
+	}
+	return issues
+}
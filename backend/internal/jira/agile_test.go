@@ -0,0 +1,74 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAgile serves boards, sprints and a 3-issue sprint in pages of two,
+// recording the query of each request
+func fakeAgile(t *testing.T, queries *[]string) *Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*queries = append(*queries, r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/rest/agile/1.0/board":
+			fmt.Fprint(w, `{"isLast":true,"values":[{"id":3,"name":"WEB board","type":"scrum","location":{"projectKey":"WEB"}}]}`)
+		case "/rest/agile/1.0/board/3/sprint":
+			fmt.Fprint(w, `{"isLast":true,"values":[{"id":7,"name":"Sprint 7","state":"active"}]}`)
+		case "/rest/agile/1.0/sprint/7/issue":
+			startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+			if startAt == 0 {
+				fmt.Fprint(w, `{"total":3,"issues":[{"key":"WEB-1","fields":{"summary":"One"}},{"key":"WEB-2","fields":{"summary":"Two","customfield_10016":5}}]}`)
+			} else {
+				fmt.Fprint(w, `{"total":3,"issues":[{"key":"WEB-3","fields":{"summary":"Three"}}]}`)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Config{BaseURL: server.URL, Email: "bot@example.com", APIToken: "token"})
+	assert.Nil(t, err)
+	return client
+}
+
+func TestClient_Agile(t *testing.T) {
+	var queries []string
+	client := fakeAgile(t, &queries)
+
+	boards, err := client.ListBoards(context.Background(), "WEB")
+	assert.Nil(t, err)
+	assert.Equal(t, []Board{{ID: 3, Name: "WEB board", Type: "scrum", ProjectKey: "WEB"}}, boards)
+	assert.Contains(t, queries[0], "projectKeyOrId=WEB")
+
+	sprints, err := client.ListSprints(context.Background(), 3, "active", "future")
+	assert.Nil(t, err)
+	assert.Equal(t, []Sprint{{ID: 7, Name: "Sprint 7", State: "active"}}, sprints)
+	assert.Contains(t, queries[1], "state=active%2Cfuture")
+
+	// All pages are loaded, unestimated only if asked
+	queries = nil
+	issues, err := client.LoadIssues(context.Background(), IssueSource{Kind: SourceSprint, ID: 7, SkipEstimated: true})
+	assert.Nil(t, err)
+	assert.Len(t, issues, 3)
+	assert.Equal(t, "WEB-1", issues[0].Key)
+	assert.Nil(t, issues[0].Points)
+	assert.Equal(t, 5.0, *issues[1].Points)
+	assert.Equal(t, "WEB-3", issues[2].Key)
+	assert.Len(t, queries, 2)
+	assert.Contains(t, queries[0], "jql=cf%5B10016%5D+is+EMPTY")
+
+	_, err = client.LoadIssues(context.Background(), IssueSource{Kind: "epic", ID: 7})
+	assert.NotNil(t, err)
+	_, err = client.LoadIssues(context.Background(), IssueSource{Kind: SourceBacklog, ID: 404})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,88 @@
+package jira
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned without contacting Jira while the circuit
+// breaker is open, after Jira failed repeatedly
+var ErrUnavailable = errors.New("jira is unavailable")
+
+// breaker is a circuit breaker: after threshold consecutive failures it
+// opens and requests fail fast for the cooldown, then a single trial request
+// decides whether it closes again or stays open for another cooldown
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // Zero while closed
+	trial     bool      // A trial request is in flight
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a request may be sent
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if b.trial || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record counts the outcome of a request that was allowed. Only Jira being
+// down or overloaded counts as a failure: error answers such as 404 show
+// it is up, and requests the caller gave up on say nothing about it.
+func (b *breaker) record(err error) {
+	if errors.Is(err, context.Canceled) {
+		b.release()
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !isOutage(err) {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// release ends a request without counting its outcome
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// isOutage reports whether a request failed because Jira could not serve it:
+// it did not answer or answered with a server error
+func isOutage(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	temporary, _ := IsTemporary(err)
+	return temporary
+}
@@ -0,0 +1,83 @@
+package jira
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCached bounds the entries of each cache; the soonest to expire are
+// dropped first when it is full
+const maxCached = 1000
+
+// ttlCache keeps values for a fixed time after they are stored
+type ttlCache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, now: time.Now, entries: make(map[string]cacheEntry[V])}
+}
+
+// get returns the value stored for key unless it has expired
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// set stores a value for the cache's TTL; nothing is kept when it is not positive
+func (c *ttlCache[V]) set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxCached {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// delete drops the value stored for key
+func (c *ttlCache[V]) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// clear drops every value
+func (c *ttlCache[V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
+
+// evict drops expired entries, or the one expiring soonest if none have
+func (c *ttlCache[V]) evict(now time.Time) {
+	var soonest string
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		} else if soonest == "" || entry.expires.Before(c.entries[soonest].expires) {
+			soonest = key
+		}
+	}
+	if len(c.entries) >= maxCached {
+		delete(c.entries, soonest)
+	}
+}
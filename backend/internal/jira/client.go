@@ -1,25 +1,69 @@
 package jira
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/andygrunwald/go-jira"
 )
 
+// Client defaults used when the config leaves them unset
+const (
+	DefaultSearchLimit      = 20
+	DefaultMaxSearchLimit   = 100
+	DefaultTimeout          = 10 * time.Second
+	DefaultRetries          = 2
+	DefaultRetryDelay       = 500 * time.Millisecond
+	DefaultCacheTTL         = 30 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
 // Config holds Jira connection details
 type Config struct {
 	BaseURL          string
-	Email            string
-	APIToken         string
+	Flavor           Flavor // Cloud or Server/Data Center, detected when empty
+	Email            string // Cloud account email, or Server username; empty to use APIToken as a personal access token
+	APIToken         string // Cloud API token, Server password or personal access token
 	StoryPointsField string
+	// Rich text field holding acceptance criteria, if the site has one
+	AcceptanceCriteriaField string
+	SearchLimit             int // Results per search page unless the caller asks for fewer or more
+	MaxSearchLimit          int // Largest page a caller may ask for
+	// Fields estimates are written to; the story points field unless set
+	EstimateFields []EstimateField
+	HoursPerPoint  float64 // Original estimate per story point, for time tracking fields
+	// Resilience: requests time out, are retried while Jira is rate limiting
+	// or failing, and fail fast once it has failed BreakerThreshold times in
+	// a row, until BreakerCooldown has passed
+	Timeout          time.Duration // Per request, including reading the response
+	Retries          int           // Retries of a failed request; negative for none
+	RetryDelay       time.Duration // Wait before the first retry, doubled for each one after
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// How long issues and search results are reused; negative to not cache
+	CacheTTL time.Duration
 }
 
 // Client handles Jira API interactions
 type Client struct {
-	config    Config
-	jiraClient *jira.Client
+	config        Config
+	jiraClient    *jira.Client
+	flavor        Flavor
+	flavorMu      sync.Mutex
+	flavorRetryAt time.Time // Detection is not retried before then
+	breaker       *breaker
+	issues        *ttlCache[Issue]         // By key
+	searches      *ttlCache[*SearchResult] // By search options
 }
 
 // NewClient creates a new Jira client
@@ -27,21 +71,65 @@ func NewClient(config Config) (*Client, error) {
 	if config.BaseURL == "" {
 		return nil, fmt.Errorf("jira base URL is required")
 	}
-	if config.Email == "" || config.APIToken == "" {
-		return nil, fmt.Errorf("jira email and API token are required")
+	if config.APIToken == "" {
+		return nil, fmt.Errorf("jira API token is required")
+	}
+	if config.Flavor == FlavorCloud && config.Email == "" {
+		return nil, fmt.Errorf("jira email is required for Jira Cloud")
 	}
 
 	if config.StoryPointsField == "" {
 		config.StoryPointsField = "customfield_10016" // Common default
 	}
+	if config.MaxSearchLimit <= 0 {
+		config.MaxSearchLimit = DefaultMaxSearchLimit
+	}
+	if config.SearchLimit <= 0 {
+		config.SearchLimit = DefaultSearchLimit
+	}
+	config.SearchLimit = min(config.SearchLimit, config.MaxSearchLimit)
+	if len(config.EstimateFields) == 0 {
+		config.EstimateFields = []EstimateField{{ID: config.StoryPointsField, Type: FieldNumber}}
+	}
+	if config.HoursPerPoint <= 0 {
+		config.HoursPerPoint = DefaultHoursPerPoint
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Retries == 0 {
+		config.Retries = DefaultRetries
+	}
+	config.Retries = max(config.Retries, 0)
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultRetryDelay
+	}
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if config.BreakerCooldown <= 0 {
+		config.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = DefaultCacheTTL
+	}
 
-	// Create authenticated Jira client
-	tp := jira.BasicAuthTransport{
-		Username: config.Email,
-		Password: config.APIToken,
+	// Create authenticated Jira client: basic auth with an email (Cloud) or
+	// username (Server), otherwise a Server personal access token
+	var httpClient *http.Client
+	if config.Email != "" {
+		tp := jira.BasicAuthTransport{
+			Username: config.Email,
+			Password: config.APIToken,
+		}
+		httpClient = tp.Client()
+	} else {
+		tp := jira.PATAuthTransport{Token: config.APIToken}
+		httpClient = tp.Client()
 	}
+	httpClient.Timeout = config.Timeout
 
-	jiraClient, err := jira.NewClient(tp.Client(), config.BaseURL)
+	jiraClient, err := jira.NewClient(httpClient, config.BaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create jira client: %w", err)
 	}
@@ -49,188 +137,226 @@ func NewClient(config Config) (*Client, error) {
 	return &Client{
 		config:     config,
 		jiraClient: jiraClient,
+		flavor:     config.Flavor,
+		breaker:    newBreaker(config.BreakerThreshold, config.BreakerCooldown),
+		issues:     newTTLCache[Issue](config.CacheTTL),
+		searches:   newTTLCache[*SearchResult](config.CacheTTL),
 	}, nil
 }
 
-// Issue represents a simplified Jira issue
-type Issue struct {
-	Key     string  `json:"key"`
-	Summary string  `json:"summary"`
-	Points  float64 `json:"points,omitempty"`
+// SearchResult is one page of search results
+type SearchResult struct {
+	Issues        []Issue `json:"issues"`
+	NextPageToken string  `json:"nextPageToken,omitempty"` // Empty on the last page
 }
 
 // SearchIssues searches for issues by text or key using the new JQL search API
-func (c *Client) SearchIssues(query string) ([]Issue, error) {
+func (c *Client) SearchIssues(ctx context.Context, query string) ([]Issue, error) {
 	if query == "" {
 		return []Issue{}, nil
 	}
 
+	result, err := c.Search(ctx, SearchOptions{Query: query})
+	if err != nil {
+		return nil, err
+	}
+	return result.Issues, nil
+}
+
+// Search returns a page of issues matching the options. Results are cached
+// for the cache TTL, so searching as the user types stays cheap.
+func (c *Client) Search(ctx context.Context, opts SearchOptions) (*SearchResult, error) {
 	// Optimization: If query looks like an issue key (e.g. PROJ-123), try direct fetch first
-	if isIssueKey(query) {
-		issue, err := c.GetIssue(query)
+	if isIssueKey(opts.Query) && !opts.HasFilters() && opts.PageToken == "" {
+		issue, err := c.GetIssue(ctx, opts.Query)
 		if err == nil && issue != nil {
-			return []Issue{*issue}, nil
+			return &SearchResult{Issues: []Issue{*issue}}, nil
 		}
 		// If direct fetch fails, fall back to search
 	}
 
-	// JQL: summary ~ "query" OR key = "query"
-	// Note: text ~ is fuzzy, key = is exact.
-	jql := fmt.Sprintf("summary ~ \"%s\" OR key = \"%s\" ORDER BY updated DESC", query, query)
+	limit := opts.MaxResults
+	if limit <= 0 {
+		limit = c.config.SearchLimit
+	}
+	limit = min(limit, c.config.MaxSearchLimit)
+
+	jql := BuildJQL(opts, c.config.StoryPointsField)
+	cacheKey := searchCacheKey(jql, limit, opts.PageToken)
+	if result, ok := c.searches.get(cacheKey); ok {
+		return copyResult(result), nil
+	}
 
-	// Use the new /rest/api/3/search/jql endpoint to avoid 410 deprecation errors
-	result, err := c.searchJQL(jql, 10)
+	result, err := c.searchJQL(ctx, jql, limit, opts.PageToken)
 	if err != nil {
 		return nil, fmt.Errorf("jira search failed: %w", err)
 	}
 
+	c.searches.set(cacheKey, copyResult(result))
 	return result, nil
 }
 
-// searchJQL performs a JQL search using the new /rest/api/3/search/jql endpoint
-func (c *Client) searchJQL(jql string, maxResults int) ([]Issue, error) {
+// searchCacheKey identifies a page of search results
+func searchCacheKey(jql string, limit int, pageToken string) string {
+	key, _ := json.Marshal([]interface{}{jql, limit, pageToken})
+	return string(key)
+}
+
+// copyResult copies a page of results, so cached pages are not changed by callers
+func copyResult(result *SearchResult) *SearchResult {
+	c := *result
+	c.Issues = slices.Clone(result.Issues)
+	return &c
+}
+
+// Invalidate drops what is cached about an issue, when it changed in Jira.
+// Cached searches are dropped too, as the change may affect what they match.
+func (c *Client) Invalidate(key string) {
+	c.issues.delete(key)
+	c.searches.clear()
+}
+
+// searchJQL performs a JQL search with the site's search API
+func (c *Client) searchJQL(ctx context.Context, jql string, maxResults int, pageToken string) (*SearchResult, error) {
+	if c.Flavor(ctx) == FlavorServer {
+		return c.searchOffset(ctx, jql, maxResults, pageToken)
+	}
+	return c.searchToken(ctx, jql, maxResults, pageToken)
+}
+
+// searchToken searches with Cloud's /rest/api/3/search/jql endpoint, which
+// pages with opaque tokens
+func (c *Client) searchToken(ctx context.Context, jql string, maxResults int, pageToken string) (*SearchResult, error) {
 	// Build the search payload for the new API
 	payload := map[string]interface{}{
 		"jql":        jql,
 		"maxResults": maxResults,
-		"fields":     []string{"summary", c.config.StoryPointsField},
+		"fields":     c.listFields(),
 	}
-
-	// Use the new /rest/api/3/search/jql endpoint (replaces deprecated /rest/api/3/search)
-	req, err := c.jiraClient.NewRequest("POST", "rest/api/3/search/jql", payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create search request: %w", err)
+	if pageToken != "" {
+		payload["nextPageToken"] = pageToken
 	}
 
-	// Response structure for the new API
+	// Use the new /rest/api/3/search/jql endpoint (replaces deprecated /rest/api/3/search)
 	var searchResponse struct {
-		Issues []jira.Issue `json:"values"`
+		Issues        []apiIssue `json:"issues"`
+		NextPageToken string     `json:"nextPageToken"`
 	}
-
-	resp, err := c.jiraClient.Do(req, &searchResponse)
-	if err != nil {
+	if err := c.search(ctx, "rest/api/3/search/jql", payload, &searchResponse); err != nil {
 		return nil, fmt.Errorf("search request failed: %w", err)
 	}
-	if resp != nil {
-		defer resp.Body.Close()
-	}
 
-	// Convert Jira issues to our Issue type
-	result := make([]Issue, 0, len(searchResponse.Issues))
-	for _, jiraIssue := range searchResponse.Issues {
-		issue := Issue{
-			Key:     jiraIssue.Key,
-			Summary: jiraIssue.Fields.Summary,
-		}
+	return c.searchResult(searchResponse.Issues, searchResponse.NextPageToken), nil
+}
 
-		// Extract story points from custom field
-		if points := extractStoryPoints(jiraIssue.Fields, c.config.StoryPointsField); points != nil {
-			issue.Points = *points
+// searchOffset searches with Server's /rest/api/2/search endpoint, which
+// pages by offset; the offset of the next page serves as its token
+func (c *Client) searchOffset(ctx context.Context, jql string, maxResults int, pageToken string) (*SearchResult, error) {
+	startAt := 0
+	if pageToken != "" {
+		var err error
+		if startAt, err = strconv.Atoi(pageToken); err != nil || startAt < 0 {
+			return nil, fmt.Errorf("invalid page token %q", pageToken)
 		}
-
-		result = append(result, issue)
 	}
 
-	return result, nil
-}
-
-// GetIssue fetches a single issue by key
-func (c *Client) GetIssue(key string) (*Issue, error) {
-	if key == "" {
-		return nil, fmt.Errorf("issue key is required")
+	payload := map[string]interface{}{
+		"jql":        jql,
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"fields":     c.listFields(),
 	}
-
-	opts := &jira.GetQueryOptions{
-		Fields: "summary," + c.config.StoryPointsField,
+	var searchResponse struct {
+		Total  int        `json:"total"`
+		Issues []apiIssue `json:"issues"`
 	}
-
-	jiraIssue, _, err := c.jiraClient.Issue.Get(key, opts)
-	if err != nil {
-		// Check if it's a 404 error
-		if strings.Contains(err.Error(), "404") {
-			return nil, fmt.Errorf("issue not found: %s", key)
-		}
-		return nil, fmt.Errorf("jira get issue failed: %w", err)
+	if err := c.search(ctx, "rest/api/2/search", payload, &searchResponse); err != nil {
+		return nil, fmt.Errorf("search request failed: %w", err)
 	}
 
-	if jiraIssue == nil {
-		return nil, fmt.Errorf("issue not found: %s", key)
+	next := ""
+	if end := startAt + len(searchResponse.Issues); len(searchResponse.Issues) > 0 && end < searchResponse.Total {
+		next = strconv.Itoa(end)
 	}
+	return c.searchResult(searchResponse.Issues, next), nil
+}
 
-	issue := &Issue{
-		Key:     jiraIssue.Key,
-		Summary: jiraIssue.Fields.Summary,
+// searchResult converts a page of issues as returned by Jira
+func (c *Client) searchResult(issues []apiIssue, nextPageToken string) *SearchResult {
+	result := &SearchResult{
+		Issues:        make([]Issue, 0, len(issues)),
+		NextPageToken: nextPageToken,
 	}
-
-	// Extract story points from custom field
-	if points := extractStoryPoints(jiraIssue.Fields, c.config.StoryPointsField); points != nil {
-		issue.Points = *points
+	for i := range issues {
+		result.Issues = append(result.Issues, c.toIssue(&issues[i]))
 	}
-
-	return issue, nil
+	return result
 }
 
-// UpdateStoryPoints updates the story points for an issue
-func (c *Client) UpdateStoryPoints(issueKey string, points float64) error {
-	if issueKey == "" {
-		return fmt.Errorf("issue key is required")
+// post sends a JSON request and decodes the response into v
+func (c *Client) post(ctx context.Context, path string, payload, v interface{}) error {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "POST", path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	return c.do(req, v)
+}
 
-	if points < 0 {
-		return fmt.Errorf("story points must be non-negative")
+// search posts a search, which changes nothing and so is retried like a GET
+func (c *Client) search(ctx context.Context, path string, payload, v interface{}) error {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "POST", path, payload)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
 	}
+	return c.send(req, v, true)
+}
 
-	// Build update payload
-	payload := map[string]interface{}{
-		"fields": map[string]interface{}{
-			c.config.StoryPointsField: points,
-		},
+// GetIssue fetches a single issue by key with its details, or returns it
+// from the cache
+func (c *Client) GetIssue(ctx context.Context, key string) (*Issue, error) {
+	if key == "" {
+		return nil, fmt.Errorf("issue key is required")
+	}
+	if issue, ok := c.issues.get(key); ok {
+		return &issue, nil
 	}
 
-	_, err := c.jiraClient.Issue.UpdateIssue(issueKey, payload)
+	query := url.Values{"fields": {strings.Join(c.detailFields(), ",")}}
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", c.apiPath(ctx, "issue/"+url.PathEscape(key))+"?"+query.Encode(), nil)
 	if err != nil {
-		return fmt.Errorf("jira update failed: %w", err)
+		return nil, fmt.Errorf("failed to create issue request: %w", err)
 	}
 
-	return nil
+	var raw apiIssue
+	if err := c.do(req, &raw); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("issue not found: %s", key)
+		}
+		return nil, fmt.Errorf("jira get issue failed: %w", err)
+	}
+
+	issue := c.toIssue(&raw)
+	c.issues.set(key, issue)
+	return &issue, nil
+}
+
+// UpdateStoryPoints writes a number of story points to the estimate fields
+func (c *Client) UpdateStoryPoints(ctx context.Context, issueKey string, points float64) error {
+	return c.UpdateEstimate(ctx, issueKey, PointsEstimate(points))
 }
 
 // ValidateConnection tests the Jira connection by attempting a simple API call
-func (c *Client) ValidateConnection() error {
-	// Try a simple JQL search using the new API to validate the connection
+func (c *Client) ValidateConnection(ctx context.Context) error {
+	// Try a simple JQL search to validate the connection
 	// Search for any issue with maxResults=1 to minimize API load
-	_, err := c.searchJQL("order by created DESC", 1)
+	_, err := c.searchJQL(ctx, "order by created DESC", 1, "")
 	if err != nil {
 		return fmt.Errorf("jira connection validation failed: %w", err)
 	}
 	return nil
 }
 
-// extractStoryPoints extracts story points from a Jira issue's fields
-func extractStoryPoints(fields *jira.IssueFields, fieldName string) *float64 {
-	if fields == nil || fields.Unknowns == nil {
-		return nil
-	}
-
-	// Get the custom field value from unknowns
-	if value, ok := fields.Unknowns[fieldName]; ok {
-		switch v := value.(type) {
-		case float64:
-			return &v
-		case int:
-			f := float64(v)
-			return &f
-		case string:
-			// Try to parse string to float
-			if f, err := strconv.ParseFloat(v, 64); err == nil {
-				return &f
-			}
-		}
-	}
-
-	return nil
-}
-
 // isIssueKey validates if a string looks like a Jira issue key (e.g., PROJ-123)
 func isIssueKey(query string) bool {
 	// Simple validation: Uppercase letters, hyphen, digits
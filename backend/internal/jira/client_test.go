@@ -0,0 +1,296 @@
+package jira
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Flavor(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+
+		_, err := client.GetIssue(context.Background(), "WEB-1")
+		assert.Nil(t, err)
+		auth := f.received()[0].Auth
+		if f.cloud() {
+			assert.Equal(t, FlavorCloud, client.Flavor(context.Background()))
+			assert.True(t, len(auth) > 6 && auth[:6] == "Basic ", auth)
+		} else {
+			assert.Equal(t, FlavorServer, client.Flavor(context.Background()))
+			assert.Equal(t, "Bearer secret", auth)
+		}
+	})
+
+	// A configured flavor is not detected
+	f := newFakeJira(t, "DataCenter")
+	client, err := NewClient(Config{BaseURL: f.URL, APIToken: "secret", Flavor: FlavorServer})
+	assert.Nil(t, err)
+	assert.Equal(t, FlavorServer, client.Flavor(context.Background()))
+	assert.Empty(t, f.requests)
+
+	_, err = NewClient(Config{BaseURL: f.URL, APIToken: "secret", Flavor: FlavorCloud})
+	assert.NotNil(t, err, "cloud needs an email")
+	_, err = NewClient(Config{BaseURL: f.URL, Email: "bot@example.com"})
+	assert.NotNil(t, err, "a token is always needed")
+}
+
+func TestClient_FlavorDetectionFailure(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	// The flavor is guessed from the credentials and not asked again right away
+	client, err := NewClient(Config{BaseURL: server.URL, Email: "bot@example.com", APIToken: "secret", Retries: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, FlavorCloud, client.Flavor(context.Background()))
+	assert.Equal(t, FlavorCloud, client.Flavor(context.Background()))
+	assert.Equal(t, 1, requests)
+
+	client, err = NewClient(Config{BaseURL: server.URL, APIToken: "secret", Retries: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, FlavorServer, client.Flavor(context.Background()))
+}
+
+func TestParseFlavor(t *testing.T) {
+	for value, want := range map[string]Flavor{"": FlavorAuto, "auto": FlavorAuto, "Cloud": FlavorCloud, "server": FlavorServer, "datacenter": FlavorServer, "dc": FlavorServer} {
+		got, err := ParseFlavor(value)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, value)
+	}
+	_, err := ParseFlavor("onprem")
+	assert.NotNil(t, err)
+}
+
+func TestClient_SearchPages(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		for i := 1; i <= 3; i++ {
+			f.addIssue("WEB-"+string(rune('0'+i)), map[string]interface{}{"summary": "Issue", "customfield_10016": 3})
+		}
+
+		page, err := client.Search(context.Background(), SearchOptions{Project: "WEB", MaxResults: 2})
+		assert.Nil(t, err)
+		assert.Len(t, page.Issues, 2)
+		assert.Equal(t, "WEB-1", page.Issues[0].Key)
+		assert.Equal(t, 3.0, *page.Issues[0].Points)
+		assert.NotEmpty(t, page.NextPageToken)
+		assert.Equal(t, `project = "WEB" ORDER BY updated DESC`, f.received()[0].Body["jql"])
+
+		page, err = client.Search(context.Background(), SearchOptions{Project: "WEB", MaxResults: 2, PageToken: page.NextPageToken})
+		assert.Nil(t, err)
+		assert.Len(t, page.Issues, 1)
+		assert.Equal(t, "WEB-3", page.Issues[0].Key)
+		assert.Empty(t, page.NextPageToken)
+
+		// Page sizes default to the configured limit and are capped
+		client.Search(context.Background(), SearchOptions{Project: "WEB"})
+		client.Search(context.Background(), SearchOptions{Project: "WEB", MaxResults: 500})
+		requests := f.received()
+		assert.Equal(t, float64(DefaultSearchLimit), requests[2].Body["maxResults"])
+		assert.Equal(t, float64(50), requests[3].Body["maxResults"])
+	})
+}
+
+func TestClient_GetIssue(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		client.config.AcceptanceCriteriaField = "customfield_10050"
+
+		// Cloud returns rich text as documents, Data Center as wiki markup
+		var description interface{} = map[string]interface{}{"type": "doc", "content": []interface{}{
+			map[string]interface{}{"type": "paragraph", "content": []interface{}{map[string]interface{}{"type": "text", "text": "Pay <now>"}}},
+		}}
+		if !f.cloud() {
+			description = "Pay <now>"
+		}
+		f.addIssue("WEB-7", map[string]interface{}{
+			"summary":           "Checkout",
+			"issuetype":         map[string]interface{}{"name": "Story"},
+			"priority":          map[string]interface{}{"name": "High"},
+			"status":            map[string]interface{}{"name": "In Progress"},
+			"labels":            []string{"payments"},
+			"customfield_10016": "5",
+			"description":       description,
+			"customfield_10050": "Given a cart",
+		})
+
+		issue, err := client.GetIssue(context.Background(), "WEB-7")
+		assert.Nil(t, err)
+		points := 5.0
+		assert.Equal(t, &Issue{
+			Key:                "WEB-7",
+			Summary:            "Checkout",
+			Type:               "Story",
+			Priority:           "High",
+			Status:             "In Progress",
+			Labels:             []string{"payments"},
+			Points:             &points,
+			Description:        "<p>Pay &lt;now&gt;</p>",
+			AcceptanceCriteria: "<p>Given a cart</p>",
+			URL:                f.URL + "/browse/WEB-7",
+		}, issue)
+		fields := f.received()[0].Query.Get("fields")
+		assert.Contains(t, fields, "description")
+		assert.Contains(t, fields, "customfield_10050")
+
+		_, err = client.GetIssue(context.Background(), "WEB-404")
+		assert.EqualError(t, err, "issue not found: WEB-404")
+	})
+}
+
+func TestClient_UpdateStoryPoints(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+
+		assert.Nil(t, client.UpdateStoryPoints(context.Background(), "WEB-1", 8))
+		issue, err := client.GetIssue(context.Background(), "WEB-1")
+		assert.Nil(t, err)
+		assert.Equal(t, 8.0, *issue.Points)
+
+		assert.NotNil(t, client.UpdateStoryPoints(context.Background(), "WEB-404", 8))
+		assert.NotNil(t, client.UpdateStoryPoints(context.Background(), "WEB-1", -1))
+	})
+}
+
+func TestClient_Cache(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		ctx := context.Background()
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+
+		// Lookups and searches are reused, and callers get their own copies
+		first, _ := client.GetIssue(ctx, "WEB-1")
+		first.Summary = "Changed"
+		issue, err := client.GetIssue(ctx, "WEB-1")
+		assert.Nil(t, err)
+		assert.Equal(t, "First", issue.Summary)
+		page, _ := client.Search(ctx, SearchOptions{Query: "first"})
+		page.Issues[0].Summary = "Changed"
+		page, err = client.Search(ctx, SearchOptions{Query: "first"})
+		assert.Nil(t, err)
+		assert.Equal(t, "First", page.Issues[0].Summary)
+		assert.Len(t, f.received(), 2)
+
+		// Writing to an issue drops what was cached about it
+		assert.Nil(t, client.UpdateStoryPoints(ctx, "WEB-1", 3))
+		issue, _ = client.GetIssue(ctx, "WEB-1")
+		assert.Equal(t, 3.0, *issue.Points)
+		client.Search(ctx, SearchOptions{Query: "first"})
+		assert.Len(t, f.received(), 5)
+
+		// Entries expire
+		now := time.Now().Add(time.Hour)
+		client.issues.now = func() time.Time { return now }
+		client.GetIssue(ctx, "WEB-1")
+		assert.Len(t, f.received(), 6)
+	})
+
+	f := newFakeJira(t, "Cloud")
+	f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+	client, _ := NewClient(Config{BaseURL: f.URL, Email: "bot@example.com", APIToken: "secret", CacheTTL: -1})
+	client.GetIssue(context.Background(), "WEB-1")
+	client.GetIssue(context.Background(), "WEB-1")
+	assert.Len(t, f.received(), 2, "negative TTL disables caching")
+}
+
+func TestClient_Retries(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		ctx := context.Background()
+		client.config.RetryDelay = time.Millisecond
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+
+		// Reads are retried while Jira fails, up to the configured retries
+		f.fail(2, http.StatusServiceUnavailable, "")
+		_, err := client.GetIssue(ctx, "WEB-1")
+		assert.Nil(t, err)
+		assert.Len(t, f.received(), 3)
+		f.fail(3, http.StatusBadGateway, "")
+		_, err = client.Search(ctx, SearchOptions{Project: "WEB"})
+		assert.NotNil(t, err)
+		assert.Len(t, f.received(), 6)
+
+		// So is anything rate limited, unless Jira asks to wait too long
+		f.fail(1, http.StatusTooManyRequests, "")
+		assert.Nil(t, client.AddComment(ctx, "WEB-1", "Hello"))
+		assert.Len(t, f.comments["WEB-1"], 1)
+		f.fail(1, http.StatusTooManyRequests, "60")
+		err = client.AddComment(ctx, "WEB-1", "Hello")
+		temporary, wait := IsTemporary(err)
+		assert.True(t, temporary)
+		assert.Equal(t, time.Minute, wait)
+
+		// Comments are not sent again after a server error, which may have saved them
+		f.fail(1, http.StatusInternalServerError, "")
+		assert.NotNil(t, client.AddComment(ctx, "WEB-1", "Hello"))
+		assert.Len(t, f.comments["WEB-1"], 1)
+		requests := len(f.received())
+		assert.NotNil(t, client.AddComment(ctx, "WEB-404", "Hello"))
+		assert.Len(t, f.received(), requests+1, "errors other than outages are not retried")
+	})
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	f := newFakeJira(t, "Cloud")
+	f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+	client, _ := NewClient(Config{BaseURL: f.URL, Email: "bot@example.com", APIToken: "secret", Retries: -1, BreakerThreshold: 2, BreakerCooldown: time.Minute, CacheTTL: -1})
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Errors other than outages do not count
+	_, err := client.GetIssue(ctx, "WEB-404")
+	assert.NotNil(t, err)
+	f.fail(2, http.StatusServiceUnavailable, "")
+	client.GetIssue(ctx, "WEB-1")
+	client.GetIssue(ctx, "WEB-1")
+	assert.Len(t, f.received(), 3)
+
+	// Open: requests fail without reaching Jira
+	_, err = client.GetIssue(ctx, "WEB-1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	temporary, _ := IsTemporary(err)
+	assert.True(t, temporary)
+	assert.Len(t, f.received(), 3)
+
+	// After the cooldown a failed trial opens it again, a successful one closes it
+	now = now.Add(time.Minute)
+	f.fail(1, http.StatusServiceUnavailable, "")
+	client.GetIssue(ctx, "WEB-1")
+	_, err = client.GetIssue(ctx, "WEB-1")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	now = now.Add(time.Minute)
+	_, err = client.GetIssue(ctx, "WEB-1")
+	assert.Nil(t, err)
+	_, err = client.GetIssue(ctx, "WEB-1")
+	assert.Nil(t, err)
+	assert.Len(t, f.received(), 6)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, _ := NewClient(Config{BaseURL: server.URL, Flavor: FlavorCloud, Email: "bot@example.com", APIToken: "secret", Timeout: 50 * time.Millisecond, Retries: -1})
+	start := time.Now()
+	_, err := client.GetIssue(context.Background(), "WEB-1")
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The caller's context ends requests too
+	client, _ = NewClient(Config{BaseURL: server.URL, Flavor: FlavorCloud, Email: "bot@example.com", APIToken: "secret", Timeout: time.Minute})
+	start = time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.GetIssue(ctx, "WEB-1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "not retried once the context is done")
+}
@@ -0,0 +1,223 @@
+package jira
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Permissions the server needs on the issues it estimates
+const (
+	PermissionBrowse     = "BROWSE_PROJECTS"
+	PermissionEdit       = "EDIT_ISSUES"
+	PermissionComment    = "ADD_COMMENTS"
+	PermissionTransition = "TRANSITION_ISSUES"
+)
+
+// storyPointsType is the custom field type of the "Story Points" field of
+// company-managed projects, a plain number field
+const storyPointsType = "com.atlassian.jira.plugin.system.customfieldtypes:float"
+
+// jswStoryPointsType is the custom field type of the story points field of
+// team-managed Cloud projects
+const jswStoryPointsType = "com.pyxis.greenhopper.jira:jsw-story-points"
+
+// ErrNoPointsField is returned when no field looks like a story points field
+var ErrNoPointsField = errors.New("no story points field found")
+
+// User is the account the client authenticates as
+type User struct {
+	AccountID    string `json:"accountId,omitempty"` // Cloud
+	Name         string `json:"name,omitempty"`      // Server username
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+}
+
+// Field is a Jira issue field
+type Field struct {
+	ID     string      `json:"id"`
+	Name   string      `json:"name"`
+	Custom bool        `json:"custom"`
+	Schema FieldSchema `json:"schema"`
+}
+
+// FieldSchema describes the values of a field
+type FieldSchema struct {
+	Type   string `json:"type"`             // e.g. "number", "string", "option"
+	Custom string `json:"custom,omitempty"` // Custom field type
+	System string `json:"system,omitempty"` // System field name
+}
+
+// Myself returns the account the client authenticates as, which validates
+// the credentials
+func (c *Client) Myself(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.get(ctx, c.apiPath(ctx, "myself"), &user); err != nil {
+		return nil, fmt.Errorf("jira myself failed: %w", err)
+	}
+	return &user, nil
+}
+
+// Fields returns the fields of the site
+func (c *Client) Fields(ctx context.Context) ([]Field, error) {
+	var fields []Field
+	if err := c.get(ctx, c.apiPath(ctx, "field"), &fields); err != nil {
+		return nil, fmt.Errorf("jira fields failed: %w", err)
+	}
+	return fields, nil
+}
+
+// EditMeta returns the fields the client may edit on an issue, ordered by ID
+func (c *Client) EditMeta(ctx context.Context, issueKey string) ([]Field, error) {
+	var meta struct {
+		Fields map[string]Field `json:"fields"`
+	}
+	if err := c.get(ctx, c.apiPath(ctx, "issue/"+url.PathEscape(issueKey)+"/editmeta"), &meta); err != nil {
+		return nil, fmt.Errorf("jira edit metadata failed: %w", err)
+	}
+
+	fields := make([]Field, 0, len(meta.Fields))
+	for id, field := range meta.Fields {
+		// Server leaves the ID out of the field itself
+		field.ID = id
+		field.Custom = strings.HasPrefix(id, "customfield_")
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	return fields, nil
+}
+
+// Permissions reports which of the permissions the client has on an issue.
+// Nothing is changed, so it tells in advance whether writes would succeed.
+func (c *Client) Permissions(ctx context.Context, issueKey string, permissions ...string) (map[string]bool, error) {
+	query := url.Values{"issueKey": {issueKey}, "permissions": {strings.Join(permissions, ",")}}
+	var response struct {
+		Permissions map[string]struct {
+			HavePermission bool `json:"havePermission"`
+		} `json:"permissions"`
+	}
+	if err := c.get(ctx, c.apiPath(ctx, "mypermissions")+"?"+query.Encode(), &response); err != nil {
+		return nil, fmt.Errorf("jira permissions failed: %w", err)
+	}
+
+	granted := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		granted[permission] = response.Permissions[permission].HavePermission
+	}
+	return granted, nil
+}
+
+// SearchAPI returns the search endpoint used for the site's flavor
+func (c *Client) SearchAPI(ctx context.Context) string {
+	if c.Flavor(ctx) == FlavorServer {
+		return "POST /rest/api/2/search"
+	}
+	return "POST /rest/api/3/search/jql"
+}
+
+// SampleIssue returns the most recently updated issue of a project, or of
+// the site when project is empty; nil when there are none
+func (c *Client) SampleIssue(ctx context.Context, project string) (*Issue, error) {
+	jql := "ORDER BY updated DESC"
+	if project != "" {
+		jql = "project = " + QuoteJQL(project) + " " + jql
+	}
+	result, err := c.searchJQL(ctx, jql, 1, "")
+	if err != nil {
+		return nil, fmt.Errorf("jira sample issue failed: %w", err)
+	}
+	if len(result.Issues) == 0 {
+		return nil, nil
+	}
+	return &result.Issues[0], nil
+}
+
+// DiscoverPointsField finds the story points field of a project, or of the
+// site when project is empty. The fields editable on the project's latest
+// issue are looked at first, since projects can use different fields; the
+// site's fields are the fallback when there is no issue to look at or the
+// issue has no points field, as sub-tasks and epics often do not.
+func (c *Client) DiscoverPointsField(ctx context.Context, project string) (*Field, error) {
+	issue, err := c.SampleIssue(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	if issue != nil {
+		editable, err := c.EditMeta(ctx, issue.Key)
+		if err != nil {
+			return nil, err
+		}
+		if field, ok := PointsField(editable); ok {
+			return &field, nil
+		}
+	}
+
+	fields, err := c.Fields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	field, ok := PointsField(fields)
+	if !ok {
+		return nil, ErrNoPointsField
+	}
+	return &field, nil
+}
+
+// PointsField picks the story points field among fields: Jira Software's
+// story points fields first, then number fields named like one
+func PointsField(fields []Field) (Field, bool) {
+	best, bestScore := Field{}, 0
+	for _, field := range fields {
+		if score := pointsFieldScore(field); score > bestScore {
+			best, bestScore = field, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+func pointsFieldScore(field Field) int {
+	name := strings.ToLower(field.Name)
+	switch {
+	case field.Schema.Custom == jswStoryPointsType:
+		return 4
+	case name == "story points" || name == "story point estimate":
+		if field.Schema.Custom == storyPointsType {
+			return 3
+		}
+		return 2
+	case field.Schema.Type == "number" && strings.Contains(name, "point"):
+		return 1
+	}
+	return 0
+}
+
+// UsePointsField switches the story points field, e.g. to a discovered one.
+// Estimates follow it unless estimate fields were configured. Call it before
+// the client is shared.
+func (c *Client) UsePointsField(id string) {
+	defaulted := len(c.config.EstimateFields) == 1 &&
+		c.config.EstimateFields[0] == EstimateField{ID: c.config.StoryPointsField, Type: FieldNumber}
+	c.config.StoryPointsField = id
+	if defaulted {
+		c.config.EstimateFields = []EstimateField{{ID: id, Type: FieldNumber}}
+	}
+	c.issues.clear()
+	c.searches.clear()
+}
+
+// PointsFieldID returns the story points field in use
+func (c *Client) PointsFieldID() string {
+	return c.config.StoryPointsField
+}
+
+// get fetches a resource into v
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.do(req, v)
+}
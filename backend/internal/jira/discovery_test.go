@@ -0,0 +1,92 @@
+package jira
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Myself(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		user, err := client.Myself(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "Estimation Bot", user.DisplayName)
+		if f.cloud() {
+			assert.NotEmpty(t, user.AccountID)
+			assert.Equal(t, "POST /rest/api/3/search/jql", client.SearchAPI(context.Background()))
+		} else {
+			assert.Equal(t, "bot", user.Name)
+			assert.Equal(t, "POST /rest/api/2/search", client.SearchAPI(context.Background()))
+		}
+	})
+}
+
+func TestClient_DiscoverPointsField(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		ctx := context.Background()
+
+		// Without issues, the site's fields are looked at
+		field, err := client.DiscoverPointsField(ctx, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "customfield_10016", field.ID)
+		assert.Equal(t, "Story point estimate", field.Name)
+
+		// Otherwise those editable on the project's latest issue
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+		f.editable = []string{"summary", "customfield_10026"}
+		field, err = client.DiscoverPointsField(ctx, "WEB")
+		assert.Nil(t, err)
+		assert.Equal(t, "customfield_10026", field.ID)
+		assert.Equal(t, `project = "WEB" ORDER BY updated DESC`, f.received()[len(f.received())-2].Body["jql"])
+
+		// And the site's again when that issue has none
+		f.editable = []string{"summary"}
+		field, err = client.DiscoverPointsField(ctx, "WEB")
+		assert.Nil(t, err)
+		assert.Equal(t, "customfield_10016", field.ID)
+	})
+}
+
+func TestClient_Permissions(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.denied = []string{PermissionTransition}
+		granted, err := client.Permissions(context.Background(), "WEB-1", PermissionBrowse, PermissionEdit, PermissionTransition)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]bool{PermissionBrowse: true, PermissionEdit: true, PermissionTransition: false}, granted)
+		assert.Equal(t, "WEB-1", f.received()[0].Query.Get("issueKey"))
+	})
+}
+
+func TestPointsField(t *testing.T) {
+	number := FieldSchema{Type: "number"}
+	_, ok := PointsField([]Field{{ID: "summary", Name: "Summary"}, {ID: "customfield_1", Name: "Points", Schema: FieldSchema{Type: "string"}}})
+	assert.False(t, ok)
+
+	field, _ := PointsField([]Field{{ID: "customfield_1", Name: "Risk points", Schema: number}})
+	assert.Equal(t, "customfield_1", field.ID)
+	field, _ = PointsField([]Field{
+		{ID: "customfield_1", Name: "Risk points", Schema: number},
+		{ID: "customfield_2", Name: "Story Points", Schema: number},
+	})
+	assert.Equal(t, "customfield_2", field.ID)
+}
+
+func TestClient_UsePointsField(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First", "customfield_10026": 5})
+		client.UsePointsField("customfield_10026")
+		assert.Equal(t, "customfield_10026", client.PointsFieldID())
+
+		issue, _ := client.GetIssue(context.Background(), "WEB-1")
+		assert.Equal(t, 5.0, *issue.Points)
+		assert.Nil(t, client.UpdateStoryPoints(context.Background(), "WEB-1", 8))
+		assert.Equal(t, map[string]interface{}{"customfield_10026": 8.0}, f.received()[1].Body["fields"])
+	})
+
+	// Configured estimate fields are kept
+	f := newFakeJira(t, "Cloud")
+	client, _ := NewClient(Config{BaseURL: f.URL, Email: "bot@example.com", APIToken: "secret", EstimateFields: []EstimateField{{ID: "timetracking", Type: FieldTimeTracking}}})
+	client.UsePointsField("customfield_10026")
+	assert.Equal(t, []EstimateField{{ID: "timetracking", Type: FieldTimeTracking}}, client.config.EstimateFields)
+}
@@ -0,0 +1,146 @@
+package jira
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// APIError is a request Jira answered with an error status
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, zero if absent
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("jira returned %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the request may succeed if sent again: Jira
+// was rate limiting or unavailable
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IsTemporary reports whether a failed request may succeed later, and how
+// long Jira asked to wait first. Requests that never got an answer, such as
+// on timeouts or refused connections, and requests held back by the circuit
+// breaker count as temporary.
+func IsTemporary(err error) (bool, time.Duration) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary(), apiErr.RetryAfter
+	}
+	if errors.Is(err, ErrUnavailable) {
+		return true, 0
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr), 0
+}
+
+// maxRetryWait is the longest the client waits to retry a request itself;
+// when Jira asks for longer the error is returned to the caller
+const maxRetryWait = 5 * time.Second
+
+// do sends a request and decodes the response into v, returning an
+// *APIError when Jira answers with an error status. Rate limited requests
+// are retried, and so are GET and PUT requests Jira failed to serve, as
+// sending them again does no harm.
+func (c *Client) do(req *http.Request, v interface{}) error {
+	return c.send(req, v, req.Method == http.MethodGet || req.Method == http.MethodPut)
+}
+
+// send sends a request, retrying it with backoff while Jira is rate
+// limiting or, when idempotent, failing to serve it
+func (c *Client) send(req *http.Request, v interface{}, idempotent bool) error {
+	for attempt := 1; ; attempt++ {
+		err := c.sendOnce(req, v)
+		if err == nil || attempt > c.config.Retries {
+			return err
+		}
+		wait, retry := c.retryWait(req, err, attempt, idempotent)
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		// The body was read by the last attempt
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// sendOnce sends a request unless the circuit breaker is open
+func (c *Client) sendOnce(req *http.Request, v interface{}) error {
+	if !c.breaker.allow() {
+		return ErrUnavailable
+	}
+	resp, err := c.jiraClient.Do(req, v)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil && resp != nil && resp.StatusCode >= 400 {
+		err = &APIError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")), Err: err}
+	}
+	c.breaker.record(err)
+	return err
+}
+
+// retryWait returns how long to wait before sending a failed request again,
+// and whether to send it again at all
+func (c *Client) retryWait(req *http.Request, err error, attempt int, idempotent bool) (time.Duration, bool) {
+	if req.Context().Err() != nil || errors.Is(err, ErrUnavailable) {
+		return 0, false
+	}
+	wait := c.config.RetryDelay << (attempt - 1)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			wait = max(wait, apiErr.RetryAfter)
+		case apiErr.StatusCode < 500 || !idempotent:
+			return 0, false
+		}
+	} else if temporary, _ := IsTemporary(err); !temporary || !idempotent {
+		return 0, false
+	}
+	return wait, wait <= maxRetryWait
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FieldType is the kind of Jira field an estimate is written to, which
+// decides how a vote is turned into the field's value
+type FieldType string
+
+const (
+	FieldNumber       FieldType = "number"       // Story points or another number field, set to the points
+	FieldTimeTracking FieldType = "timetracking" // Original estimate, set to the points times HoursPerPoint
+	FieldSelect       FieldType = "select"       // Select list with an option named after each scale value
+	FieldText         FieldType = "text"         // Text field, set to the vote as shown on the card
+)
+
+// DefaultHoursPerPoint converts points to an original estimate unless configured
+const DefaultHoursPerPoint = 4
+
+// EstimateField is a Jira field estimates are written to
+type EstimateField struct {
+	ID   string    // Field ID, e.g. customfield_10016 or timetracking
+	Type FieldType // How the estimate is written
+}
+
+// ParseEstimateFields parses a JIRA_ESTIMATE_FIELDS value: comma-separated
+// fields, each an ID with an optional ":type" (number unless given, or
+// timetracking for the timetracking field)
+func ParseEstimateFields(value string) ([]EstimateField, error) {
+	var fields []EstimateField
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, fieldType, _ := strings.Cut(entry, ":")
+		field := EstimateField{ID: strings.TrimSpace(id), Type: FieldType(strings.ToLower(strings.TrimSpace(fieldType)))}
+		if field.Type == "" {
+			field.Type = defaultFieldType(field.ID)
+		}
+		switch field.Type {
+		case FieldNumber, FieldTimeTracking, FieldSelect, FieldText:
+		default:
+			return nil, fmt.Errorf("unknown type %q for estimate field %s (expected number, timetracking, select or text)", field.Type, field.ID)
+		}
+		if field.ID == "" {
+			return nil, fmt.Errorf("estimate field %q has no ID", entry)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func defaultFieldType(id string) FieldType {
+	if id == "timetracking" {
+		return FieldTimeTracking
+	}
+	return FieldNumber
+}
+
+// Estimate is an agreed vote to write to Jira
+type Estimate struct {
+	Value  string   `json:"value"`            // The vote as shown on the card, e.g. "M" or "5"
+	Points *float64 `json:"points,omitempty"` // Story points the vote is worth; nil if it has none
+}
+
+// PointsEstimate returns the estimate for a number of story points
+func PointsEstimate(points float64) Estimate {
+	return Estimate{Value: strconv.FormatFloat(points, 'f', -1, 64), Points: &points}
+}
+
+// UpdateEstimate writes an estimate to every configured estimate field
+func (c *Client) UpdateEstimate(ctx context.Context, issueKey string, estimate Estimate) error {
+	if issueKey == "" {
+		return fmt.Errorf("issue key is required")
+	}
+	if estimate.Points != nil && *estimate.Points < 0 {
+		return fmt.Errorf("story points must be non-negative")
+	}
+
+	fields := make(map[string]interface{}, len(c.config.EstimateFields))
+	for _, field := range c.config.EstimateFields {
+		value, err := c.fieldValue(field, estimate)
+		if err != nil {
+			return err
+		}
+		fields[field.ID] = value
+	}
+
+	payload := map[string]interface{}{"fields": fields}
+	err := c.put(ctx, c.apiPath(ctx, "issue/"+url.PathEscape(issueKey)), payload)
+	c.Invalidate(issueKey)
+	if err != nil {
+		return fmt.Errorf("jira update failed: %w", err)
+	}
+
+	return nil
+}
+
+// NeedsPoints reports whether any estimate field is written from story
+// points, so estimates without points cannot be written
+func (c *Client) NeedsPoints() bool {
+	for _, field := range c.config.EstimateFields {
+		if field.Type == FieldNumber || field.Type == FieldTimeTracking {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue returns the value an estimate sets a field to
+func (c *Client) fieldValue(field EstimateField, estimate Estimate) (interface{}, error) {
+	switch field.Type {
+	case FieldSelect:
+		if estimate.Value == "" {
+			return nil, fmt.Errorf("an estimate value is required for %s", field.ID)
+		}
+		return map[string]string{"value": estimate.Value}, nil
+	case FieldText:
+		if estimate.Value == "" {
+			return nil, fmt.Errorf("an estimate value is required for %s", field.ID)
+		}
+		return estimate.Value, nil
+	}
+
+	if estimate.Points == nil {
+		return nil, fmt.Errorf("estimate %q has no story points for %s", estimate.Value, field.ID)
+	}
+	if field.Type == FieldTimeTracking {
+		return map[string]string{"originalEstimate": formatDuration(*estimate.Points * c.config.HoursPerPoint)}, nil
+	}
+	return *estimate.Points, nil
+}
+
+// formatDuration formats hours in Jira's duration syntax, in whole minutes
+// when the hours are fractional
+func formatDuration(hours float64) string {
+	if hours == math.Trunc(hours) {
+		return strconv.FormatFloat(hours, 'f', -1, 64) + "h"
+	}
+	return strconv.FormatFloat(math.Round(hours*60), 'f', -1, 64) + "m"
+}
@@ -0,0 +1,61 @@
+package jira
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEstimateFields(t *testing.T) {
+	fields, err := ParseEstimateFields("customfield_10016, timetracking ,customfield_10200:select,customfield_10300:Text")
+	assert.Nil(t, err)
+	assert.Equal(t, []EstimateField{
+		{ID: "customfield_10016", Type: FieldNumber},
+		{ID: "timetracking", Type: FieldTimeTracking},
+		{ID: "customfield_10200", Type: FieldSelect},
+		{ID: "customfield_10300", Type: FieldText},
+	}, fields)
+
+	fields, err = ParseEstimateFields("")
+	assert.Nil(t, err)
+	assert.Empty(t, fields)
+
+	_, err = ParseEstimateFields("customfield_10016:date")
+	assert.NotNil(t, err)
+	_, err = ParseEstimateFields(":number")
+	assert.NotNil(t, err)
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "12h", formatDuration(12))
+	assert.Equal(t, "90m", formatDuration(1.5))
+	assert.Equal(t, "0h", formatDuration(0))
+}
+
+func TestClient_UpdateEstimate(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+		client.config.EstimateFields = []EstimateField{
+			{ID: "customfield_10016", Type: FieldNumber},
+			{ID: "timetracking", Type: FieldTimeTracking},
+			{ID: "customfield_10200", Type: FieldSelect},
+		}
+
+		points := 3.0
+		assert.Nil(t, client.UpdateEstimate(context.Background(), "WEB-1", Estimate{Value: "M", Points: &points}))
+		assert.Equal(t, map[string]interface{}{
+			"customfield_10016": 3.0,
+			"timetracking":      map[string]interface{}{"originalEstimate": "12h"},
+			"customfield_10200": map[string]interface{}{"value": "M"},
+		}, f.received()[0].Body["fields"])
+
+		// Number fields cannot be written without points, select lists can
+		assert.True(t, client.NeedsPoints())
+		assert.NotNil(t, client.UpdateEstimate(context.Background(), "WEB-1", Estimate{Value: "?"}))
+		client.config.EstimateFields = []EstimateField{{ID: "customfield_10200", Type: FieldSelect}}
+		assert.False(t, client.NeedsPoints())
+		assert.Nil(t, client.UpdateEstimate(context.Background(), "WEB-1", Estimate{Value: "?"}))
+		assert.Len(t, f.received(), 2)
+	})
+}
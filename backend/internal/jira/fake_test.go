@@ -0,0 +1,296 @@
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRequest is a request received by a fakeJira
+type fakeRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   map[string]interface{}
+	Auth   string
+}
+
+// fakeJira is a local stand-in for a Jira site of either flavor. Searches
+// ignore the JQL and page through all issues; requests to endpoints the
+// flavor lacks fail as they would on a real site.
+type fakeJira struct {
+	URL        string
+	deployment string // "Cloud" or "DataCenter"
+
+	mu       sync.Mutex
+	keys     []string
+	issues   map[string]map[string]interface{} // Fields by issue key
+	comments map[string][]interface{}          // Comment bodies by issue key
+	requests []fakeRequest
+	failures []fakeFailure // Answers to the next requests, instead of serving them
+	editable []string      // Fields editable on issues, by ID; all fakeFields when nil
+	denied   []string      // Permissions the client lacks
+}
+
+// fakeFields are the fields of the fake site
+var fakeFields = []map[string]interface{}{
+	{"id": "summary", "name": "Summary", "custom": false, "schema": map[string]string{"type": "string", "system": "summary"}},
+	{"id": "customfield_10016", "name": "Story point estimate", "custom": true, "schema": map[string]string{"type": "number", "custom": jswStoryPointsType}},
+	{"id": "customfield_10026", "name": "Story Points", "custom": true, "schema": map[string]string{"type": "number", "custom": storyPointsType}},
+	{"id": "customfield_10030", "name": "Team", "custom": true, "schema": map[string]string{"type": "option"}},
+}
+
+// fakeFailure is an error status the fake answers a request with; a zero
+// status serves the request as usual
+type fakeFailure struct {
+	status     int
+	retryAfter string
+}
+
+// fail answers the next requests other than for server info with the status
+func (f *fakeJira) fail(times, status int, retryAfter string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < times; i++ {
+		f.failures = append(f.failures, fakeFailure{status, retryAfter})
+	}
+}
+
+// fakeWorkflow lists the transitions every issue can take, by ID
+var fakeWorkflow = map[string][2]string{
+	"21": {"Ready for development", "Ready"},
+	"31": {"Start", "In Progress"},
+}
+
+func newFakeJira(t *testing.T, deployment string) *fakeJira {
+	f := &fakeJira{deployment: deployment, issues: make(map[string]map[string]interface{}), comments: make(map[string][]interface{})}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	f.URL = server.URL
+	return f
+}
+
+// addIssue adds an issue with the given fields
+func (f *fakeJira) addIssue(key string, fields map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = append(f.keys, key)
+	f.issues[key] = fields
+}
+
+// received returns the requests received, other than for server info
+func (f *fakeJira) received() []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var requests []fakeRequest
+	for _, r := range f.requests {
+		if !strings.HasSuffix(r.Path, "/serverInfo") {
+			requests = append(requests, r)
+		}
+	}
+	return requests
+}
+
+func (f *fakeJira) cloud() bool {
+	return f.deployment == "Cloud"
+}
+
+func (f *fakeJira) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req := fakeRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Auth: r.Header.Get("Authorization")}
+	json.NewDecoder(r.Body).Decode(&req.Body)
+	f.requests = append(f.requests, req)
+
+	path := r.URL.Path
+	var failure fakeFailure
+	if len(f.failures) > 0 && !strings.HasSuffix(path, "/serverInfo") {
+		failure = f.failures[0]
+		f.failures = f.failures[1:]
+	}
+	if failure.status != 0 {
+		if failure.retryAfter != "" {
+			w.Header().Set("Retry-After", failure.retryAfter)
+		}
+		writeJSON(w, failure.status, map[string]interface{}{"errorMessages": []string{http.StatusText(failure.status)}})
+		return
+	}
+
+	switch {
+	case path == "/rest/api/2/serverInfo":
+		writeJSON(w, http.StatusOK, map[string]string{"baseUrl": f.URL, "version": "9.12.0", "deploymentType": f.deployment})
+
+	case path == f.apiPath()+"myself":
+		if f.cloud() {
+			writeJSON(w, http.StatusOK, map[string]string{"accountId": "5b10a2844c20165700ede21g", "displayName": "Estimation Bot"})
+		} else {
+			writeJSON(w, http.StatusOK, map[string]string{"name": "bot", "displayName": "Estimation Bot"})
+		}
+
+	case path == f.apiPath()+"field":
+		writeJSON(w, http.StatusOK, fakeFields)
+
+	case path == f.apiPath()+"mypermissions":
+		permissions := map[string]interface{}{}
+		for _, p := range strings.Split(r.URL.Query().Get("permissions"), ",") {
+			permissions[p] = map[string]interface{}{"key": p, "havePermission": !slices.Contains(f.denied, p)}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"permissions": permissions})
+
+	case path == "/rest/api/3/search/jql" && f.cloud():
+		start, _ := strconv.Atoi(strings.TrimPrefix(stringValue(req.Body["nextPageToken"]), "page-"))
+		keys, end := f.page(start, req.Body["maxResults"])
+		resp := map[string]interface{}{"issues": f.render(keys)}
+		if end < len(f.keys) {
+			resp["nextPageToken"] = "page-" + strconv.Itoa(end)
+		}
+		writeJSON(w, http.StatusOK, resp)
+
+	case path == "/rest/api/2/search" && f.cloud():
+		writeJSON(w, http.StatusGone, map[string]interface{}{"errorMessages": []string{"The requested API has been removed."}})
+
+	case path == "/rest/api/2/search":
+		start, _ := req.Body["startAt"].(float64)
+		keys, _ := f.page(int(start), req.Body["maxResults"])
+		writeJSON(w, http.StatusOK, map[string]interface{}{"startAt": start, "total": len(f.keys), "issues": f.render(keys)})
+
+	case strings.HasPrefix(path, f.issuePath()):
+		key, resource, _ := strings.Cut(strings.TrimPrefix(path, f.issuePath()), "/")
+		fields, ok := f.issues[key]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"errorMessages": []string{"Issue does not exist"}})
+			return
+		}
+		f.serveIssue(w, r.Method, resource, key, fields, req.Body)
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errorMessages": []string{"Not found: " + path}})
+	}
+}
+
+// serveIssue serves an issue or one of its sub-resources
+func (f *fakeJira) serveIssue(w http.ResponseWriter, method, resource, key string, fields, body map[string]interface{}) {
+	switch {
+	case resource == "comment" && method == http.MethodPost:
+		f.comments[key] = append(f.comments[key], body["body"])
+		writeJSON(w, http.StatusCreated, map[string]string{"id": strconv.Itoa(len(f.comments[key]))})
+
+	case resource == "transitions" && method == http.MethodPost:
+		transition, _ := body["transition"].(map[string]interface{})
+		to, ok := fakeWorkflow[stringValue(transition["id"])]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errorMessages": []string{"Invalid transition"}})
+			return
+		}
+		fields["status"] = map[string]string{"name": to[1]}
+		w.WriteHeader(http.StatusNoContent)
+
+	case resource == "transitions":
+		var transitions []map[string]interface{}
+		for id, t := range fakeWorkflow {
+			transitions = append(transitions, map[string]interface{}{"id": id, "name": t[0], "to": map[string]string{"name": t[1]}})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"transitions": transitions})
+
+	case resource == "editmeta":
+		// Server leaves the ID out of each field
+		meta := map[string]interface{}{}
+		for _, field := range fakeFields {
+			id := field["id"].(string)
+			if f.editable == nil || slices.Contains(f.editable, id) {
+				meta[id] = map[string]interface{}{"name": field["name"], "schema": field["schema"], "operations": []string{"set"}}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"fields": meta})
+
+	case resource == "" && method == http.MethodPut:
+		update, _ := body["fields"].(map[string]interface{})
+		for k, v := range update {
+			fields[k] = v
+		}
+		operations, _ := body["update"].(map[string]interface{})
+		labelOps, _ := operations["labels"].([]interface{})
+		for _, op := range labelOps {
+			add := stringValue(op.(map[string]interface{})["add"])
+			labels, _ := fields["labels"].([]interface{})
+			fields["labels"] = append(labels, add)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case resource == "":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "fields": fields})
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errorMessages": []string{"Not found"}})
+	}
+}
+
+// apiPath is the platform API of the flavor; Data Center has no v3
+func (f *fakeJira) apiPath() string {
+	if f.cloud() {
+		return "/rest/api/3/"
+	}
+	return "/rest/api/2/"
+}
+
+// issuePath is the issue resource of the flavor's API
+func (f *fakeJira) issuePath() string {
+	return f.apiPath() + "issue/"
+}
+
+func (f *fakeJira) page(start int, maxResults interface{}) ([]string, int) {
+	size, _ := maxResults.(float64)
+	end := min(len(f.keys), start+int(size))
+	if start >= end {
+		return nil, start
+	}
+	return f.keys[start:end], end
+}
+
+func (f *fakeJira) render(keys []string) []map[string]interface{} {
+	issues := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		issues = append(issues, map[string]interface{}{"key": key, "fields": f.issues[key]})
+	}
+	return issues
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// forEachFlavor runs the test against a fake Cloud site (email and API token)
+// and a fake Data Center site (personal access token), flavors detected
+func forEachFlavor(t *testing.T, test func(t *testing.T, f *fakeJira, client *Client)) {
+	sites := []struct {
+		deployment string
+		email      string
+	}{
+		{"Cloud", "bot@example.com"},
+		{"DataCenter", ""},
+	}
+	for _, site := range sites {
+		t.Run(site.deployment, func(t *testing.T) {
+			f := newFakeJira(t, site.deployment)
+			client, err := NewClient(Config{BaseURL: f.URL, Email: site.email, APIToken: "secret", MaxSearchLimit: 50})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, f, client)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// flavorRetryInterval is how long a failed flavor detection is not retried
+const flavorRetryInterval = time.Minute
+
+// Flavor is the kind of Jira deployment, which decides authentication and
+// the REST API version used
+type Flavor string
+
+const (
+	FlavorAuto   Flavor = ""       // Detected from the site's server info
+	FlavorCloud  Flavor = "cloud"  // Email and API token, REST API v3
+	FlavorServer Flavor = "server" // Server and Data Center: personal access token, REST API v2
+)
+
+// ParseFlavor parses a JIRA_FLAVOR value; "datacenter" and "dc" mean server
+func ParseFlavor(value string) (Flavor, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return FlavorAuto, nil
+	case "cloud":
+		return FlavorCloud, nil
+	case "server", "datacenter", "data-center", "dc":
+		return FlavorServer, nil
+	}
+	return FlavorAuto, fmt.Errorf("unknown jira flavor %q (expected auto, cloud or server)", value)
+}
+
+// ServerInfo describes a Jira site
+type ServerInfo struct {
+	BaseURL        string `json:"baseUrl"`
+	Version        string `json:"version"`
+	DeploymentType string `json:"deploymentType"` // "Cloud", "Server" or "DataCenter"
+	ServerTitle    string `json:"serverTitle"`
+}
+
+// Flavor returns the deployment flavor of the site
+func (i *ServerInfo) Flavor() Flavor {
+	if strings.EqualFold(i.DeploymentType, "Cloud") {
+		return FlavorCloud
+	}
+	return FlavorServer
+}
+
+// ServerInfo fetches the site's version and deployment type. The endpoint
+// exists under v2 on every flavor and does not require authentication.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	req, err := c.jiraClient.NewRequestWithContext(ctx, "GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server info request: %w", err)
+	}
+
+	var info ServerInfo
+	if err := c.do(req, &info); err != nil {
+		return nil, fmt.Errorf("jira server info failed: %w", err)
+	}
+	if info.DeploymentType == "" {
+		return nil, fmt.Errorf("jira server info has no deployment type")
+	}
+	return &info, nil
+}
+
+// Flavor returns the configured flavor, detecting it on first use when set
+// to auto. The site is asked without holding the lock, and by one caller at
+// a time; the others, and every call for flavorRetryInterval after a failed
+// detection, use the flavor guessed from the credentials (an email means
+// Cloud).
+func (c *Client) Flavor(ctx context.Context) Flavor {
+	c.flavorMu.Lock()
+	if c.flavor != FlavorAuto {
+		defer c.flavorMu.Unlock()
+		return c.flavor
+	}
+	if time.Now().Before(c.flavorRetryAt) {
+		c.flavorMu.Unlock()
+		return c.guessFlavor()
+	}
+	c.flavorRetryAt = time.Now().Add(flavorRetryInterval)
+	c.flavorMu.Unlock()
+
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		guess := c.guessFlavor()
+		log.Printf("⚠️  Could not detect Jira flavor, assuming %s for %s: %v", guess, flavorRetryInterval, err)
+		return guess
+	}
+
+	c.flavorMu.Lock()
+	c.flavor = info.Flavor()
+	c.flavorMu.Unlock()
+	log.Printf("Detected Jira %s (%s %s)", info.Flavor(), info.DeploymentType, info.Version)
+	return info.Flavor()
+}
+
+// guessFlavor returns the flavor the credentials suggest
+func (c *Client) guessFlavor() Flavor {
+	if c.config.Email != "" {
+		return FlavorCloud
+	}
+	return FlavorServer
+}
+
+// apiPath returns the platform REST API path for the site's flavor
+func (c *Client) apiPath(ctx context.Context, path string) string {
+	if c.Flavor(ctx) == FlavorServer {
+		return "rest/api/2/" + path
+	}
+	return "rest/api/3/" + path
+}
@@ -0,0 +1,100 @@
+package jira
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Issue represents a simplified Jira issue
+type Issue struct {
+	Key                string   `json:"key"`
+	Summary            string   `json:"summary"`
+	Type               string   `json:"type,omitempty"`
+	Priority           string   `json:"priority,omitempty"`
+	Status             string   `json:"status,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
+	Points             *float64 `json:"points,omitempty"`             // Nil when not estimated
+	Description        string   `json:"description,omitempty"`        // Safe HTML, only from GetIssue
+	AcceptanceCriteria string   `json:"acceptanceCriteria,omitempty"` // Safe HTML, only from GetIssue
+	URL                string   `json:"url,omitempty"`                // Browse page of the issue
+}
+
+// apiIssue is an issue as returned by the REST API. Fields are kept raw
+// since their shape depends on the field and the API version.
+type apiIssue struct {
+	Key    string                     `json:"key"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+// listFields are the fields requested for issue lists
+func (c *Client) listFields() []string {
+	return []string{"summary", "issuetype", "priority", "status", "labels", c.config.StoryPointsField}
+}
+
+// detailFields are the fields requested for a single issue
+func (c *Client) detailFields() []string {
+	fields := append(c.listFields(), "description")
+	if c.config.AcceptanceCriteriaField != "" {
+		fields = append(fields, c.config.AcceptanceCriteriaField)
+	}
+	return fields
+}
+
+// toIssue converts an issue as returned by Jira
+func (c *Client) toIssue(raw *apiIssue) Issue {
+	issue := Issue{
+		Key:      raw.Key,
+		Summary:  fieldString(raw.Fields["summary"]),
+		Type:     fieldName(raw.Fields["issuetype"]),
+		Priority: fieldName(raw.Fields["priority"]),
+		Status:   fieldName(raw.Fields["status"]),
+		Points:   fieldNumber(raw.Fields[c.config.StoryPointsField]),
+		URL:      c.BrowseURL(raw.Key),
+	}
+	json.Unmarshal(raw.Fields["labels"], &issue.Labels)
+
+	issue.Description = RenderRichText(raw.Fields["description"])
+	if c.config.AcceptanceCriteriaField != "" {
+		issue.AcceptanceCriteria = RenderRichText(raw.Fields[c.config.AcceptanceCriteriaField])
+	}
+	return issue
+}
+
+// BrowseURL returns the address of the issue's page in Jira
+func (c *Client) BrowseURL(key string) string {
+	return strings.TrimSuffix(c.config.BaseURL, "/") + "/browse/" + key
+}
+
+func fieldString(raw json.RawMessage) string {
+	var s string
+	json.Unmarshal(raw, &s)
+	return s
+}
+
+// fieldName returns the name of an object-valued field such as the status
+func fieldName(raw json.RawMessage) string {
+	var v struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(raw, &v)
+	return v.Name
+}
+
+// fieldNumber returns a numeric field, which custom fields may hold as a string
+func fieldNumber(raw json.RawMessage) *float64 {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+
+	switch v := v.(type) {
+	case float64:
+		return &v
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return &f
+		}
+	}
+	return nil
+}
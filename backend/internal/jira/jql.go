@@ -0,0 +1,175 @@
+package jira
+
+import "strings"
+
+// Sprint filter values with a special meaning
+const (
+	SprintOpen   = "open"   // Issues in any open sprint
+	SprintFuture = "future" // Issues in sprints that have not started
+)
+
+// Assignee filter values with a special meaning
+const (
+	AssigneeMe         = "me"         // The user the client authenticates as
+	AssigneeUnassigned = "unassigned" // Issues nobody is assigned to
+)
+
+// SearchOptions filters an issue search. Empty fields do not filter.
+type SearchOptions struct {
+	Query       string   // Text in the summary, or an issue key
+	Project     string   // Project key
+	Filter      string   // Saved filter ID or name
+	IssueTypes  []string // Issue type names, any of
+	Statuses    []string // Status names, any of
+	Sprint      string   // Sprint ID or name, SprintOpen or SprintFuture
+	Assignee    string   // Account ID or username, AssigneeMe or AssigneeUnassigned
+	Unestimated bool     // Only issues without story points
+	MaxResults  int      // Page size, capped by the client's configured maximum
+	PageToken   string   // Token of the page to fetch, from a previous result
+}
+
+// HasFilters reports whether anything other than the text query narrows the search
+func (o SearchOptions) HasFilters() bool {
+	return o.Project != "" || o.Filter != "" || len(o.IssueTypes) > 0 || len(o.Statuses) > 0 ||
+		o.Sprint != "" || o.Assignee != "" || o.Unestimated
+}
+
+// QuoteJQL returns s as a JQL string literal. Quotes and backslashes are
+// escaped, so user input can never end the literal and change the query.
+func QuoteJQL(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// textSearchReserved are characters the text search operator (~) treats as
+// query syntax
+const textSearchReserved = `+-&|!(){}[]^~*?:\/"`
+
+// quoteText returns s as a literal for the text search operator, with the
+// search syntax characters escaped so they are matched literally
+func quoteText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(textSearchReserved, r) {
+			// Doubled by QuoteJQL, so one backslash reaches the text search
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return QuoteJQL(b.String())
+}
+
+// quoteList returns the values as a JQL list of string literals
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = QuoteJQL(v)
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// fieldClause returns how a field ID is referred to in JQL; custom fields
+// are referred to by number since their names need not be unique
+func fieldClause(field string) string {
+	if id, ok := strings.CutPrefix(field, "customfield_"); ok {
+		return "cf[" + id + "]"
+	}
+	return field
+}
+
+// BuildJQL returns the JQL query for the search options. pointsField is the
+// story points field, used for the unestimated filter.
+func BuildJQL(opts SearchOptions, pointsField string) string {
+	var clauses []string
+
+	if q := strings.TrimSpace(opts.Query); q != "" {
+		text := "summary ~ " + quoteText(q)
+		if isIssueKey(strings.ToUpper(q)) {
+			text = "(" + text + " OR key = " + QuoteJQL(strings.ToUpper(q)) + ")"
+		}
+		clauses = append(clauses, text)
+	}
+	if opts.Project != "" {
+		clauses = append(clauses, "project = "+QuoteJQL(opts.Project))
+	}
+	if opts.Filter != "" {
+		if isNumber(opts.Filter) {
+			clauses = append(clauses, "filter = "+opts.Filter)
+		} else {
+			clauses = append(clauses, "filter = "+QuoteJQL(opts.Filter))
+		}
+	}
+	if len(opts.IssueTypes) > 0 {
+		clauses = append(clauses, "issuetype in "+quoteList(opts.IssueTypes))
+	}
+	if len(opts.Statuses) > 0 {
+		clauses = append(clauses, "status in "+quoteList(opts.Statuses))
+	}
+
+	switch sprint := opts.Sprint; {
+	case sprint == "":
+	case strings.EqualFold(sprint, SprintOpen):
+		clauses = append(clauses, "sprint in openSprints()")
+	case strings.EqualFold(sprint, SprintFuture):
+		clauses = append(clauses, "sprint in futureSprints()")
+	default:
+		if isNumber(sprint) {
+			clauses = append(clauses, "sprint = "+sprint)
+		} else {
+			clauses = append(clauses, "sprint = "+QuoteJQL(sprint))
+		}
+	}
+
+	switch assignee := opts.Assignee; {
+	case assignee == "":
+	case strings.EqualFold(assignee, AssigneeMe):
+		clauses = append(clauses, "assignee = currentUser()")
+	case strings.EqualFold(assignee, AssigneeUnassigned):
+		clauses = append(clauses, "assignee is EMPTY")
+	default:
+		clauses = append(clauses, "assignee = "+QuoteJQL(assignee))
+	}
+
+	if opts.Unestimated {
+		if clause := unestimatedClause(pointsField); clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(clauses, " AND ") + " ORDER BY updated DESC")
+}
+
+// unestimatedClause matches issues without story points in pointsField
+func unestimatedClause(pointsField string) string {
+	if pointsField == "" {
+		return ""
+	}
+	return fieldClause(pointsField) + " is EMPTY"
+}
+
+// isNumber reports whether s is a non-empty string of digits, such as an ID
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
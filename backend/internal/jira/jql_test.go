@@ -0,0 +1,77 @@
+package jira
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteJQL(t *testing.T) {
+	assert.Equal(t, `"plain"`, QuoteJQL("plain"))
+	assert.Equal(t, `"say \"hi\""`, QuoteJQL(`say "hi"`))
+	assert.Equal(t, `"back\\slash"`, QuoteJQL(`back\slash`))
+	assert.Equal(t, `"two lines"`, QuoteJQL("two\nlines"))
+}
+
+func TestBuildJQL(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want string
+	}{
+		{
+			name: "text",
+			opts: SearchOptions{Query: "login page"},
+			want: `summary ~ "login page" ORDER BY updated DESC`,
+		},
+		{
+			name: "injection attempt stays inside the literal",
+			opts: SearchOptions{Project: `WEB" OR project = "SECRET`},
+			want: `project = "WEB\" OR project = \"SECRET" ORDER BY updated DESC`,
+		},
+		{
+			name: "quotes in text are escaped for JQL and the text search",
+			opts: SearchOptions{Query: `x" OR key = "y`},
+			want: `summary ~ "x\\\" OR key = \\\"y" ORDER BY updated DESC`,
+		},
+		{
+			name: "text search syntax is matched literally",
+			opts: SearchOptions{Query: "fix [urgent]*"},
+			want: `summary ~ "fix \\[urgent\\]\\*" ORDER BY updated DESC`,
+		},
+		{
+			name: "issue key",
+			opts: SearchOptions{Query: "proj-12"},
+			want: `(summary ~ "proj\\-12" OR key = "PROJ-12") ORDER BY updated DESC`,
+		},
+		{
+			name: "filters",
+			opts: SearchOptions{
+				Project:     "WEB",
+				IssueTypes:  []string{"Story", "Bug"},
+				Statuses:    []string{"To Do"},
+				Sprint:      "open",
+				Assignee:    "me",
+				Unestimated: true,
+			},
+			want: `project = "WEB" AND issuetype in ("Story", "Bug") AND status in ("To Do") AND ` +
+				`sprint in openSprints() AND assignee = currentUser() AND cf[10016] is EMPTY ORDER BY updated DESC`,
+		},
+		{
+			name: "sprint by id and name",
+			opts: SearchOptions{Sprint: "42"},
+			want: `sprint = 42 ORDER BY updated DESC`,
+		},
+		{
+			name: "sprint by name",
+			opts: SearchOptions{Sprint: `Sprint "7"`, Assignee: "unassigned"},
+			want: `sprint = "Sprint \"7\"" AND assignee is EMPTY ORDER BY updated DESC`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, BuildJQL(tt.opts, "customfield_10016"))
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package jira
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WriteStatus is where a write-back stands
+type WriteStatus string
+
+const (
+	WritePending WriteStatus = "pending" // Waiting for its next attempt
+	WriteFailed  WriteStatus = "failed"  // Given up on until retried by hand
+	WriteDone    WriteStatus = "done"
+)
+
+// Steps of a write-back, in the order they are done
+const (
+	StepEstimate   = "estimate"
+	StepComment    = "comment"
+	StepLabel      = "label"
+	StepTransition = "transition"
+)
+
+// Outbox defaults used when the config leaves them unset
+const (
+	DefaultOutboxAttempts = 8
+	DefaultOutboxDelay    = 10 * time.Second
+	DefaultOutboxMaxDelay = 30 * time.Minute
+	DefaultOutboxPoll     = 5 * time.Second
+	DefaultOutboxLease    = 2 * time.Minute
+	DefaultOutboxBatch    = 20
+)
+
+var (
+	// ErrWriteNotFound is returned when retrying a write that is not stored
+	ErrWriteNotFound = errors.New("jira write not found")
+	// ErrWriteBusy is returned when retrying a write that is being attempted
+	ErrWriteBusy = errors.New("jira write is being attempted")
+)
+
+// Write is an estimate to write back to Jira with the room's actions. Its
+// steps are done in order; a retry resumes at the step that failed, so
+// comments are not posted twice.
+type Write struct {
+	ID            string      `json:"id"`
+	Room          string      `json:"room,omitempty"`
+	IssueKey      string      `json:"issueKey"`
+	Estimate      Estimate    `json:"estimate"`
+	Actions       Actions     `json:"actions"`
+	Votes         Votes       `json:"votes,omitempty"` // For the comment
+	Status        WriteStatus `json:"status"`
+	Done          []string    `json:"done,omitempty"` // Steps completed
+	Attempts      int         `json:"attempts"`
+	LastError     string      `json:"lastError,omitempty"`
+	NextAttemptAt time.Time   `json:"nextAttemptAt"`
+	CreatedAt     time.Time   `json:"createdAt"`
+	UpdatedAt     time.Time   `json:"updatedAt"`
+}
+
+// Steps returns the steps of the write in order
+func (w *Write) Steps() []string {
+	steps := []string{StepEstimate}
+	if w.Actions.Comment {
+		steps = append(steps, StepComment)
+	}
+	if w.Actions.Label != "" {
+		steps = append(steps, StepLabel)
+	}
+	if w.Actions.Transition != "" {
+		steps = append(steps, StepTransition)
+	}
+	return steps
+}
+
+// OutboxStore persists write-backs until they are done
+type OutboxStore interface {
+	SaveWrite(w *Write) error
+	DeleteWrite(id string) error
+	GetWrite(id string) (*Write, error) // Nil when not stored
+	// ClaimWrites returns pending writes due by now and pushes their next
+	// attempt back by lease, so other instances leave them alone meanwhile
+	ClaimWrites(now time.Time, lease time.Duration, limit int) ([]*Write, error)
+	// ClaimWrite pushes the next attempt of a loaded write back by lease,
+	// reporting false if it was claimed or updated since it was loaded
+	ClaimWrite(w *Write, now time.Time, lease time.Duration) (bool, error)
+	ListWrites(room string) ([]*Write, error) // Pending and failed writes of a room, oldest first
+}
+
+// OutboxConfig holds outbox settings
+type OutboxConfig struct {
+	MaxAttempts  int           // Attempts before a write fails
+	BaseDelay    time.Duration // Wait before the first retry, doubled for each one after
+	MaxDelay     time.Duration // Longest wait between attempts, unless Jira asks for longer
+	PollInterval time.Duration // How often due writes are looked for
+	Lease        time.Duration // How long an attempt may take before another worker may claim the write
+	BatchSize    int           // Writes claimed per poll
+}
+
+// Outbox writes estimates back to Jira, retrying with exponential backoff
+// while Jira is rate limiting or unavailable. Writes are stored first, so
+// none are lost to an outage or restart.
+type Outbox struct {
+	client *Client
+	store  OutboxStore
+	config OutboxConfig
+	now    func() time.Time
+	ctx    context.Context // Cancelled by Stop, ending attempts in flight
+	cancel context.CancelFunc
+
+	mu         sync.Mutex      // Guards attempting
+	attempting map[string]bool // IDs of the writes being attempted here
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// NewOutbox creates an outbox with default settings
+func NewOutbox(client *Client, store OutboxStore) *Outbox {
+	return NewOutboxWithConfig(client, store, OutboxConfig{})
+}
+
+// NewOutboxWithConfig creates an outbox; zero config values use the defaults
+func NewOutboxWithConfig(client *Client, store OutboxStore, config OutboxConfig) *Outbox {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultOutboxAttempts
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = DefaultOutboxDelay
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultOutboxMaxDelay
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultOutboxPoll
+	}
+	if config.Lease <= 0 {
+		config.Lease = DefaultOutboxLease
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultOutboxBatch
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Outbox{
+		client:     client,
+		store:      store,
+		config:     config,
+		now:        time.Now,
+		ctx:        ctx,
+		cancel:     cancel,
+		attempting: make(map[string]bool),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start retries due writes in the background until Stop is called
+func (o *Outbox) Start() {
+	go func() {
+		defer close(o.done)
+		ticker := time.NewTicker(o.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.stop:
+				return
+			case <-ticker.C:
+				o.RetryDue()
+			}
+		}
+	}()
+}
+
+// Stop stops the background retries started by Start. Attempts in flight
+// are cut short and left pending.
+func (o *Outbox) Stop() {
+	o.cancel()
+	close(o.stop)
+	<-o.done
+}
+
+// Submit stores a new write and makes its first attempt right away. The
+// returned write tells whether it is done, waiting for a retry or failed.
+func (o *Outbox) Submit(w *Write) (*Write, error) {
+	now := o.now()
+	w.ID = uuid.NewString()
+	w.Status = WritePending
+	w.CreatedAt = now
+	w.UpdatedAt = now
+	w.NextAttemptAt = now.Add(o.config.Lease)
+	if err := o.store.SaveWrite(w); err != nil {
+		return nil, fmt.Errorf("failed to store jira write: %w", err)
+	}
+
+	o.begin(w.ID)
+	defer o.end(w.ID)
+	o.attempt(w)
+	return w, nil
+}
+
+// Retry makes an immediate attempt at a pending or failed write of the room,
+// starting its attempts afresh. The write is claimed first, like due writes,
+// so it is not attempted twice when retried from several places at once.
+func (o *Outbox) Retry(id, room string) (*Write, error) {
+	if !o.begin(id) {
+		return nil, ErrWriteBusy
+	}
+	defer o.end(id)
+
+	w, err := o.store.GetWrite(id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil || w.Room != room {
+		return nil, ErrWriteNotFound
+	}
+	claimed, err := o.store.ClaimWrite(w, o.now(), o.config.Lease)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrWriteBusy
+	}
+
+	w.Status = WritePending
+	w.Attempts = 0
+	o.attempt(w)
+	return w, nil
+}
+
+// List returns the pending and failed writes of a room
+func (o *Outbox) List(room string) ([]*Write, error) {
+	return o.store.ListWrites(room)
+}
+
+// RetryDue attempts the writes whose retry is due
+func (o *Outbox) RetryDue() {
+	writes, err := o.store.ClaimWrites(o.now(), o.config.Lease, o.config.BatchSize)
+	if err != nil {
+		log.Printf("⚠️  Failed to claim jira writes: %v", err)
+		return
+	}
+	for _, w := range writes {
+		if o.begin(w.ID) {
+			o.attempt(w)
+			o.end(w.ID)
+		}
+	}
+}
+
+// begin marks a write as being attempted, reporting false if it already is.
+// Writes are attempted one at a time each, but different writes at once.
+func (o *Outbox) begin(id string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.attempting[id] {
+		return false
+	}
+	o.attempting[id] = true
+	return true
+}
+
+// end marks the end of an attempt started with begin
+func (o *Outbox) end(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.attempting, id)
+}
+
+// attempt does the remaining steps of a write and stores the outcome.
+// Callers mark the write with begin first.
+func (o *Outbox) attempt(w *Write) {
+	w.Attempts++
+	err := o.run(w)
+	now := o.now()
+	w.UpdatedAt = now
+
+	if err == nil {
+		w.Status = WriteDone
+		w.LastError = ""
+		if err := o.store.DeleteWrite(w.ID); err != nil {
+			log.Printf("⚠️  Failed to remove done jira write %s: %v", w.ID, err)
+		}
+		return
+	}
+
+	w.LastError = err.Error()
+	temporary, wait := IsTemporary(err)
+	if temporary && w.Attempts < o.config.MaxAttempts {
+		w.Status = WritePending
+		w.NextAttemptAt = now.Add(max(o.backoff(w.Attempts), wait))
+		log.Printf("Jira write for %s failed (attempt %d), retrying at %s: %v", w.IssueKey, w.Attempts, w.NextAttemptAt.Format(time.RFC3339), err)
+	} else {
+		w.Status = WriteFailed
+		log.Printf("⚠️  Jira write for %s in room %s failed after %d attempts: %v", w.IssueKey, w.Room, w.Attempts, err)
+	}
+	if err := o.store.SaveWrite(w); err != nil {
+		log.Printf("⚠️  Failed to store jira write %s: %v", w.ID, err)
+	}
+}
+
+// run does the steps of a write not yet done, stopping at the first failure
+func (o *Outbox) run(w *Write) error {
+	for _, step := range w.Steps() {
+		if slices.Contains(w.Done, step) {
+			continue
+		}
+
+		var err error
+		switch step {
+		case StepEstimate:
+			err = o.client.UpdateEstimate(o.ctx, w.IssueKey, w.Estimate)
+		case StepComment:
+			err = o.client.AddComment(o.ctx, w.IssueKey, VoteSummary{Estimate: w.Estimate, Votes: w.Votes}.Comment())
+		case StepLabel:
+			err = o.client.AddLabel(o.ctx, w.IssueKey, w.Actions.Label)
+		case StepTransition:
+			err = o.client.TransitionTo(o.ctx, w.IssueKey, w.Actions.Transition)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", step, err)
+		}
+		w.Done = append(w.Done, step)
+	}
+	return nil
+}
+
+// backoff returns the wait after the given number of attempts
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.config.BaseDelay
+	for i := 1; i < attempts && delay < o.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.config.MaxDelay)
+}
+
+// MemoryOutboxStore keeps writes in memory, for tests and setups without a
+// database; writes do not survive a restart
+type MemoryOutboxStore struct {
+	mu     sync.Mutex
+	writes map[string]Write
+}
+
+// NewMemoryOutboxStore creates an empty in-memory store
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{writes: make(map[string]Write)}
+}
+
+func (s *MemoryOutboxStore) SaveWrite(w *Write) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes[w.ID] = copyWrite(w)
+	return nil
+}
+
+func (s *MemoryOutboxStore) DeleteWrite(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.writes, id)
+	return nil
+}
+
+func (s *MemoryOutboxStore) GetWrite(id string) (*Write, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.writes[id]
+	if !ok {
+		return nil, nil
+	}
+	c := copyWrite(&w)
+	return &c, nil
+}
+
+func (s *MemoryOutboxStore) ClaimWrites(now time.Time, lease time.Duration, limit int) ([]*Write, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*Write
+	for _, w := range s.writes {
+		if w.Status == WritePending && !w.NextAttemptAt.After(now) {
+			c := copyWrite(&w)
+			due = append(due, &c)
+		}
+	}
+	sortWrites(due, func(w *Write) time.Time { return w.NextAttemptAt })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, w := range due {
+		w.NextAttemptAt = now.Add(lease)
+		s.writes[w.ID] = copyWrite(w)
+	}
+	return due, nil
+}
+
+func (s *MemoryOutboxStore) ClaimWrite(w *Write, now time.Time, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.writes[w.ID]
+	if !ok || !stored.NextAttemptAt.Equal(w.NextAttemptAt) {
+		return false, nil
+	}
+	w.NextAttemptAt = now.Add(lease)
+	stored.NextAttemptAt = w.NextAttemptAt
+	s.writes[w.ID] = stored
+	return true, nil
+}
+
+func (s *MemoryOutboxStore) ListWrites(room string) ([]*Write, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var writes []*Write
+	for _, w := range s.writes {
+		if w.Room == room && w.Status != WriteDone {
+			c := copyWrite(&w)
+			writes = append(writes, &c)
+		}
+	}
+	sortWrites(writes, func(w *Write) time.Time { return w.CreatedAt })
+	return writes, nil
+}
+
+func copyWrite(w *Write) Write {
+	c := *w
+	c.Done = slices.Clone(w.Done)
+	return c
+}
+
+func sortWrites(writes []*Write, by func(*Write) time.Time) {
+	slices.SortFunc(writes, func(a, b *Write) int { return by(a).Compare(by(b)) })
+}
@@ -0,0 +1,152 @@
+package jira
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestOutbox returns an outbox on a fake clock, which the returned
+// function advances
+func newTestOutbox(client *Client, config OutboxConfig) (*Outbox, *MemoryOutboxStore, func(time.Duration)) {
+	store := NewMemoryOutboxStore()
+	outbox := NewOutboxWithConfig(client, store, config)
+	now := time.Now()
+	outbox.now = func() time.Time { return now }
+	return outbox, store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestOutbox_Submit(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First", "status": map[string]string{"name": "To Do"}})
+		outbox, store, _ := newTestOutbox(client, OutboxConfig{})
+
+		w, err := outbox.Submit(&Write{
+			Room:     "ROOM",
+			IssueKey: "WEB-1",
+			Estimate: PointsEstimate(5),
+			Actions:  Actions{Comment: true, Label: "estimated", Transition: "Ready"},
+			Votes:    Votes{{Name: "Ada", Vote: "5"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, WriteDone, w.Status)
+		assert.Equal(t, []string{StepEstimate, StepComment, StepLabel, StepTransition}, w.Done)
+		assert.Equal(t, 1, w.Attempts)
+
+		// Done writes are not kept
+		writes, _ := store.ListWrites("ROOM")
+		assert.Empty(t, writes)
+		issue, _ := client.GetIssue(context.Background(), "WEB-1")
+		assert.Equal(t, 5.0, *issue.Points)
+		assert.Equal(t, "Ready", issue.Status)
+	})
+}
+
+func TestOutbox_Retries(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "First"})
+		outbox, store, advance := newTestOutbox(client, OutboxConfig{BaseDelay: time.Second, MaxAttempts: 3})
+
+		// Rate limited: the Retry-After wins over the shorter backoff
+		f.fail(1, http.StatusTooManyRequests, "30")
+		w, err := outbox.Submit(&Write{Room: "ROOM", IssueKey: "WEB-1", Estimate: PointsEstimate(3), Actions: Actions{Comment: true}})
+		assert.Nil(t, err)
+		assert.Equal(t, WritePending, w.Status)
+		assert.Contains(t, w.LastError, "429")
+		assert.Equal(t, outbox.now().Add(30*time.Second), w.NextAttemptAt)
+		writes, _ := store.ListWrites("ROOM")
+		assert.Len(t, writes, 1)
+
+		// Not due yet
+		advance(10 * time.Second)
+		outbox.RetryDue()
+		assert.Empty(t, f.comments["WEB-1"])
+
+		// The estimate goes through, then the comment fails and resumes on its own
+		advance(20 * time.Second)
+		f.mu.Lock()
+		f.failures = []fakeFailure{{}, {status: http.StatusServiceUnavailable}} // The estimate passes
+		f.mu.Unlock()
+		outbox.RetryDue()
+		writes, _ = store.ListWrites("ROOM")
+		if assert.Len(t, writes, 1) {
+			assert.Equal(t, []string{StepEstimate}, writes[0].Done)
+			assert.Equal(t, 2, writes[0].Attempts)
+		}
+
+		// Out of attempts
+		f.fail(1, http.StatusBadGateway, "")
+		advance(time.Hour)
+		outbox.RetryDue()
+		writes, _ = store.ListWrites("ROOM")
+		if assert.Len(t, writes, 1) {
+			assert.Equal(t, WriteFailed, writes[0].Status)
+		}
+		assert.Empty(t, f.comments["WEB-1"])
+
+		// Failed writes are only retried by hand, from the failed step
+		advance(time.Hour)
+		outbox.RetryDue()
+		w, err = outbox.Retry(writes[0].ID, "ROOM")
+		assert.Nil(t, err)
+		assert.Equal(t, WriteDone, w.Status)
+		assert.Len(t, f.comments["WEB-1"], 1)
+		writes, _ = store.ListWrites("ROOM")
+		assert.Empty(t, writes)
+
+		_, err = outbox.Retry(w.ID, "ROOM")
+		assert.Equal(t, ErrWriteNotFound, err)
+
+		// A write being attempted is not attempted again alongside
+		outbox.begin("busy")
+		_, err = outbox.Retry("busy", "ROOM")
+		assert.Equal(t, ErrWriteBusy, err)
+
+		// Of two retries that loaded the same write, only the first claims it
+		w, _ = outbox.Submit(&Write{Room: "ROOM", IssueKey: "WEB-404", Estimate: PointsEstimate(3)})
+		stale, _ := store.GetWrite(w.ID)
+		claimed, _ := store.ClaimWrite(stale, outbox.now(), time.Minute)
+		assert.True(t, claimed)
+		claimed, _ = store.ClaimWrite(&Write{ID: w.ID, NextAttemptAt: w.NextAttemptAt}, outbox.now(), time.Minute)
+		assert.False(t, claimed)
+	})
+}
+
+func TestOutbox_PermanentFailure(t *testing.T) {
+	f := newFakeJira(t, "Cloud")
+	client, _ := NewClient(Config{BaseURL: f.URL, Email: "bot@example.com", APIToken: "secret"})
+	outbox, _, _ := newTestOutbox(client, OutboxConfig{})
+
+	// Missing issues are not retried
+	w, err := outbox.Submit(&Write{IssueKey: "WEB-404", Estimate: PointsEstimate(3)})
+	assert.Nil(t, err)
+	assert.Equal(t, WriteFailed, w.Status)
+	assert.Empty(t, w.Done)
+
+	// Nor is an unreachable Jira given up on
+	unreachable, _ := NewClient(Config{BaseURL: "http://127.0.0.1:1", Flavor: FlavorCloud, Email: "bot@example.com", APIToken: "secret", RetryDelay: time.Millisecond})
+	outbox, _, _ = newTestOutbox(unreachable, OutboxConfig{})
+	w, err = outbox.Submit(&Write{IssueKey: "WEB-1", Estimate: PointsEstimate(3)})
+	assert.Nil(t, err)
+	assert.Equal(t, WritePending, w.Status)
+}
+
+func TestOutbox_Backoff(t *testing.T) {
+	outbox := NewOutboxWithConfig(nil, nil, OutboxConfig{BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+	assert.Equal(t, time.Second, outbox.backoff(1))
+	assert.Equal(t, 2*time.Second, outbox.backoff(2))
+	assert.Equal(t, 8*time.Second, outbox.backoff(4))
+	assert.Equal(t, 10*time.Second, outbox.backoff(5))
+	assert.Equal(t, 10*time.Second, outbox.backoff(50))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	wait := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, wait > 50*time.Second && wait <= time.Minute, wait)
+}
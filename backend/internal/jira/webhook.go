@@ -0,0 +1,69 @@
+package jira
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Webhook events about issues
+const (
+	WebhookIssueUpdated = "jira:issue_updated"
+	WebhookIssueDeleted = "jira:issue_deleted"
+)
+
+// WebhookEvent is an issue event sent by a Jira webhook
+type WebhookEvent struct {
+	Type     string // WebhookIssueUpdated, WebhookIssueDeleted or another event
+	IssueKey string
+	Issue    *Issue // The issue as it is now; nil when deleted
+}
+
+// VerifyWebhook checks a webhook request against the shared secret. Jira
+// signs the body with the secret in the X-Hub-Signature header, as
+// "sha256=<hex HMAC>"; Jira versions without signing can instead be given
+// the secret in the webhook URL, passed here as token.
+func VerifyWebhook(secret string, body []byte, signature, token string) bool {
+	if secret == "" {
+		return false
+	}
+	if signature != "" {
+		method, digest, _ := strings.Cut(signature, "=")
+		sum, err := hex.DecodeString(digest)
+		if !strings.EqualFold(method, "sha256") || err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(sum, mac.Sum(nil))
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// ParseWebhook decodes a webhook request body. The issue is converted like
+// issues fetched from Jira, and what the client cached about it is dropped.
+func (c *Client) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var payload struct {
+		WebhookEvent string    `json:"webhookEvent"`
+		Issue        *apiIssue `json:"issue"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	event := &WebhookEvent{Type: payload.WebhookEvent}
+	if payload.Issue == nil || payload.Issue.Key == "" {
+		return event, nil
+	}
+
+	event.IssueKey = payload.Issue.Key
+	c.Invalidate(event.IssueKey)
+	if event.Type != WebhookIssueDeleted {
+		issue := c.toIssue(payload.Issue)
+		event.Issue = &issue
+	}
+	return event, nil
+}
@@ -0,0 +1,75 @@
+package jira
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"webhookEvent":"jira:issue_updated"}`)
+
+	assert.True(t, VerifyWebhook("secret", body, sign("secret", body), ""))
+	assert.False(t, VerifyWebhook("secret", body, sign("other", body), ""))
+	assert.False(t, VerifyWebhook("secret", []byte(`{}`), sign("secret", body), ""))
+	assert.False(t, VerifyWebhook("secret", body, "sha1=abc", ""))
+	assert.False(t, VerifyWebhook("secret", body, "sha256=not-hex", ""))
+
+	// Jira versions without signing send the secret in the URL
+	assert.True(t, VerifyWebhook("secret", body, "", "secret"))
+	assert.False(t, VerifyWebhook("secret", body, "", "guess"))
+	assert.False(t, VerifyWebhook("secret", body, "", ""))
+	assert.False(t, VerifyWebhook("", body, "", ""), "nothing is accepted without a secret")
+}
+
+func TestClient_ParseWebhook(t *testing.T) {
+	forEachFlavor(t, func(t *testing.T, f *fakeJira, client *Client) {
+		f.addIssue("WEB-1", map[string]interface{}{"summary": "Old"})
+		client.GetIssue(context.Background(), "WEB-1")
+
+		event, err := client.ParseWebhook([]byte(`{
+			"webhookEvent": "jira:issue_updated",
+			"issue": {"key": "WEB-1", "fields": {
+				"summary": "New",
+				"status": {"name": "In Progress"},
+				"customfield_10016": 5,
+				"description": "Pay <now>"
+			}}
+		}`))
+		assert.Nil(t, err)
+		assert.Equal(t, WebhookIssueUpdated, event.Type)
+		assert.Equal(t, "WEB-1", event.IssueKey)
+		if assert.NotNil(t, event.Issue) {
+			assert.Equal(t, "New", event.Issue.Summary)
+			assert.Equal(t, "In Progress", event.Issue.Status)
+			assert.Equal(t, 5.0, *event.Issue.Points)
+			assert.Equal(t, "<p>Pay &lt;now&gt;</p>", event.Issue.Description)
+			assert.Equal(t, f.URL+"/browse/WEB-1", event.Issue.URL)
+		}
+
+		// The cached issue was dropped
+		client.GetIssue(context.Background(), "WEB-1")
+		assert.Len(t, f.received(), 2)
+
+		event, err = client.ParseWebhook([]byte(`{"webhookEvent":"jira:issue_deleted","issue":{"key":"WEB-1","fields":{"summary":"Old"}}}`))
+		assert.Nil(t, err)
+		assert.Equal(t, "WEB-1", event.IssueKey)
+		assert.Nil(t, event.Issue)
+
+		event, err = client.ParseWebhook([]byte(`{"webhookEvent":"sprint_started"}`))
+		assert.Nil(t, err)
+		assert.Empty(t, event.IssueKey)
+		_, err = client.ParseWebhook([]byte(`not json`))
+		assert.NotNil(t, err)
+	})
+}
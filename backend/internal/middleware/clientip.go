@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// platformHeaders maps hosting platforms to the header their edge sets to the client address
+var platformHeaders = map[string]string{
+	"fly":        gin.PlatformFlyIO,
+	"cloudflare": gin.PlatformCloudflare,
+	"appengine":  gin.PlatformGoogleAppEngine,
+}
+
+// ClientIPConfig says whom to believe about a request's client address, which
+// keys rate limits and quotas. With neither set the connection's peer address
+// is used and X-Forwarded-For is ignored, since any client can send it.
+type ClientIPConfig struct {
+	TrustedProxies []string // Proxy IPs or CIDRs whose X-Forwarded-For / X-Real-IP is believed
+	Platform       string   // "fly", "cloudflare", "appengine" or the header set by the platform's edge
+}
+
+// ParseProxies splits a comma-separated TRUSTED_PROXIES value
+func ParseProxies(value string) []string {
+	return ParseOrigins(value)
+}
+
+// ConfigureClientIP sets how the engine resolves Context.ClientIP
+func ConfigureClientIP(r *gin.Engine, config ClientIPConfig) error {
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	r.TrustedPlatform = ""
+	if platform := strings.TrimSpace(config.Platform); platform != "" {
+		if header, ok := platformHeaders[strings.ToLower(platform)]; ok {
+			platform = header
+		}
+		r.TrustedPlatform = platform
+	}
+	return nil
+}
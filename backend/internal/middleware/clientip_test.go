@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigureClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	clientIP := func(config ClientIPConfig, remoteAddr string, headers map[string]string) string {
+		r := gin.New()
+		assert.Nil(t, ConfigureClientIP(r, config))
+		var ip string
+		r.GET("/", func(c *gin.Context) { ip = c.ClientIP() })
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return ip
+	}
+	forwarded := map[string]string{"X-Forwarded-For": "203.0.113.7"}
+
+	// Forwarded headers are ignored unless they come from a trusted proxy
+	assert.Equal(t, "198.51.100.1", clientIP(ClientIPConfig{}, "198.51.100.1:4000", forwarded))
+	proxies := ClientIPConfig{TrustedProxies: ParseProxies("10.0.0.0/8, 192.168.1.1")}
+	assert.Equal(t, "203.0.113.7", clientIP(proxies, "10.1.2.3:4000", forwarded))
+	assert.Equal(t, "198.51.100.1", clientIP(proxies, "198.51.100.1:4000", forwarded))
+
+	// Platforms report the client in their own header
+	fly := ClientIPConfig{Platform: "fly"}
+	assert.Equal(t, "203.0.113.9", clientIP(fly, "172.16.0.2:4000", map[string]string{"Fly-Client-IP": "203.0.113.9"}))
+	custom := ClientIPConfig{Platform: "X-Client-Address"}
+	assert.Equal(t, "203.0.113.5", clientIP(custom, "172.16.0.2:4000", map[string]string{"X-Client-Address": "203.0.113.5"}))
+
+	assert.NotNil(t, ConfigureClientIP(gin.New(), ClientIPConfig{TrustedProxies: []string{"not-an-ip"}}))
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import "sync"
+
+// ConnLimiter caps the number of concurrent long-lived connections per client IP
+type ConnLimiter struct {
+	max    int
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+// NewConnLimiter creates a limiter allowing max concurrent connections per IP (0 = unlimited)
+func NewConnLimiter(max int) *ConnLimiter {
+	return &ConnLimiter{
+		max:    max,
+		counts: make(map[string]int),
+	}
+}
+
+// Acquire reserves a connection slot for the IP, returning false if the cap is reached
+func (l *ConnLimiter) Acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.counts[ip] >= l.max {
+		return false
+	}
+	l.counts[ip]++
+	return true
+}
+
+// Release frees a slot previously reserved with Acquire
+func (l *ConnLimiter) Release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.counts[ip] <= 1 {
+		delete(l.counts, ip)
+		return
+	}
+	l.counts[ip]--
+}
+
+// Count returns the number of open connections for the IP
+func (l *ConnLimiter) Count(ip string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[ip]
+}
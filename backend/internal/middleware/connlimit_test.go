@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnLimiter(t *testing.T) {
+	limiter := NewConnLimiter(2)
+
+	assert.True(t, limiter.Acquire("1.1.1.1"))
+	assert.True(t, limiter.Acquire("1.1.1.1"))
+	assert.False(t, limiter.Acquire("1.1.1.1"))
+	assert.True(t, limiter.Acquire("2.2.2.2")) // Other IPs are independent
+
+	limiter.Release("1.1.1.1")
+	assert.Equal(t, 1, limiter.Count("1.1.1.1"))
+	assert.True(t, limiter.Acquire("1.1.1.1"))
+
+	limiter.Release("2.2.2.2")
+	assert.Equal(t, 0, limiter.Count("2.2.2.2"))
+
+	unlimited := NewConnLimiter(0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.Acquire("1.1.1.1"))
+	}
+}
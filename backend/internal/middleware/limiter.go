@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultLimiterCapacity is how many clients a memory limiter tracks before
+// forgetting the least recently seen
+const DefaultLimiterCapacity = 100_000
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is how long an empty bucket takes to refill completely
+func (l Limit) Window() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Bucket size
+	Remaining  int           // Requests left right now
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next request is allowed, zero if allowed
+}
+
+// Limiter decides whether the client identified by key may make another request
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// decide builds the decision for a bucket left with the given tokens
+func decide(limit Limit, allowed bool, tokens float64) Decision {
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+	}
+	if limit.Rate > 0 {
+		d.Reset = time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second))
+		if !allowed {
+			d.RetryAfter = time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+		}
+	}
+	return d
+}
+
+// bucket is one client's token bucket in a MemoryLimiter
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+	window  time.Duration // Refill time of the bucket's limit
+}
+
+// MemoryLimiter keeps token buckets in process. Buckets that have refilled
+// completely are equivalent to new ones and are dropped, and at most capacity
+// buckets are kept, evicting the least recently used.
+type MemoryLimiter struct {
+	capacity int
+	buckets  map[string]*list.Element
+	lru      *list.List // Front is most recently used
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+// NewMemoryLimiter creates an in-process limiter tracking up to capacity clients
+func NewMemoryLimiter(capacity int) *MemoryLimiter {
+	if capacity <= 0 {
+		capacity = DefaultLimiterCapacity
+	}
+	return &MemoryLimiter{
+		capacity: capacity,
+		buckets:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Allow takes a token from the key's bucket if one is left
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	var b *bucket
+	if el, ok := m.buckets[key]; ok {
+		b = el.Value.(*bucket)
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+		m.lru.MoveToFront(el)
+	} else {
+		b = &bucket{key: key, tokens: float64(limit.Burst)}
+		m.buckets[key] = m.lru.PushFront(b)
+	}
+	b.updated = now
+	b.window = limit.Window()
+	m.evict(now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return decide(limit, allowed, b.tokens), nil
+}
+
+// evict drops full buckets from the back of the list, then the least recently
+// used ones while over capacity; callers must hold the lock
+func (m *MemoryLimiter) evict(now time.Time) {
+	for el := m.lru.Back(); el != nil; {
+		b := el.Value.(*bucket)
+		full := b.window > 0 && now.Sub(b.updated) >= b.window
+		if !full && m.lru.Len() <= m.capacity {
+			break
+		}
+		prev := el.Prev()
+		m.lru.Remove(el)
+		delete(m.buckets, b.key)
+		el = prev
+	}
+}
+
+// Len returns the number of buckets held
+func (m *MemoryLimiter) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// forEachLimiter runs the test against every limiter with a controllable
+// clock; Redis is stood in for by an in-process miniredis server
+func forEachLimiter(t *testing.T, test func(t *testing.T, l Limiter, advance func(time.Duration))) {
+	t.Run("memory", func(t *testing.T) {
+		m := NewMemoryLimiter(10)
+		now := time.Now()
+		m.now = func() time.Time { return now }
+		test(t, m, func(d time.Duration) { now = now.Add(d) })
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		r := NewRedisLimiter(client)
+		now := time.Now()
+		r.now = func() time.Time { return now }
+		test(t, r, func(d time.Duration) {
+			now = now.Add(d)
+			server.FastForward(d)
+		})
+	})
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	forEachLimiter(t, func(t *testing.T, l Limiter, advance func(time.Duration)) {
+		ctx := context.Background()
+		limit := Limit{Rate: 2, Burst: 2}
+
+		d, err := l.Allow(ctx, "a", limit)
+		assert.Nil(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 2, d.Limit)
+		assert.Equal(t, 1, d.Remaining)
+		assert.Equal(t, 500*time.Millisecond, d.Reset)
+
+		d, _ = l.Allow(ctx, "a", limit)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 0, d.Remaining)
+
+		d, _ = l.Allow(ctx, "a", limit)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+		// Other keys have their own budget
+		d, _ = l.Allow(ctx, "b", limit)
+		assert.True(t, d.Allowed)
+
+		// Tokens refill at the rate
+		advance(500 * time.Millisecond)
+		d, _ = l.Allow(ctx, "a", limit)
+		assert.True(t, d.Allowed)
+		d, _ = l.Allow(ctx, "a", limit)
+		assert.False(t, d.Allowed)
+
+		// An idle bucket refills to the burst and no further
+		advance(time.Hour)
+		d, _ = l.Allow(ctx, "a", limit)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, 1, d.Remaining)
+	})
+}
+
+func TestMemoryLimiter_Eviction(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	m := NewMemoryLimiter(2)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// Least recently used buckets go first when over capacity
+	m.Allow(ctx, "a", limit)
+	m.Allow(ctx, "a", limit)
+	m.Allow(ctx, "b", limit)
+	m.Allow(ctx, "c", limit)
+	assert.Equal(t, 2, m.Len())
+	d, _ := m.Allow(ctx, "a", limit)
+	assert.True(t, d.Allowed, "evicted bucket starts full")
+
+	// Buckets that have refilled are dropped
+	now = now.Add(3 * time.Second)
+	m.Allow(ctx, "d", limit)
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestRedisLimiter_ExpiresBuckets(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	r := NewRedisLimiter(client)
+
+	_, err := r.Allow(context.Background(), "api:1.2.3.4", Limit{Rate: 1, Burst: 5})
+	assert.Nil(t, err)
+	assert.True(t, server.Exists(rateLimitKeyPrefix+"api:1.2.3.4"))
+	server.FastForward(7 * time.Second)
+	assert.False(t, server.Exists(rateLimitKeyPrefix+"api:1.2.3.4"))
+}
+
+// failingLimiter stands in for an unreachable shared store
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, Limit) (Decision, error) {
+	return Decision{}, errors.New("store unavailable")
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(limiter Limiter, policy Policy) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.GET("/", RateLimit(limiter, policy), func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	limiter := NewMemoryLimiter(0)
+	create := Policy{Name: "create", Limit: Limit{Rate: 0.1, Burst: 2}}
+
+	w := serve(limiter, create)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2;w=20", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", w.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	serve(limiter, create)
+	w = serve(limiter, create)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// Policies sharing a limiter keep separate budgets
+	w = serve(limiter, Policy{Name: "lookup", Limit: Limit{Rate: 1, Burst: 2}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A failing store lets requests through
+	w = serve(failingLimiter{}, create)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
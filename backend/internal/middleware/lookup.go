@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LookupGuardConfig configures protection of room lookup endpoints against code enumeration
+type LookupGuardConfig struct {
+	RequestsPerSecond   float64       // Lookup rate per IP
+	Burst               int           // Lookup burst per IP
+	FreeFailures        int           // Failed lookups tolerated before backoff starts
+	BaseBackoff         time.Duration // First backoff, doubled on each further failure
+	MaxBackoff          time.Duration
+	FailureWindow       time.Duration // Failures older than this are forgotten
+	SuspiciousThreshold int           // Failed lookups within the window that get logged
+	Limiter             Limiter       // Shared limiter for the lookup rate, in process when nil
+}
+
+// DefaultLookupGuardConfig returns the default lookup protection settings
+func DefaultLookupGuardConfig() LookupGuardConfig {
+	return LookupGuardConfig{
+		RequestsPerSecond:   1,
+		Burst:               10,
+		FreeFailures:        5,
+		BaseBackoff:         time.Second,
+		MaxBackoff:          5 * time.Minute,
+		FailureWindow:       15 * time.Minute,
+		SuspiciousThreshold: 20,
+	}
+}
+
+// lookupFailures tracks failed lookups of one client
+type lookupFailures struct {
+	count        int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// LookupGuard rate limits room lookups and backs off clients that keep asking
+// for rooms that do not exist
+type LookupGuard struct {
+	config   LookupGuardConfig
+	limiter  Limiter
+	policy   Policy
+	failures map[string]*lookupFailures
+	mu       sync.Mutex
+	now      func() time.Time
+	done     chan struct{}
+}
+
+// NewLookupGuard creates a lookup guard. Zero durations take their default values.
+func NewLookupGuard(config LookupGuardConfig) *LookupGuard {
+	defaults := DefaultLookupGuardConfig()
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = defaults.BaseBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = defaults.FailureWindow
+	}
+
+	if config.Limiter == nil {
+		config.Limiter = NewMemoryLimiter(DefaultLimiterCapacity)
+	}
+
+	g := &LookupGuard{
+		config:   config,
+		limiter:  config.Limiter,
+		policy:   Policy{Name: "lookup", Limit: Limit{Rate: config.RequestsPerSecond, Burst: config.Burst}},
+		failures: make(map[string]*lookupFailures),
+		now:      time.Now,
+		done:     make(chan struct{}),
+	}
+
+	go g.cleanupLoop()
+
+	return g
+}
+
+// Middleware returns the Gin middleware enforcing the guard. A 404 response
+// from the wrapped handler counts as a failed lookup.
+func (g *LookupGuard) Middleware() gin.HandlerFunc {
+	return g.middleware(true)
+}
+
+// BackoffMiddleware enforces only the failure backoff, for routes that have
+// their own rate limit such as WebSocket joins
+func (g *LookupGuard) BackoffMiddleware() gin.HandlerFunc {
+	return g.middleware(false)
+}
+
+func (g *LookupGuard) middleware(limitRate bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+
+		if wait := g.blockedFor(ip); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many failed lookups",
+			})
+			return
+		}
+
+		if limitRate && !AllowRequest(c, g.limiter, g.policy) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many requests",
+			})
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusNotFound {
+			g.recordFailure(ip, c.Request.URL.Path)
+		}
+	}
+}
+
+// blockedFor returns how long the IP must wait before its next lookup
+func (g *LookupGuard) blockedFor(ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.failures[ip]
+	if !ok {
+		return 0
+	}
+	return f.blockedUntil.Sub(g.now())
+}
+
+// recordFailure counts a failed lookup and extends the IP's backoff
+func (g *LookupGuard) recordFailure(ip, path string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	f, ok := g.failures[ip]
+	if !ok || now.Sub(f.lastFailure) > g.config.FailureWindow {
+		f = &lookupFailures{}
+		g.failures[ip] = f
+	}
+	f.count++
+	f.lastFailure = now
+
+	if excess := f.count - g.config.FreeFailures; excess > 0 {
+		backoff := g.config.BaseBackoff << uint(min(excess-1, 30))
+		if backoff > g.config.MaxBackoff || backoff <= 0 {
+			backoff = g.config.MaxBackoff
+		}
+		f.blockedUntil = now.Add(backoff)
+	}
+
+	if g.config.SuspiciousThreshold > 0 && f.count%g.config.SuspiciousThreshold == 0 {
+		log.Printf("⚠️  Suspicious room lookups from %s: %d failed lookups within %s (last: %s)",
+			ip, f.count, g.config.FailureWindow, path)
+	}
+}
+
+// Stop stops the cleanup routine
+func (g *LookupGuard) Stop() {
+	close(g.done)
+}
+
+// cleanupLoop periodically forgets clients whose failures are outside the window
+func (g *LookupGuard) cleanupLoop() {
+	ticker := time.NewTicker(g.config.FailureWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.cleanup()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+func (g *LookupGuard) cleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for ip, f := range g.failures {
+		if now.Sub(f.lastFailure) > g.config.FailureWindow && now.After(f.blockedUntil) {
+			delete(g.failures, ip)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupGuard_FailureBackoff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	guard := NewLookupGuard(LookupGuardConfig{
+		RequestsPerSecond: 100,
+		Burst:             100,
+		FreeFailures:      2,
+		BaseBackoff:       time.Minute,
+		MaxBackoff:        time.Hour,
+		FailureWindow:     time.Hour,
+	})
+	defer guard.Stop()
+	now := time.Now()
+	guard.now = func() time.Time { return now }
+
+	r := gin.New()
+	r.GET("/rooms/:code", guard.Middleware(), func(c *gin.Context) {
+		if c.Param("code") == "LIVE" {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusNotFound)
+	})
+
+	lookup := func(code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/rooms/"+code, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Free failures pass through
+	assert.Equal(t, http.StatusNotFound, lookup("AAAA").Code)
+	assert.Equal(t, http.StatusNotFound, lookup("BBBB").Code)
+	assert.Equal(t, http.StatusOK, lookup("LIVE").Code)
+
+	// The third failure starts a one minute backoff, blocking even valid codes
+	assert.Equal(t, http.StatusNotFound, lookup("CCCC").Code)
+	w := lookup("LIVE")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// The next failure doubles the backoff
+	now = now.Add(time.Minute + time.Second)
+	assert.Equal(t, http.StatusNotFound, lookup("DDDD").Code)
+	assert.Equal(t, "120", lookup("LIVE").Header().Get("Retry-After"))
+
+	// Failures are forgotten after the window
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, http.StatusOK, lookup("LIVE").Code)
+	assert.Equal(t, http.StatusNotFound, lookup("EEEE").Code)
+	assert.Equal(t, http.StatusOK, lookup("LIVE").Code)
+}
+
+func TestLookupGuard_RateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	guard := NewLookupGuard(LookupGuardConfig{RequestsPerSecond: 1, Burst: 2})
+	defer guard.Stop()
+
+	r := gin.New()
+	r.GET("/check", guard.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	codes := make([]int, 3)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/check", nil)
+		r.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestLookupGuard_BackoffMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	guard := NewLookupGuard(LookupGuardConfig{RequestsPerSecond: 1, Burst: 1, FreeFailures: 1})
+	defer guard.Stop()
+
+	r := gin.New()
+	r.GET("/ws", guard.BackoffMiddleware(), func(c *gin.Context) {
+		if c.Query("room") == "LIVE" {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusNotFound)
+	})
+
+	join := func(code string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ws?room="+code, nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The lookup rate does not apply, so reconnects are not throttled
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, join("LIVE"))
+	}
+
+	// Unknown rooms still back off
+	assert.Equal(t, http.StatusNotFound, join("AAAA"))
+	assert.Equal(t, http.StatusNotFound, join("BBBB"))
+	assert.Equal(t, http.StatusTooManyRequests, join("LIVE"))
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// OriginPolicy decides which browser origins may call the API and open WebSockets.
+// Entries are exact origins ("https://poker.example.com"), wildcard subdomains
+// ("https://*.pages.dev") or "*" to allow everything.
+type OriginPolicy struct {
+	allowAll  bool
+	exact     map[string]bool
+	wildcards []originPattern
+}
+
+// originPattern matches any subdomain of host for the given scheme and port
+type originPattern struct {
+	scheme string
+	suffix string // ".pages.dev"
+	port   string
+}
+
+// ParseOrigins splits a comma-separated ALLOWED_ORIGINS value
+func ParseOrigins(value string) []string {
+	var origins []string
+	for _, o := range strings.Split(value, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// NewOriginPolicy creates a policy from a list of allowed origins
+func NewOriginPolicy(origins []string) *OriginPolicy {
+	p := &OriginPolicy{exact: make(map[string]bool)}
+
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.ToLower(origin), "/")
+		if origin == "*" {
+			p.allowAll = true
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			log.Printf("Ignoring invalid allowed origin %q", origin)
+			continue
+		}
+
+		if strings.HasPrefix(u.Hostname(), "*.") {
+			p.wildcards = append(p.wildcards, originPattern{
+				scheme: u.Scheme,
+				suffix: strings.TrimPrefix(u.Hostname(), "*"),
+				port:   u.Port(),
+			})
+			continue
+		}
+		p.exact[u.Scheme+"://"+u.Host] = true
+	}
+
+	return p
+}
+
+// AllowsAll returns true if every origin is accepted
+func (p *OriginPolicy) AllowsAll() bool {
+	return p.allowAll
+}
+
+// Allowed returns true if the origin matches the policy
+func (p *OriginPolicy) Allowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	if p.exact[u.Scheme+"://"+u.Host] {
+		return true
+	}
+
+	host := u.Hostname()
+	for _, w := range p.wildcards {
+		if u.Scheme == w.scheme && u.Port() == w.port &&
+			strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin function. Requests without an
+// Origin header (non-browser clients) and same-origin requests are accepted.
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || p.Allowed(origin) {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	log.Printf("Rejected WebSocket upgrade from origin %q (remote %s)", origin, r.RemoteAddr)
+	return false
+}
+
+// CORSMiddleware creates the CORS middleware for the policy. A "*" policy answers
+// with a literal wildcard and no credentials; only listed origins get credentials.
+func CORSMiddleware(policy *OriginPolicy) gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:    []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:    []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
+		AllowWebSockets: true,
+		MaxAge:          12 * time.Hour,
+	}
+
+	if policy.AllowsAll() {
+		config.AllowAllOrigins = true
+		return cors.New(config)
+	}
+
+	config.AllowCredentials = true
+	config.AllowOriginFunc = func(origin string) bool {
+		if policy.Allowed(origin) {
+			return true
+		}
+		log.Printf("Rejected CORS request from origin %q", origin)
+		return false
+	}
+	return cors.New(config)
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginPolicy_Allowed(t *testing.T) {
+	policy := NewOriginPolicy(ParseOrigins("https://scrum-poker.pages.dev, https://*.pages.dev,http://localhost:5173/"))
+
+	assert.True(t, policy.Allowed("https://scrum-poker.pages.dev"))
+	assert.True(t, policy.Allowed("https://preview-123.scrum-poker.pages.dev"))
+	assert.True(t, policy.Allowed("http://localhost:5173"))
+	assert.True(t, policy.Allowed("HTTPS://Scrum-Poker.Pages.Dev"))
+
+	assert.False(t, policy.Allowed("https://pages.dev"))
+	assert.False(t, policy.Allowed("http://preview.pages.dev"))       // Scheme mismatch
+	assert.False(t, policy.Allowed("https://evil.dev"))               // Not listed
+	assert.False(t, policy.Allowed("https://pages.dev.evil.com"))     // Suffix trick
+	assert.False(t, policy.Allowed("http://localhost:3000"))          // Port mismatch
+	assert.False(t, policy.Allowed("https://preview.pages.dev:8443")) // Port mismatch
+	assert.False(t, policy.Allowed("null"))
+
+	all := NewOriginPolicy([]string{"*"})
+	assert.True(t, all.AllowsAll())
+	assert.True(t, all.Allowed("https://anything.example"))
+}
+
+func TestOriginPolicy_CheckOrigin(t *testing.T) {
+	policy := NewOriginPolicy([]string{"https://poker.example.com"})
+
+	req := httptest.NewRequest("GET", "http://api.example.com/ws", nil)
+	assert.True(t, policy.CheckOrigin(req)) // Non-browser client
+
+	req.Header.Set("Origin", "https://poker.example.com")
+	assert.True(t, policy.CheckOrigin(req))
+
+	req.Header.Set("Origin", "https://api.example.com")
+	assert.True(t, policy.CheckOrigin(req)) // Same origin
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	assert.False(t, policy.CheckOrigin(req))
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CORSMiddleware(NewOriginPolicy([]string{"https://*.pages.dev"})))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(origin string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("Origin", origin)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("https://preview.pages.dev")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://preview.pages.dev", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = send("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCORSMiddleware_AllowAll(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CORSMiddleware(NewOriginPolicy([]string{"*"})))
+	r.GET("/test", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/test", nil)
+	req.Header.Set("Origin", "https://anything.example")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaWindow counts the uses of one client within the current window
+type quotaWindow struct {
+	count int
+	start time.Time
+}
+
+// Quota limits how many requests creating something a client IP may make
+// per fixed window, e.g. room creations per hour
+type Quota struct {
+	limit   int
+	window  time.Duration
+	windows map[string]*quotaWindow
+	mu      sync.Mutex
+	now     func() time.Time
+	done    chan struct{}
+}
+
+// NewQuota creates a quota of limit uses per window and IP (0 = unlimited)
+func NewQuota(limit int, window time.Duration) *Quota {
+	if window <= 0 {
+		window = time.Hour
+	}
+	q := &Quota{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*quotaWindow),
+		now:     time.Now,
+		done:    make(chan struct{}),
+	}
+
+	if limit > 0 {
+		go q.cleanupLoop()
+	}
+
+	return q
+}
+
+// Reserve takes one use for the IP if it has any left. Otherwise it returns
+// false and when the IP's window resets.
+func (q *Quota) Reserve(ip string) (bool, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	w := q.current(ip)
+	if w.count >= q.limit {
+		return false, w.start.Add(q.window).Sub(q.now())
+	}
+	w.count++
+	return true, 0
+}
+
+// Release gives back a use taken by Reserve, unless its window has elapsed
+func (q *Quota) Release(ip string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if w, ok := q.windows[ip]; ok && q.now().Sub(w.start) < q.window && w.count > 0 {
+		w.count--
+	}
+}
+
+// current returns the IP's window, starting a new one if it has elapsed; callers must hold the lock
+func (q *Quota) current(ip string) *quotaWindow {
+	now := q.now()
+	w, ok := q.windows[ip]
+	if !ok || now.Sub(w.start) >= q.window {
+		w = &quotaWindow{start: now}
+		q.windows[ip] = w
+	}
+	return w
+}
+
+// Middleware returns the Gin middleware enforcing the quota. A use is
+// reserved before the handler runs, so concurrent requests cannot exceed the
+// quota, and given back unless the response is 201 Created. Requests that
+// succeed without creating anything, such as updating a reserved room, do
+// not count.
+func (q *Quota) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if q.limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ip := c.ClientIP()
+		if ok, reset := q.Reserve(ip); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "quota exceeded",
+			})
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() != http.StatusCreated {
+			q.Release(ip)
+		}
+	}
+}
+
+// Stop stops the cleanup routine
+func (q *Quota) Stop() {
+	close(q.done)
+}
+
+// cleanupLoop periodically forgets clients whose window has elapsed
+func (q *Quota) cleanupLoop() {
+	ticker := time.NewTicker(q.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.cleanup()
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *Quota) cleanup() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	for ip, w := range q.windows {
+		if now.Sub(w.start) >= q.window {
+			delete(q.windows, ip)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	quota := NewQuota(2, time.Hour)
+	defer quota.Stop()
+	now := time.Now()
+	quota.now = func() time.Time { return now }
+
+	r := gin.New()
+	r.POST("/rooms", quota.Middleware(), func(c *gin.Context) {
+		if c.Query("fail") != "" {
+			c.Status(http.StatusServiceUnavailable)
+			return
+		}
+		if c.Query("update") != "" {
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	create := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/rooms"+query, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Failed requests and updates do not use up the quota
+	assert.Equal(t, http.StatusServiceUnavailable, create("?fail=1").Code)
+	assert.Equal(t, http.StatusOK, create("?update=1").Code)
+	assert.Equal(t, http.StatusCreated, create("").Code)
+	assert.Equal(t, http.StatusCreated, create("").Code)
+
+	now = now.Add(15 * time.Minute)
+	w := create("")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2700", w.Header().Get("Retry-After"))
+
+	// A new window restores the quota
+	now = now.Add(45 * time.Minute)
+	assert.Equal(t, http.StatusCreated, create("").Code)
+}
+
+func TestQuota_Concurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	quota := NewQuota(2, time.Hour)
+	defer quota.Stop()
+
+	// Requests in flight hold their use, so later ones are refused at once
+	started := make(chan struct{}, 5)
+	finish := make(chan struct{})
+	r := gin.New()
+	r.POST("/rooms", quota.Middleware(), func(c *gin.Context) {
+		started <- struct{}{}
+		<-finish
+		c.Status(http.StatusCreated)
+	})
+
+	codes := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/rooms", nil)
+			r.ServeHTTP(w, req)
+			codes <- w.Code
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusTooManyRequests, <-codes)
+	}
+	<-started
+	<-started
+	close(finish)
+	assert.Equal(t, http.StatusCreated, <-codes)
+	assert.Equal(t, http.StatusCreated, <-codes)
+}
+
+func TestQuota_Unlimited(t *testing.T) {
+	quota := NewQuota(0, time.Hour)
+	defer quota.Stop()
+	r := gin.New()
+	r.POST("/rooms", quota.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/rooms", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+}
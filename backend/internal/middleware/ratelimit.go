@@ -1,66 +1,26 @@
 package middleware
 
 import (
+	"log"
+	"math"
 	"net/http"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-type IPRateLimiter struct {
-	ips map[string]*rate.Limiter
-	mu  sync.RWMutex
-	r   rate.Limit
-	b   int
+// Policy is a named limit for a group of routes (e.g. room creation or
+// lookups). Every policy keeps its own budget per client IP.
+type Policy struct {
+	Name string
+	Limit
 }
 
-// NewIPRateLimiter creates a new rate limiter that allows r requests per second with a burst of b
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	i := &IPRateLimiter{
-		ips: make(map[string]*rate.Limiter),
-		r:   r,
-		b:   b,
-	}
-
-	// Launch a cleanup routine to remove old entries
-	go i.cleanupLoop()
-
-	return i
-}
-
-func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
-
-	limiter, exists := i.ips[ip]
-	if !exists {
-		limiter = rate.NewLimiter(i.r, i.b)
-		i.ips[ip] = limiter
-	}
-
-	return limiter
-}
-
-// cleanupLoop periodically clears the map to avoid memory leaks
-// In a production system, you might want to use a LRU cache or Redis
-func (i *IPRateLimiter) cleanupLoop() {
-	ticker := time.NewTicker(10 * time.Minute)
-	for range ticker.C {
-		i.mu.Lock()
-		i.ips = make(map[string]*rate.Limiter)
-		i.mu.Unlock()
-	}
-}
-
-// RateLimitMiddleware creates a Gin middleware for rate limiting
-func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
-	limiter := NewIPRateLimiter(rate.Limit(requestsPerSecond), burst)
-
+// RateLimit returns a Gin middleware enforcing the policy per client IP
+func RateLimit(limiter Limiter, policy Policy) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		if !limiter.GetLimiter(ip).Allow() {
+		if !AllowRequest(c, limiter, policy) {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"error": "too many requests",
 			})
@@ -69,3 +29,27 @@ func RateLimitMiddleware(requestsPerSecond float64, burst int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AllowRequest checks the policy for the request's client IP and sets the
+// RateLimit-* headers, plus Retry-After when the request is refused. The
+// caller writes the response. Requests are allowed if the limiter fails.
+func AllowRequest(c *gin.Context, limiter Limiter, policy Policy) bool {
+	d, err := limiter.Allow(c.Request.Context(), policy.Name+":"+c.ClientIP(), policy.Limit)
+	if err != nil {
+		log.Printf("Rate limiter error (%s), allowing request: %v", policy.Name, err)
+		return true
+	}
+
+	c.Header("RateLimit-Policy", strconv.Itoa(policy.Burst)+";w="+strconv.Itoa(ceilSeconds(policy.Window())))
+	c.Header("RateLimit-Limit", strconv.Itoa(d.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	if !d.Allowed {
+		c.Header("Retry-After", strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+	}
+	return d.Allowed
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rateLimitKeyPrefix namespaces token buckets in Redis
+const rateLimitKeyPrefix = "poker:ratelimit:"
+
+// tokenBucketScript refills and takes from a bucket atomically. Time comes
+// from the caller, in milliseconds, so the script stays deterministic.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate / 1000)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated", tostring(now))
+redis.call("PEXPIRE", KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter keeps token buckets in Redis, so all instances share each
+// client's budget. Buckets expire once they would have refilled.
+type RedisLimiter struct {
+	client redis.Scripter
+	now    func() time.Time
+}
+
+// NewRedisLimiter creates a limiter storing its buckets through the client
+func NewRedisLimiter(client redis.Scripter) *RedisLimiter {
+	return &RedisLimiter{client: client, now: time.Now}
+}
+
+// Allow takes a token from the key's bucket if one is left
+func (r *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	ttl := limit.Window().Milliseconds() + 1000
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{rateLimitKeyPrefix + key},
+		limit.Rate, limit.Burst, r.now().UnixMilli(), ttl).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	allowed, _ := res[0].(int64)
+	tokensText, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensText, 64)
+	if err != nil {
+		return Decision{}, err
+	}
+	return decide(limit, allowed == 1, tokens), nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoomOwnerFunc reports which instance owns a room and whether it is this one
+type RoomOwnerFunc func(code string) (owner string, local bool)
+
+// RoomRouter sends requests for rooms owned by another instance to that
+// instance. The room is taken from the :code path parameter or the room query
+// parameter (WebSocket upgrades); other requests pass through.
+//
+// With flyReplay set, the response carries a Fly-Replay header so the Fly
+// proxy replays the request on the owning machine. Otherwise the client gets
+// 421 Misdirected Request with the owner in X-Room-Owner, for a load balancer
+// or client to act on.
+func RoomRouter(owner RoomOwnerFunc, flyReplay bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := c.Param("code")
+		if code == "" {
+			code = c.Query("room")
+		}
+		if code == "" {
+			c.Next()
+			return
+		}
+
+		instance, local := owner(code)
+		if local {
+			c.Next()
+			return
+		}
+
+		c.Header("X-Room-Owner", instance)
+		if flyReplay {
+			c.Header("Fly-Replay", "instance="+instance)
+		}
+		c.AbortWithStatusJSON(http.StatusMisdirectedRequest, gin.H{
+			"error": "room is served by another instance",
+		})
+	}
+}
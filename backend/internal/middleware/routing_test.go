@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	owners := map[string]string{"REMOTE": "machine-b"}
+	owner := func(code string) (string, bool) {
+		if instance, ok := owners[code]; ok {
+			return instance, false
+		}
+		return "machine-a", true
+	}
+
+	for _, flyReplay := range []bool{false, true} {
+		r := gin.New()
+		r.Use(RoomRouter(owner, flyReplay))
+		handler := func(c *gin.Context) { c.Status(http.StatusOK) }
+		r.GET("/rooms/:code", handler)
+		r.GET("/ws", handler)
+		r.GET("/health", handler)
+
+		for path, want := range map[string]int{
+			"/rooms/LOCAL":    http.StatusOK,
+			"/rooms/REMOTE":   http.StatusMisdirectedRequest,
+			"/ws?room=REMOTE": http.StatusMisdirectedRequest,
+			"/ws?room=LOCAL":  http.StatusOK,
+			"/health":         http.StatusOK,
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			r.ServeHTTP(w, req)
+			assert.Equal(t, want, w.Code, path)
+
+			if want == http.StatusMisdirectedRequest {
+				assert.Equal(t, "machine-b", w.Header().Get("X-Room-Owner"))
+				if flyReplay {
+					assert.Equal(t, "instance=machine-b", w.Header().Get("Fly-Replay"))
+				} else {
+					assert.Empty(t, w.Header().Get("Fly-Replay"))
+				}
+			}
+		}
+	}
+}
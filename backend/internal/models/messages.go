@@ -1,5 +1,15 @@
 package models
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
 // MessageType represents the type of WebSocket message
 type MessageType string
 
@@ -11,6 +21,12 @@ const (
 	MsgTypeReset      MessageType = "reset"
 	MsgTypeStartTimer MessageType = "start_timer"
 	MsgTypeStopTimer  MessageType = "stop_timer"
+	MsgTypeLoadIssues MessageType = "load_issues"
+
+	// Host-only token management
+	MsgTypeRotateHostToken MessageType = "rotate_host_token"
+	MsgTypeCreateInvite    MessageType = "create_facilitator_invite"
+	MsgTypeRevokeInvite    MessageType = "revoke_facilitator_invite"
 
 	// Server -> Client messages
 	MsgTypeSync       MessageType = "sync"
@@ -23,12 +39,58 @@ const (
 	MsgTypeTimerSync  MessageType = "timer_sync"
 	MsgTypeTimerEnd   MessageType = "timer_end"
 	MsgTypeSetIssue   MessageType = "set_issue"
+	MsgTypeAck        MessageType = "ack"
+	MsgTypeWelcome    MessageType = "welcome"
+	MsgTypeToken      MessageType = "token_issued"
 )
 
-// JiraIssue represents an issue being estimated
+// JiraIssue represents an issue being estimated. Description and acceptance
+// criteria are HTML rendered by the server from Jira, never taken from clients.
 type JiraIssue struct {
-	Key     string `json:"key"`
-	Summary string `json:"summary"`
+	Key                string   `json:"key"`
+	Summary            string   `json:"summary"`
+	Type               string   `json:"type,omitempty"`
+	Priority           string   `json:"priority,omitempty"`
+	Status             string   `json:"status,omitempty"`
+	Labels             []string `json:"labels,omitempty"`
+	Points             *float64 `json:"points,omitempty"` // Story points currently in Jira
+	Description        string   `json:"description,omitempty"`
+	AcceptanceCriteria string   `json:"acceptanceCriteria,omitempty"`
+	URL                string   `json:"url,omitempty"` // Browse page of the issue
+}
+
+// WithoutMarkup returns a copy of the issue without its HTML fields and its
+// link, for issues that did not come from the server's Jira client. Clients
+// render both as is, so a forged link could run script when followed.
+func (i JiraIssue) WithoutMarkup() JiraIssue {
+	i.Description = ""
+	i.AcceptanceCriteria = ""
+	i.URL = ""
+	return i
+}
+
+// JiraActions are done in Jira when an estimate is saved, besides writing
+// the estimate itself
+type JiraActions struct {
+	Comment    bool   `json:"comment,omitempty"`    // Comment with the vote distribution and participants
+	Label      string `json:"label,omitempty"`      // Label to add, e.g. "estimated"
+	Transition string `json:"transition,omitempty"` // Status to move the issue to, e.g. "Ready"
+}
+
+// Any reports whether any action is configured
+func (a JiraActions) Any() bool {
+	return a.Comment || a.Label != "" || a.Transition != ""
+}
+
+// Validate checks the label is a valid Jira label and the names fit
+func (a JiraActions) Validate() error {
+	if strings.ContainsAny(a.Label, " \t\n") {
+		return errors.New("jira labels cannot contain spaces")
+	}
+	if len(a.Label) > 255 || len(a.Transition) > 255 {
+		return errors.New("jira label and transition must be at most 255 characters")
+	}
+	return nil
 }
 
 // VotingScaleType represents different voting scale presets
@@ -43,9 +105,73 @@ const (
 
 // VotingScale represents a voting scale configuration
 type VotingScale struct {
-	Type   VotingScaleType `json:"type"`
-	Name   string          `json:"name"`
-	Values []string        `json:"values"`
+	Type   VotingScaleType    `json:"type"`
+	Name   string             `json:"name"`
+	Values []string           `json:"values"`
+	Points map[string]float64 `json:"points,omitempty"` // Story points of non-numeric values, or overrides
+}
+
+// Limits on custom scales
+const (
+	MaxScaleValues      = 20
+	MaxScaleValueLength = 8
+)
+
+// PointsFor returns the story points a vote on the scale is worth: its
+// mapped points, otherwise the value itself if numeric. Votes such as "?"
+// have no points.
+func (s *VotingScale) PointsFor(value string) (float64, bool) {
+	if points, ok := s.Points[value]; ok {
+		return points, true
+	}
+	points, err := strconv.ParseFloat(value, 64)
+	if err != nil || points < 0 || math.IsNaN(points) || math.IsInf(points, 0) {
+		return 0, false
+	}
+	return points, true
+}
+
+// Validate checks a scale's values are present, unique and short enough to
+// fit on a card, and that its points map only its values to non-negative numbers
+func (s *VotingScale) Validate() error {
+	if len(s.Values) == 0 {
+		return errors.New("scale has no values")
+	}
+	if len(s.Values) > MaxScaleValues {
+		return fmt.Errorf("scale has more than %d values", MaxScaleValues)
+	}
+	seen := make(map[string]bool, len(s.Values))
+	for _, v := range s.Values {
+		if v == "" || utf8.RuneCountInString(v) > MaxScaleValueLength {
+			return fmt.Errorf("scale values must be 1 to %d characters", MaxScaleValueLength)
+		}
+		if seen[v] {
+			return fmt.Errorf("duplicate scale value %q", v)
+		}
+		seen[v] = true
+	}
+	for v, points := range s.Points {
+		if !seen[v] {
+			return fmt.Errorf("points given for %q, which is not on the scale", v)
+		}
+		if points < 0 || math.IsNaN(points) || math.IsInf(points, 0) {
+			return fmt.Errorf("points for %q must be a non-negative number", v)
+		}
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the scale
+func (s VotingScale) Clone() VotingScale {
+	s.Values = append([]string(nil), s.Values...)
+	if s.Points != nil {
+		points := make(map[string]float64, len(s.Points))
+		for v, p := range s.Points {
+			points[v] = p
+		}
+		s.Points = points
+	}
+	return s
 }
 
 // Preset voting scales
@@ -59,6 +185,7 @@ var PresetScales = map[VotingScaleType]VotingScale{
 		Type:   ScaleTShirt,
 		Name:   "T-Shirt Sizes",
 		Values: []string{"XS", "S", "M", "L", "XL", "XXL", "?"},
+		Points: map[string]float64{"XS": 1, "S": 2, "M": 3, "L": 5, "XL": 8, "XXL": 13},
 	},
 	ScalePowers2: {
 		Type:   ScalePowers2,
@@ -69,43 +196,106 @@ var PresetScales = map[VotingScaleType]VotingScale{
 
 // ClientMessage represents a message from client to server
 type ClientMessage struct {
-	Type          MessageType `json:"type"`
-	RoomCode      string      `json:"roomCode,omitempty"`
-	Name          string      `json:"name,omitempty"`
-	Vote          string      `json:"vote,omitempty"`
-	TimerDuration int         `json:"timerDuration,omitempty"` // Duration in seconds
-	AutoReveal    bool        `json:"autoReveal,omitempty"`    // Auto-reveal when timer ends
-	Issue         *JiraIssue  `json:"issue,omitempty"`
+	Type      MessageType     `json:"type"`
+	RequestID string          `json:"requestId,omitempty"` // Echoed back in the ack or error
+	Payload   json.RawMessage `json:"payload,omitempty"`   // Type-specific payload (v1)
+
+	// Legacy (v0) flat fields, kept for clients that do not negotiate a protocol
+	RoomCode      string     `json:"roomCode,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Vote          string     `json:"vote,omitempty"`
+	TimerDuration int        `json:"timerDuration,omitempty"` // Duration in seconds
+	AutoReveal    bool       `json:"autoReveal,omitempty"`    // Auto-reveal when timer ends
+	Issue         *JiraIssue `json:"issue,omitempty"`
 }
 
 // ServerMessage represents a message from server to client
 type ServerMessage struct {
-	Type    MessageType `json:"type"`
-	Payload interface{} `json:"payload,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	Payload   interface{} `json:"payload,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Code      ErrorCode   `json:"code,omitempty"`
 }
 
+// PlayerRole represents how a player takes part in a room
+type PlayerRole string
+
+const (
+	RoleVoter    PlayerRole = "voter"
+	RoleObserver PlayerRole = "observer" // Sees the room but cannot vote
+)
+
 // Player represents a player in a room
 type Player struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Avatar   string `json:"avatar"`
-	HasVoted bool   `json:"hasVoted"`
-	Vote     string `json:"vote,omitempty"`
-	IsHost   bool   `json:"isHost"`
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Avatar   string     `json:"avatar"`
+	Role     PlayerRole `json:"role"`
+	HasVoted bool       `json:"hasVoted"`
+	Vote     string     `json:"vote,omitempty"`
+	IsHost   bool       `json:"isHost"`
+	IsCoHost bool       `json:"isCoHost"`
 }
 
 // RoomState represents the current state of a room
 type RoomState struct {
-	Code            string       `json:"code"`
-	Players         []*Player    `json:"players"`
-	Revealed        bool         `json:"revealed"`
-	CurrentPlayerID string       `json:"currentPlayerId"`
-	HostID          string       `json:"hostId"`
-	Scale           *VotingScale `json:"scale"`
-	TimerEndTime    *int64       `json:"timerEndTime,omitempty"` // Unix timestamp in milliseconds
-	TimerAutoReveal bool         `json:"timerAutoReveal"`
-	CurrentIssue    *JiraIssue   `json:"currentIssue,omitempty"`
+	Code            string        `json:"code"`
+	Players         []*Player     `json:"players"`
+	Revealed        bool          `json:"revealed"`
+	CurrentPlayerID string        `json:"currentPlayerId"`
+	HostID          string        `json:"hostId"`
+	Scale           *VotingScale  `json:"scale"`
+	TimerEndTime    *int64        `json:"timerEndTime,omitempty"` // Unix timestamp in milliseconds
+	TimerAutoReveal bool          `json:"timerAutoReveal"`
+	CurrentIssue    *JiraIssue    `json:"currentIssue,omitempty"`
+	Queue           []JiraIssue   `json:"queue,omitempty"` // Issues waiting to be estimated, next first
+	JiraActions     *JiraActions  `json:"jiraActions,omitempty"`
+	Persistent      bool          `json:"persistent"`
+	History         []RoundResult `json:"history,omitempty"`
+}
+
+// RoundResult records a revealed round in a room's history
+type RoundResult struct {
+	Issue      *JiraIssue           `json:"issue,omitempty"`
+	Votes      map[string]RoundVote `json:"votes"` // Keyed by player ID, which survives reconnects
+	Average    float64              `json:"average,omitempty"`
+	RevealedAt int64                `json:"revealedAt"` // Unix timestamp in milliseconds
+}
+
+// RoundVote is one player's vote in a recorded round
+type RoundVote struct {
+	Name string `json:"name"` // The player's name at the time
+	Vote string `json:"vote"`
+}
+
+// UnmarshalJSON also reads rounds recorded by older versions, whose votes
+// were plain values keyed by player name
+func (r *RoundResult) UnmarshalJSON(data []byte) error {
+	type roundResult RoundResult
+	var raw struct {
+		*roundResult
+		Votes map[string]json.RawMessage `json:"votes"`
+	}
+	raw.roundResult = (*roundResult)(r)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	r.Votes = make(map[string]RoundVote, len(raw.Votes))
+	for key, value := range raw.Votes {
+		var legacy string
+		if json.Unmarshal(value, &legacy) == nil {
+			r.Votes[key] = RoundVote{Name: key, Vote: legacy}
+			continue
+		}
+		var vote RoundVote
+		if err := json.Unmarshal(value, &vote); err != nil {
+			return err
+		}
+		r.Votes[key] = vote
+	}
+	return nil
 }
 
 // TimerState represents the timer state broadcast to clients
@@ -0,0 +1,117 @@
+package models
+
+import "encoding/json"
+
+// Protocol versions negotiated via the WebSocket subprotocol header.
+// Clients that do not request a subprotocol speak the legacy protocol (v0),
+// which uses flat client messages and never receives acks.
+const (
+	ProtocolV0 = 0
+	ProtocolV1 = 1
+
+	// SubprotocolV1 is the Sec-WebSocket-Protocol value selecting protocol v1
+	SubprotocolV1 = "scrum-poker.v1"
+)
+
+// ErrorCode is a stable, machine-readable identifier sent alongside error messages
+type ErrorCode string
+
+const (
+	ErrCodeInvalidMessage      ErrorCode = "invalid_message"
+	ErrCodeInvalidPayload      ErrorCode = "invalid_payload"
+	ErrCodeUnknownType         ErrorCode = "unknown_type"
+	ErrCodeUnsupportedProtocol ErrorCode = "unsupported_protocol"
+	ErrCodeJoinRequired        ErrorCode = "join_required"
+	ErrCodeJoinTimeout         ErrorCode = "join_timeout"
+	ErrCodeInvalidPassphrase   ErrorCode = "invalid_passphrase"
+	ErrCodeNotHost             ErrorCode = "not_host"
+	ErrCodeNotInRoom           ErrorCode = "not_in_room"
+	ErrCodeNotVoter            ErrorCode = "not_voter"
+	ErrCodeRoomFull            ErrorCode = "room_full"
+	ErrCodeRoomClosed          ErrorCode = "room_closed"
+	ErrCodeInvalidTimer        ErrorCode = "invalid_timer_duration"
+	ErrCodeRateLimited         ErrorCode = "rate_limited"
+	ErrCodeJira                ErrorCode = "jira_error"
+	ErrCodeInternal            ErrorCode = "internal_error"
+)
+
+// ParseClientMessage decodes a raw client frame. Legacy (v0) messages carry
+// their fields at the top level, so when no payload object is present the
+// whole frame is used as the payload.
+func ParseClientMessage(data []byte) (*ClientMessage, error) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if len(msg.Payload) == 0 {
+		msg.Payload = json.RawMessage(data)
+	}
+	return &msg, nil
+}
+
+// DecodePayload unmarshals the message payload into the given payload struct
+func (m *ClientMessage) DecodePayload(v interface{}) error {
+	if len(m.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// JoinPayload is the payload of the join handshake, which must be the first
+// message sent on a new connection
+type JoinPayload struct {
+	Name             string     `json:"name"`
+	Role             PlayerRole `json:"role,omitempty"`         // Defaults to voter
+	SessionToken     string     `json:"sessionToken,omitempty"` // Resumes an existing player
+	HostToken        string     `json:"hostToken,omitempty"`
+	FacilitatorToken string     `json:"facilitatorToken,omitempty"` // Grants co-host rights
+	Passphrase       string     `json:"passphrase,omitempty"`
+}
+
+// WelcomePayload confirms a successful join
+type WelcomePayload struct {
+	PlayerID     string     `json:"playerId"`
+	SessionToken string     `json:"sessionToken"` // Present it on reconnect to keep the same player
+	Role         PlayerRole `json:"role"`
+	IsHost       bool       `json:"isHost"`
+}
+
+// TokenKind identifies which room token a token_issued message carries
+type TokenKind string
+
+const (
+	TokenKindHost        TokenKind = "host"
+	TokenKindFacilitator TokenKind = "facilitator"
+)
+
+// TokenPayload delivers a newly issued token to the host. It is sent only to
+// the requesting player and cannot be retrieved again.
+type TokenPayload struct {
+	Kind      TokenKind `json:"kind"`
+	Token     string    `json:"token"`
+	ExpiresAt *int64    `json:"expiresAt,omitempty"` // Unix timestamp in milliseconds
+}
+
+// VotePayload is the payload of a vote message. An empty vote retracts it.
+type VotePayload struct {
+	Vote string `json:"vote"`
+}
+
+// StartTimerPayload is the payload of a start_timer message
+type StartTimerPayload struct {
+	TimerDuration int  `json:"timerDuration"` // Duration in seconds
+	AutoReveal    bool `json:"autoReveal"`    // Auto-reveal when timer ends
+}
+
+// SetIssuePayload is the payload of a set_issue message
+type SetIssuePayload struct {
+	Issue *JiraIssue `json:"issue"`
+}
+
+// LoadIssuesPayload is the payload of a load_issues message, which replaces
+// the room's queue with the issues of a Jira sprint, board backlog or filter
+type LoadIssuesPayload struct {
+	Source        string `json:"source"` // "sprint", "backlog" or "filter"
+	ID            int    `json:"id"`     // Sprint, board or filter ID
+	SkipEstimated bool   `json:"skipEstimated,omitempty"`
+}
@@ -291,9 +291,9 @@ export function Home() {
                 type="text"
                 value={joinCode}
                 onChange={(e) => setJoinCode(e.target.value.toUpperCase())}
-                placeholder="Enter 6-digit code..."
+                placeholder="Enter room code..."
                 className="input-western w-full text-center tracking-widest text-xl"
-                maxLength={6}
+                maxLength={64}
                 onKeyDown={(e) => e.key === 'Enter' && joinRoom()}
               />
             </div>
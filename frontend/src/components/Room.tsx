@@ -475,9 +475,50 @@ export function Room() {
             <div>
               <div className="text-xs font-bold text-wood-500 uppercase tracking-widest mb-1">Wanted Issue</div>
               <div className="flex items-baseline gap-3">
-                <span className="text-2xl font-mono font-bold text-wood-900">{currentIssue.key}</span>
+                {currentIssue.url ? (
+                  <a
+                    href={currentIssue.url}
+                    target="_blank"
+                    rel="noopener noreferrer"
+                    className="text-2xl font-mono font-bold text-wood-900 hover:underline"
+                  >
+                    {currentIssue.key}
+                  </a>
+                ) : (
+                  <span className="text-2xl font-mono font-bold text-wood-900">{currentIssue.key}</span>
+                )}
                 <span className="text-lg text-wood-700">{currentIssue.summary}</span>
               </div>
+              {(currentIssue.type || currentIssue.status || currentIssue.priority || currentIssue.points != null || currentIssue.labels?.length) && (
+                <div className="flex flex-wrap gap-2 mt-2 text-xs text-wood-600">
+                  {[currentIssue.type, currentIssue.status, currentIssue.priority]
+                    .filter(Boolean)
+                    .map((value) => (
+                      <span key={value} className="px-2 py-0.5 bg-wood-100 rounded">{value}</span>
+                    ))}
+                  {currentIssue.points != null && (
+                    <span className="px-2 py-0.5 bg-wood-100 rounded">{currentIssue.points} pts</span>
+                  )}
+                  {currentIssue.labels?.map((label) => (
+                    <span key={label} className="px-2 py-0.5 border border-wood-300 rounded">{label}</span>
+                  ))}
+                </div>
+              )}
+              {(currentIssue.description || currentIssue.acceptanceCriteria) && (
+                <details className="mt-3 text-sm text-wood-800">
+                  <summary className="cursor-pointer font-bold text-wood-600">Details</summary>
+                  {/* Server-rendered from Jira with only safe tags and links */}
+                  {currentIssue.description && (
+                    <div className="issue-details mt-2" dangerouslySetInnerHTML={{ __html: currentIssue.description }} />
+                  )}
+                  {currentIssue.acceptanceCriteria && (
+                    <>
+                      <div className="mt-3 font-bold text-wood-600">Acceptance criteria</div>
+                      <div className="issue-details mt-1" dangerouslySetInnerHTML={{ __html: currentIssue.acceptanceCriteria }} />
+                    </>
+                  )}
+                </details>
+              )}
             </div>
             {isHost && (
               <button
@@ -692,13 +733,29 @@ export function Room() {
                               onClick={async () => {
                                 setIsSavingToJira(true);
                                 try {
+                                  // The host token lets the room's Jira actions run
+                                  const hostToken = localStorage.getItem(`scrum_poker_token_${code}`);
                                   const res = await fetch(buildApiUrl(`api/jira/issue/${currentIssue.key}/estimate`), {
                                     method: 'POST',
-                                    headers: { 'Content-Type': 'application/json' },
-                                    body: JSON.stringify({ points: parseFloat(val) })
+                                    headers: {
+                                      'Content-Type': 'application/json',
+                                      ...(hostToken ? { Authorization: `Bearer ${hostToken}` } : {})
+                                    },
+                                    // Cards are resolved to points through the room's scale
+                                    body: JSON.stringify(votingScale.includes(val)
+                                      ? { value: val, room: code }
+                                      : { points: parseFloat(val) })
                                   });
-                                  if (!res.ok) throw new Error();
-                                  setError(`Saved ${val} points to ${currentIssue.key}!`); // Success message disguised as error for visibility or make a toast?
+                                  if (res.status !== 200 && res.status !== 202) throw new Error();
+                                  const saved: { write?: { status: string; lastError?: string } } = await res.json();
+                                  if (res.status === 202) {
+                                    // Jira is busy or down; the outbox keeps retrying
+                                    setError(`Jira is unavailable, ${val} for ${currentIssue.key} will be saved when it is back`);
+                                  } else {
+                                    setError(saved.write?.lastError
+                                      ? `Saved ${val} to ${currentIssue.key}, but the Jira ${saved.write.lastError}`
+                                      : `Saved ${val} to ${currentIssue.key}!`); // Success message disguised as error for visibility or make a toast?
+                                  }
                                 } catch (e) {
                                   setError('Failed to save to Jira');
                                 } finally {
@@ -24,6 +24,7 @@ export interface VotingScale {
   type: VotingScaleType;
   name: string;
   values: string[];
+  points?: Record<string, number>; // Story points of non-numeric values, written to Jira
 }
 
 export interface Player {
@@ -93,6 +94,14 @@ export type VotingValue = (typeof VOTING_VALUES)[number];
 export interface JiraIssue {
   key: string;
   summary: string;
+  type?: string;
+  priority?: string;
+  status?: string;
+  labels?: string[];
+  points?: number;
+  description?: string; // HTML rendered and sanitized by the server
+  acceptanceCriteria?: string; // HTML rendered and sanitized by the server
+  url?: string;
 }
 
 // Preset scales for frontend use